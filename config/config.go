@@ -9,6 +9,38 @@ type Config struct {
 	DatabaseURL   string
 	DownloadDir   string
 	OmdbAPIKey    string
+	TmdbAPIKey    string
+
+	// Comma-separated metadata provider fallback order, e.g. "imdb,tmdb"
+	MetadataProviders string
+
+	// Per-endpoint metadata cache TTL overrides, e.g. "title=12h,stale=72h"
+	MetadataCacheTTLs string
+	// Comma-separated non-English locales to fetch translated metadata for
+	MetadataLocales string
+
+	// Per-field ratings source order, e.g. "imdb_rating=omdb,imdb;metacritic=omdb"
+	RatingsPrecedence string
+	// How long merged ratings are kept before a refresh re-fetches them
+	RatingsMaxAge string
+
+	// Rewrite image URLs in API responses to the local /img proxy
+	ImageProxy bool
+	// Where proxied images are cached on disk
+	ImageCacheDir string
+	// Key used to sign /img URLs; defaults to the admin password
+	ImageProxySecret string
+	// Public base URL for rewritten links, e.g. "https://omnius.example.com"
+	PublicURL string
+
+	// How long the IPTV restream proxy keeps segments in memory, e.g. "30s"
+	LiveCacheTTL string
+	// Memory the IPTV restream proxy may use for cached segments, in MB
+	LiveCacheMB string
+	// Key used to sign /live segment URLs; generated when empty
+	LiveProxySecret string
+	// Consecutive failed health checks before a channel is soft-disabled
+	ChannelHealthFailures string
 }
 
 func Load() *Config {
@@ -19,6 +51,24 @@ func Load() *Config {
 		DatabaseURL:   getEnv("DATABASE_URL", ""),
 		DownloadDir:   getEnv("DOWNLOAD_DIR", "./data/downloads"),
 		OmdbAPIKey:    getEnv("OMDB_API_KEY", ""),
+		TmdbAPIKey:    getEnv("TMDB_API_KEY", ""),
+
+		MetadataProviders: getEnv("METADATA_PROVIDERS", "imdb,tmdb"),
+		MetadataCacheTTLs: getEnv("METADATA_CACHE_TTLS", ""),
+		MetadataLocales:   getEnv("METADATA_LOCALES", "sq,tr,ar"),
+		RatingsPrecedence: getEnv("RATINGS_PRECEDENCE", ""),
+		RatingsMaxAge:     getEnv("RATINGS_MAX_AGE", "168h"),
+
+		ImageProxy:       getEnv("IMAGE_PROXY", "false") == "true",
+		ImageCacheDir:    getEnv("IMAGE_CACHE_DIR", "./data/images"),
+		ImageProxySecret: getEnv("IMAGE_PROXY_SECRET", ""),
+		PublicURL:        getEnv("PUBLIC_URL", ""),
+
+		LiveCacheTTL:    getEnv("LIVE_CACHE_TTL", "30s"),
+		LiveCacheMB:     getEnv("LIVE_CACHE_MB", "256"),
+		LiveProxySecret: getEnv("LIVE_PROXY_SECRET", ""),
+
+		ChannelHealthFailures: getEnv("CHANNEL_HEALTH_FAILURES", "3"),
 	}
 }
 
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"gorm.io/gorm/clause"
+
+	"torrent-server/models"
+)
+
+// ListComingSoonMovies returns every coming-soon movie, earliest release first
+func (d *DB) ListComingSoonMovies() ([]models.Movie, error) {
+	var movies []models.Movie
+	err := d.Where("status = ?", "coming_soon").
+		Order("release_date ASC, id ASC").
+		Find(&movies).Error
+	return movies, err
+}
+
+// AddAvailabilityWatches registers deviceID's interest in imdbCodes,
+// ignoring codes it already watches. It returns the number of new watches.
+func (d *DB) AddAvailabilityWatches(deviceID string, imdbCodes []string) (int, error) {
+	added := 0
+	for _, code := range imdbCodes {
+		res := d.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.AvailabilityWatch{DeviceID: deviceID, ImdbCode: code})
+		if res.Error != nil {
+			return added, res.Error
+		}
+		added += int(res.RowsAffected)
+	}
+	return added, nil
+}
+
+// RemoveAvailabilityWatches drops deviceID's interest in imdbCodes, or in
+// everything when imdbCodes is empty
+func (d *DB) RemoveAvailabilityWatches(deviceID string, imdbCodes []string) (int, error) {
+	query := d.Where("device_id = ?", deviceID)
+	if len(imdbCodes) > 0 {
+		query = query.Where("imdb_code IN ?", imdbCodes)
+	}
+	res := query.Delete(&models.AvailabilityWatch{})
+	return int(res.RowsAffected), res.Error
+}
+
+func (d *DB) ListAvailabilityWatches(deviceID string) ([]models.AvailabilityWatch, error) {
+	var watches []models.AvailabilityWatch
+	err := d.Where("device_id = ?", deviceID).Order("created_at DESC").Find(&watches).Error
+	return watches, err
+}
+
+func (d *DB) CountAvailabilityWatchers(imdbCode string) int64 {
+	var count int64
+	d.Model(&models.AvailabilityWatch{}).Where("imdb_code = ?", imdbCode).Count(&count)
+	return count
+}
+
+func (d *DB) CreateAvailabilityEvent(e *models.AvailabilityEvent) error {
+	return d.Create(e).Error
+}
+
+// ListDeviceAvailabilityEvents returns events newer than since for titles
+// deviceID watches, oldest first
+func (d *DB) ListDeviceAvailabilityEvents(deviceID string, since uint, limit int) ([]models.AvailabilityEvent, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	var events []models.AvailabilityEvent
+	err := d.Model(&models.AvailabilityEvent{}).
+		Joins("JOIN availability_watches ON availability_watches.imdb_code = availability_events.imdb_code").
+		Where("availability_watches.device_id = ? AND availability_events.id > ?", deviceID, since).
+		Order("availability_events.id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// ListAvailabilityEvents returns the most recent events across all titles
+func (d *DB) ListAvailabilityEvents(limit int) ([]models.AvailabilityEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var events []models.AvailabilityEvent
+	err := d.Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (d *DB) SetMovieStatus(movieID uint, status string) error {
+	return d.Model(&models.Movie{}).Where("id = ?", movieID).Update("status", status).Error
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"gorm.io/gorm/clause"
+
+	"torrent-server/models"
+)
+
+// ongoingSeries matches series that are still airing. Fresh imports use
+// "ongoing"; metadata refreshes store the provider's status (e.g. "Continuing").
+const ongoingSeries = "LOWER(series.status) NOT IN ('ended', 'canceled', 'cancelled')"
+
+// ListUpcomingEpisodes returns episodes of ongoing series airing between
+// from and to (YYYY-MM-DD, inclusive), in air date order. A seriesID limits
+// the calendar to one series, whatever its status.
+func (d *DB) ListUpcomingEpisodes(from, to string, seriesID uint) ([]models.UpcomingEpisode, error) {
+	query := d.Table("episodes").
+		Select("episodes.series_id, series.title AS series_title, series.imdb_code AS series_imdb_code, "+
+			"series.poster_image, episodes.id AS episode_id, episodes.season_number, episodes.episode_number, "+
+			"episodes.title, episodes.air_date, episodes.still_image, "+
+			"(SELECT COUNT(*) FROM episode_torrents WHERE episode_torrents.episode_id = episodes.id) AS torrent_count, "+
+			"episode_acquisitions.status AS acquisition_status").
+		Joins("JOIN series ON series.id = episodes.series_id").
+		Joins("LEFT JOIN episode_acquisitions ON episode_acquisitions.episode_id = episodes.id").
+		Where("episodes.air_date >= ? AND episodes.air_date <= ?", from, to)
+
+	if seriesID > 0 {
+		query = query.Where("episodes.series_id = ?", seriesID)
+	} else {
+		query = query.Where(ongoingSeries)
+	}
+
+	var episodes []models.UpcomingEpisode
+	err := query.
+		Order("episodes.air_date ASC, series.title ASC, episodes.season_number ASC, episodes.episode_number ASC").
+		Scan(&episodes).Error
+	for i := range episodes {
+		episodes[i].Available = episodes[i].TorrentCount > 0
+	}
+	return episodes, err
+}
+
+// ListEpisodesAwaitingTorrents returns episodes of ongoing series that aired
+// between from and to without any torrents
+func (d *DB) ListEpisodesAwaitingTorrents(from, to string) ([]models.Episode, error) {
+	var episodes []models.Episode
+	err := d.Model(&models.Episode{}).
+		Joins("JOIN series ON series.id = episodes.series_id").
+		Where("episodes.air_date >= ? AND episodes.air_date <= ?", from, to).
+		Where(ongoingSeries).
+		Where("NOT EXISTS (SELECT 1 FROM episode_torrents WHERE episode_torrents.episode_id = episodes.id)").
+		Order("episodes.air_date ASC").
+		Find(&episodes).Error
+	return episodes, err
+}
+
+func (d *DB) GetEpisodeAcquisition(episodeID uint) (*models.EpisodeAcquisition, error) {
+	var a models.EpisodeAcquisition
+	if err := d.Where("episode_id = ?", episodeID).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (d *DB) SaveEpisodeAcquisition(a *models.EpisodeAcquisition) error {
+	return d.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "episode_id"}},
+		UpdateAll: true,
+	}).Save(a).Error
+}
+
+// ListEpisodeAcquisitions returns acquisitions, most recently attempted first.
+// An empty status lists all.
+func (d *DB) ListEpisodeAcquisitions(status string, limit int) ([]models.EpisodeAcquisition, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := d.Model(&models.EpisodeAcquisition{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var list []models.EpisodeAcquisition
+	err := query.Order("last_attempt_at DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
@@ -4,27 +4,31 @@ import (
 	"fmt"
 	"time"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"torrent-server/models"
 )
 
+// epgTimeLayout is how programme start and end times are stored
+const epgTimeLayout = "2006-01-02 15:04:05"
+
+// DefaultIPTVSourceURL is the playlist seeded as the first IPTV source
+const DefaultIPTVSourceURL = "https://iptv-org.github.io/iptv/index.m3u"
+
 type ChannelFilter struct {
 	Limit     int
 	Page      int
 	Country   string
 	Category  string
+	Language  string
 	QueryTerm string
+	NSFW      string // "" for all channels, "exclude" or "only"
+	Disabled  string // "" hides soft-disabled channels, "include" or "only"
 }
 
-func (d *DB) ListChannels(filter ChannelFilter) ([]models.Channel, int, error) {
-	if filter.Limit <= 0 || filter.Limit > 50000 {
-		filter.Limit = 50
-	}
-	if filter.Page <= 0 {
-		filter.Page = 1
-	}
-
+// channelQuery applies the filter's conditions, without paging
+func (d *DB) channelQuery(filter ChannelFilter) *gorm.DB {
 	query := d.Model(&models.Channel{})
 	if filter.Country != "" {
 		query = query.Where("country = ?", filter.Country)
@@ -32,9 +36,37 @@ func (d *DB) ListChannels(filter ChannelFilter) ([]models.Channel, int, error) {
 	if filter.Category != "" {
 		query = query.Where("categories LIKE ?", "%"+filter.Category+"%")
 	}
+	if filter.Language != "" {
+		query = query.Where("languages LIKE ?", "%"+filter.Language+"%")
+	}
 	if filter.QueryTerm != "" {
 		query = query.Where("name LIKE ?", "%"+filter.QueryTerm+"%")
 	}
+	switch filter.NSFW {
+	case "exclude":
+		query = query.Where("is_nsfw = ? OR is_nsfw IS NULL", false)
+	case "only":
+		query = query.Where("is_nsfw = ?", true)
+	}
+	switch filter.Disabled {
+	case "include":
+	case "only":
+		query = query.Where("disabled = ?", true)
+	default:
+		query = query.Where("disabled = ? OR disabled IS NULL", false)
+	}
+	return query
+}
+
+func (d *DB) ListChannels(filter ChannelFilter) ([]models.Channel, int, error) {
+	if filter.Limit <= 0 || filter.Limit > 50000 {
+		filter.Limit = 50
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	query := d.channelQuery(filter)
 
 	var totalCount int64
 	query.Count(&totalCount)
@@ -45,6 +77,27 @@ func (d *DB) ListChannels(filter ChannelFilter) ([]models.Channel, int, error) {
 	return channels, int(totalCount), err
 }
 
+// ListChannelsForExport returns every channel with a stream that matches the
+// filter, ignoring paging, ordered for playlists
+func (d *DB) ListChannelsForExport(filter ChannelFilter) ([]models.Channel, error) {
+	var channels []models.Channel
+	err := d.channelQuery(filter).
+		Where("stream_url IS NOT NULL AND stream_url != ''").
+		Order("country ASC, name ASC").Find(&channels).Error
+	return channels, err
+}
+
+// ListEPGForExport returns the programmes of the channels matching the filter
+// that end after from and start before to, by channel and start time
+func (d *DB) ListEPGForExport(filter ChannelFilter, from, to time.Time) ([]models.ChannelEPG, error) {
+	channelIDs := d.channelQuery(filter).Where("stream_url IS NOT NULL AND stream_url != ''").Select("id")
+	var epgs []models.ChannelEPG
+	err := d.Where("channel_id IN (?) AND end_time >= ? AND start_time < ?",
+		channelIDs, from.Format(epgTimeLayout), to.Format(epgTimeLayout)).
+		Order("channel_id, start_time").Find(&epgs).Error
+	return epgs, err
+}
+
 func (d *DB) GetChannel(id string) (*models.Channel, error) {
 	var c models.Channel
 	if err := d.First(&c, "id = ?", id).Error; err != nil {
@@ -76,14 +129,15 @@ func (d *DB) GetChannelsByCountry(countryCode string, limit int) ([]models.Chann
 		limit = 50
 	}
 	var channels []models.Channel
-	err := d.Where("country = ?", countryCode).Order("name").Limit(limit).Find(&channels).Error
+	err := d.Where("country = ? AND (disabled = ? OR disabled IS NULL)", countryCode, false).
+		Order("name").Limit(limit).Find(&channels).Error
 	return channels, err
 }
 
 func (d *DB) UpsertChannel(ch *models.Channel) error {
 	return d.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"name", "country", "languages", "categories", "logo", "stream_url", "is_nsfw", "website", "updated_at"}),
+		DoUpdates: clause.AssignmentColumns([]string{"name", "country", "languages", "categories", "logo", "stream_url", "is_nsfw", "website", "source_id", "updated_at"}),
 	}).Create(ch).Error
 }
 
@@ -121,7 +175,7 @@ func (d *DB) UpsertEPG(epg *models.ChannelEPG) error {
 
 func (d *DB) GetEPG(channelID string) ([]models.ChannelEPG, error) {
 	var epgs []models.ChannelEPG
-	err := d.Where("channel_id = ? AND end_time >= ?", channelID, time.Now().Format("2006-01-02 15:04:05")).
+	err := d.Where("channel_id = ? AND end_time >= ?", channelID, time.Now().Format(epgTimeLayout)).
 		Order("start_time ASC").Limit(50).Find(&epgs).Error
 	return epgs, err
 }
@@ -143,6 +197,8 @@ func (d *DB) GetChannelStats() (map[string]int, error) {
 	stats["with_streams"] = int(count)
 	d.Model(&models.ChannelBlocklist{}).Count(&count)
 	stats["blocklisted"] = int(count)
+	d.Model(&models.Channel{}).Where("disabled = ?", true).Count(&count)
+	stats["disabled"] = int(count)
 	return stats, nil
 }
 
@@ -157,6 +213,38 @@ func (d *DB) UpdateChannelStream(channelID, streamURL string) error {
 		Updates(map[string]interface{}{"stream_url": streamURL, "updated_at": time.Now()}).Error
 }
 
+// UpdateChannelHealth records the outcome of a health check on a channel.
+// It leaves updated_at alone, which IPTV syncs use to find dropped channels.
+func (d *DB) UpdateChannelHealth(channelID string, disabled bool, failCount, latencyMs int, checkedAt time.Time) error {
+	return d.Model(&models.Channel{}).Where("id = ?", channelID).
+		UpdateColumns(map[string]interface{}{
+			"disabled":        disabled,
+			"fail_count":      failCount,
+			"latency_ms":      latencyMs,
+			"last_checked_at": checkedAt,
+		}).Error
+}
+
+func (d *DB) AddChannelHealthCheck(check *models.ChannelHealthCheck) error {
+	return d.Create(check).Error
+}
+
+// ListChannelHealthChecks returns a channel's most recent health checks, newest first
+func (d *DB) ListChannelHealthChecks(channelID string, limit int) ([]models.ChannelHealthCheck, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var checks []models.ChannelHealthCheck
+	err := d.Where("channel_id = ?", channelID).Order("checked_at DESC").Limit(limit).Find(&checks).Error
+	return checks, err
+}
+
+// PruneChannelHealthChecks removes health history older than before
+func (d *DB) PruneChannelHealthChecks(before time.Time) (int64, error) {
+	res := d.Where("checked_at < ?", before).Delete(&models.ChannelHealthCheck{})
+	return res.RowsAffected, res.Error
+}
+
 func (d *DB) AddToBlocklist(channelID, reason string) error {
 	bl := models.ChannelBlocklist{ChannelID: channelID, Reason: reason}
 	return d.Clauses(clause.OnConflict{
@@ -203,3 +291,78 @@ func (d *DB) GetChannelCountByCategory() ([]models.ChannelCategory, error) {
 	}
 	return cats, nil
 }
+
+// ListIPTVSources returns every IPTV source with its channel count, by priority
+func (d *DB) ListIPTVSources() ([]models.IPTVSource, error) {
+	var sources []models.IPTVSource
+	if err := d.Order("priority, id").Find(&sources).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		SourceID uint
+		Count    int
+	}
+	d.Model(&models.Channel{}).Select("source_id, COUNT(*) AS count").Group("source_id").Scan(&counts)
+	for i := range sources {
+		for _, c := range counts {
+			if c.SourceID == sources[i].ID {
+				sources[i].ChannelCount = c.Count
+			}
+		}
+	}
+	return sources, nil
+}
+
+func (d *DB) GetIPTVSource(id uint) (*models.IPTVSource, error) {
+	var src models.IPTVSource
+	if err := d.First(&src, id).Error; err != nil {
+		return nil, fmt.Errorf("source not found")
+	}
+	return &src, nil
+}
+
+func (d *DB) GetIPTVSourceByURL(url string) (*models.IPTVSource, error) {
+	var src models.IPTVSource
+	if err := d.Where("url = ?", url).First(&src).Error; err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+func (d *DB) SaveIPTVSource(src *models.IPTVSource) error {
+	return d.Save(src).Error
+}
+
+// DeleteIPTVSource removes a source and the channels imported from it
+func (d *DB) DeleteIPTVSource(id uint) error {
+	if err := d.Where("source_id = ?", id).Delete(&models.Channel{}).Error; err != nil {
+		return err
+	}
+	return d.Delete(&models.IPTVSource{}, id).Error
+}
+
+func (d *DB) UpdateIPTVSourceSync(id uint, syncedAt time.Time, lastError string) error {
+	return d.Model(&models.IPTVSource{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"last_sync_at": syncedAt, "last_error": lastError}).Error
+}
+
+// ChannelOwner is the source a stored channel was imported from
+type ChannelOwner struct {
+	ID        string
+	StreamURL string
+	SourceID  uint
+}
+
+// ListChannelOwners returns the source and stream of every channel
+func (d *DB) ListChannelOwners() ([]ChannelOwner, error) {
+	var owners []ChannelOwner
+	err := d.Model(&models.Channel{}).Select("id, stream_url, source_id").Scan(&owners).Error
+	return owners, err
+}
+
+// DeleteStaleSourceChannels removes the channels of a source that were not
+// updated since the given time, i.e. dropped from its playlist
+func (d *DB) DeleteStaleSourceChannels(sourceID uint, since time.Time) (int64, error) {
+	res := d.Where("source_id = ? AND updated_at < ?", sourceID, since).Delete(&models.Channel{})
+	return res.RowsAffected, res.Error
+}
@@ -90,3 +90,18 @@ func (d *DB) AddMovieToCuratedList(listID, movieID uint, order int) error {
 func (d *DB) RemoveMovieFromCuratedList(listID, movieID uint) error {
 	return d.Where("list_id = ? AND movie_id = ?", listID, movieID).Delete(&models.CuratedListMovie{}).Error
 }
+
+// ClearCuratedListMovies removes every pinned movie from a list
+func (d *DB) ClearCuratedListMovies(listID uint) error {
+	return d.Where("list_id = ?", listID).Delete(&models.CuratedListMovie{}).Error
+}
+
+// MaxCuratedListOrder returns the highest display order pinned in a list
+func (d *DB) MaxCuratedListOrder(listID uint) int {
+	var max int
+	d.Model(&models.CuratedListMovie{}).
+		Where("list_id = ?", listID).
+		Select("COALESCE(MAX(display_order), 0)").
+		Scan(&max)
+	return max
+}
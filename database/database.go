@@ -70,7 +70,7 @@ func (d *DB) Close() error {
 }
 
 func (d *DB) migrate() error {
-	return d.AutoMigrate(
+	err := d.AutoMigrate(
 		&models.Movie{},
 		&models.Torrent{},
 		&models.Series{},
@@ -91,7 +91,72 @@ func (d *DB) migrate() error {
 		&models.ChannelBlocklist{},
 		&models.ServiceConfig{},
 		&models.StoredSubtitle{},
+		&models.MetadataCacheEntry{},
+		&models.Localization{},
+		&models.Person{},
+		&models.PersonCredit{},
+		&models.JobRun{},
+		&models.JobSetting{},
+		&models.LibraryFolder{},
+		&models.LocalFile{},
+		&models.EpisodeAcquisition{},
+		&models.AvailabilityWatch{},
+		&models.AvailabilityEvent{},
+		&models.SubtitleProviderSetting{},
+		&models.SubtitleLanguageSetting{},
+		&models.IPTVSource{},
+		&models.ChannelHealthCheck{},
 	)
+	if err != nil {
+		return err
+	}
+	return d.createSubtitleReleaseIndex()
+}
+
+// subtitleReleaseIndex makes a named release unique per title, episode and
+// language, so provider syncs, torrent extraction, uploads and retimes can
+// insert with ON CONFLICT DO NOTHING instead of storing the same release
+// again on every run. Subtitles without a release name come from different
+// providers and are not covered.
+const subtitleReleaseIndex = "idx_subtitle_release_name"
+
+// createSubtitleReleaseIndex creates the unique release index. Databases
+// that predate it can hold duplicate named releases; those are dropped with
+// their files, keeping the oldest, before the index is created.
+func (d *DB) createSubtitleReleaseIndex() error {
+	m := d.Migrator()
+	if m.HasIndex(&models.StoredSubtitle{}, subtitleReleaseIndex) {
+		return nil
+	}
+	// An earlier development version indexed unnamed subtitles too
+	if m.HasIndex(&models.StoredSubtitle{}, "idx_subtitle_release") {
+		if err := m.DropIndex(&models.StoredSubtitle{}, "idx_subtitle_release"); err != nil {
+			return err
+		}
+	}
+
+	var dups []models.StoredSubtitle
+	err := d.Select("id, vtt_path, original_path").Where(`release_name <> '' AND id NOT IN (
+		SELECT MIN(id) FROM subtitles WHERE release_name <> ''
+		GROUP BY imdb_code, season_number, episode_number, language, release_name)`).
+		Find(&dups).Error
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate subtitles: %w", err)
+	}
+	if len(dups) > 0 {
+		ids := make([]uint, len(dups))
+		for i, sub := range dups {
+			ids[i] = sub.ID
+		}
+		if err := d.Where("id IN ?", ids).Delete(&models.StoredSubtitle{}).Error; err != nil {
+			return fmt.Errorf("failed to remove duplicate subtitles: %w", err)
+		}
+		removeSubtitleFiles(dups)
+		log.Printf("[Migration] Removed %d duplicate subtitles: %v", len(dups), ids)
+	}
+
+	return d.Exec(`CREATE UNIQUE INDEX ` + subtitleReleaseIndex + ` ON subtitles
+		(imdb_code, season_number, episode_number, language, release_name) WHERE release_name <> ''`).Error
 }
 
 func (d *DB) seed() {
@@ -103,6 +168,16 @@ func (d *DB) seed() {
 		d.Create(&models.ServiceConfig{ID: "channels", Label: "Live TV", Enabled: false, Icon: "live", DisplayOrder: 3})
 	}
 
+	var sourceCount int64
+	d.Model(&models.IPTVSource{}).Count(&sourceCount)
+	if sourceCount == 0 {
+		// Channels imported before sources existed came from this playlist
+		src := models.IPTVSource{Name: "iptv-org", URL: DefaultIPTVSourceURL, Enabled: true, Priority: 100}
+		if err := d.Create(&src).Error; err == nil {
+			d.Model(&models.Channel{}).Where("source_id = 0 OR source_id IS NULL").Update("source_id", src.ID)
+		}
+	}
+
 	var top10Count int64
 	d.Model(&models.HomeSection{}).Where("display_type = ?", "top10").Count(&top10Count)
 	if top10Count == 0 {
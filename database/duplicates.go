@@ -0,0 +1,242 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"torrent-server/models"
+)
+
+// ListMovieCandidates returns every movie with its torrent count for the
+// duplicate finder
+func (d *DB) ListMovieCandidates() ([]models.DuplicateCandidate, error) {
+	var candidates []models.DuplicateCandidate
+	err := d.Model(&models.Movie{}).
+		Select("movies.id, 'movie' AS type, movies.imdb_code, movies.title, movies.year, movies.runtime, movies.rating, movies.imdb_rating, " +
+			"(SELECT COUNT(*) FROM torrents WHERE torrents.movie_id = movies.id) AS torrents").
+		Order("movies.id").
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+// ListSeriesCandidates returns every series with its episode torrent count
+func (d *DB) ListSeriesCandidates() ([]models.DuplicateCandidate, error) {
+	var candidates []models.DuplicateCandidate
+	err := d.Model(&models.Series{}).
+		Select("series.id, 'series' AS type, series.imdb_code, series.title, series.year, series.runtime, series.rating, series.imdb_rating, " +
+			"(SELECT COUNT(*) FROM episode_torrents WHERE episode_torrents.series_id = series.id) AS torrents").
+		Order("series.id").
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+// MergeMovies folds duplicateID into survivorID and deletes the duplicate.
+// Torrents, subtitles, local files, curated list memberships, home section
+// references and analytics move to the survivor; rows the survivor already
+// has are dropped (or, for analytics, added together).
+func (d *DB) MergeMovies(survivorID, duplicateID uint) (*models.MergeResult, error) {
+	if survivorID == duplicateID {
+		return nil, fmt.Errorf("cannot merge a movie into itself")
+	}
+	survivor, err := d.GetMovie(survivorID)
+	if err != nil {
+		return nil, fmt.Errorf("survivor movie %d not found", survivorID)
+	}
+	dup, err := d.GetMovie(duplicateID)
+	if err != nil {
+		return nil, fmt.Errorf("duplicate movie %d not found", duplicateID)
+	}
+
+	result := &models.MergeResult{}
+	var droppedSubs []models.StoredSubtitle
+	err = d.Transaction(func(tx *gorm.DB) error {
+		// Torrents, skipping hashes the survivor already has
+		res := tx.Model(&models.Torrent{}).
+			Where("movie_id = ? AND hash NOT IN (?)", dup.ID,
+				tx.Model(&models.Torrent{}).Select("hash").Where("movie_id = ?", survivor.ID)).
+			Update("movie_id", survivor.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Torrents = int(res.RowsAffected)
+		if err := tx.Where("movie_id = ?", dup.ID).Delete(&models.Torrent{}).Error; err != nil {
+			return err
+		}
+
+		// Subtitles are keyed by IMDb code, skipping releases the survivor
+		// already has a subtitle for in the same language
+		res = tx.Model(&models.StoredSubtitle{}).
+			Where("imdb_code = ? AND season_number = 0", dup.ImdbCode).
+			Where(`(release_name = '' OR NOT EXISTS (SELECT 1 FROM subtitles s WHERE s.imdb_code = ?
+				AND s.season_number = subtitles.season_number AND s.episode_number = subtitles.episode_number
+				AND s.language = subtitles.language AND s.release_name = subtitles.release_name))`, survivor.ImdbCode).
+			Update("imdb_code", survivor.ImdbCode)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Subtitles = int(res.RowsAffected)
+		if err := tx.Where("imdb_code = ? AND season_number = 0", dup.ImdbCode).Find(&droppedSubs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("imdb_code = ? AND season_number = 0", dup.ImdbCode).Delete(&models.StoredSubtitle{}).Error; err != nil {
+			return err
+		}
+
+		res = tx.Model(&models.LocalFile{}).Where("movie_id = ?", dup.ID).Update("movie_id", survivor.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.LocalFiles = int(res.RowsAffected)
+
+		// Curated lists: keep the better (lower) position when both are listed
+		var memberships []models.CuratedListMovie
+		tx.Where("movie_id = ?", dup.ID).Find(&memberships)
+		for _, m := range memberships {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "list_id"}, {Name: "movie_id"}},
+				DoUpdates: clause.Set{{
+					Column: clause.Column{Name: "display_order"},
+					Value:  gorm.Expr("CASE WHEN curated_list_movies.display_order < ? THEN curated_list_movies.display_order ELSE ? END", m.DisplayOrder, m.DisplayOrder),
+				}},
+			}).Create(&models.CuratedListMovie{ListID: m.ListID, MovieID: survivor.ID, DisplayOrder: m.DisplayOrder}).Error
+			if err != nil {
+				return err
+			}
+			result.CuratedLists++
+		}
+		if err := tx.Where("movie_id = ?", dup.ID).Delete(&models.CuratedListMovie{}).Error; err != nil {
+			return err
+		}
+
+		res = tx.Model(&models.HomeSection{}).
+			Where("content_type = ? AND content_id = ?", "movie", dup.ID).
+			Update("content_id", survivor.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.HomeSections = int(res.RowsAffected)
+
+		views, err := moveAnalytics(tx, "movie", dup.ID, "movie", survivor.ID, survivor.ImdbCode)
+		if err != nil {
+			return err
+		}
+		result.Views = views
+
+		return tx.Model(&models.Movie{}).Where("id = ?", survivor.ID).Updates(map[string]interface{}{
+			"like_count":     gorm.Expr("like_count + ?", dup.LikeCount),
+			"download_count": gorm.Expr("download_count + ?", dup.DownloadCount),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	removeSubtitleFiles(droppedSubs)
+
+	if err := d.DeleteMovie(dup.ID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// MergeMovieIntoSeries replaces a series that was stored as a movie with the
+// real series entry: home section references and analytics move to the
+// series, then the movie is deleted. Movie torrents and subtitles don't map
+// onto episodes and are dropped with it.
+func (d *DB) MergeMovieIntoSeries(movieID, seriesID uint) (*models.MergeResult, error) {
+	if _, err := d.GetMovie(movieID); err != nil {
+		return nil, fmt.Errorf("movie %d not found", movieID)
+	}
+	series, err := d.GetSeries(seriesID)
+	if err != nil {
+		return nil, fmt.Errorf("series %d not found", seriesID)
+	}
+
+	result := &models.MergeResult{}
+	err = d.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.HomeSection{}).
+			Where("content_type = ? AND content_id = ?", "movie", movieID).
+			Updates(map[string]interface{}{"content_type": "series", "content_id": series.ID})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.HomeSections = int(res.RowsAffected)
+
+		views, err := moveAnalytics(tx, "movie", movieID, "series", series.ID, series.ImdbCode)
+		if err != nil {
+			return err
+		}
+		result.Views = views
+
+		// Curated lists only hold movies
+		return tx.Where("movie_id = ?", movieID).Delete(&models.CuratedListMovie{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.DeleteMovie(movieID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// moveAnalytics re-points views, daily stats and active streams from one
+// content item to another, summing rows that collide on the unique indexes.
+// It returns the number of view rows moved.
+func moveAnalytics(tx *gorm.DB, fromType string, fromID uint, toType string, toID uint, toImdb string) (int, error) {
+	var views []models.ContentView
+	tx.Where("content_type = ? AND content_id = ?", fromType, fromID).Find(&views)
+	for _, v := range views {
+		var existing models.ContentView
+		err := tx.Where("content_type = ? AND content_id = ? AND device_id = ? AND view_date = ?",
+			toType, toID, v.DeviceID, v.ViewDate).First(&existing).Error
+		if err == nil {
+			err = tx.Model(&existing).Updates(map[string]interface{}{
+				"view_count":     existing.ViewCount + v.ViewCount,
+				"watch_duration": existing.WatchDuration + v.WatchDuration,
+				"completed":      existing.Completed || v.Completed,
+			}).Error
+			if err == nil {
+				err = tx.Delete(&v).Error
+			}
+		} else {
+			err = tx.Model(&v).Updates(map[string]interface{}{
+				"content_type": toType, "content_id": toID, "imdb_code": toImdb,
+			}).Error
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var stats []models.ContentStatsDaily
+	tx.Where("content_type = ? AND content_id = ?", fromType, fromID).Find(&stats)
+	for _, s := range stats {
+		var existing models.ContentStatsDaily
+		err := tx.Where("content_type = ? AND content_id = ? AND stat_date = ?", toType, toID, s.StatDate).
+			First(&existing).Error
+		if err == nil {
+			err = tx.Model(&existing).Updates(map[string]interface{}{
+				"view_count":       existing.ViewCount + s.ViewCount,
+				"unique_viewers":   existing.UniqueViewers + s.UniqueViewers,
+				"total_watch_time": existing.TotalWatchTime + s.TotalWatchTime,
+				"completions":      existing.Completions + s.Completions,
+			}).Error
+			if err == nil {
+				err = tx.Delete(&s).Error
+			}
+		} else {
+			err = tx.Model(&s).Updates(map[string]interface{}{"content_type": toType, "content_id": toID}).Error
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err := tx.Model(&models.ActiveStream{}).
+		Where("content_type = ? AND content_id = ?", fromType, fromID).
+		Updates(map[string]interface{}{"content_type": toType, "content_id": toID, "imdb_code": toImdb}).Error
+	return len(views), err
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"torrent-server/models"
+)
+
+// jobRunsKept is how many runs of each job are kept in history
+const jobRunsKept = 200
+
+func (d *DB) CreateJobRun(run *models.JobRun) error {
+	return d.Create(run).Error
+}
+
+func (d *DB) SaveJobRun(run *models.JobRun) error {
+	return d.Save(run).Error
+}
+
+func (d *DB) GetJobRun(id uint) (*models.JobRun, error) {
+	var run models.JobRun
+	if err := d.First(&run, id).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListJobRuns returns a job's runs, newest first. An empty job lists all jobs.
+func (d *DB) ListJobRuns(job string, limit int) ([]models.JobRun, error) {
+	query := d.Order("started_at DESC, id DESC")
+	if job != "" {
+		query = query.Where("job = ?", job)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var runs []models.JobRun
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// GetLastJobRun returns the most recent run of a job, or nil
+func (d *DB) GetLastJobRun(job string) *models.JobRun {
+	var run models.JobRun
+	if err := d.Where("job = ?", job).Order("started_at DESC, id DESC").First(&run).Error; err != nil {
+		return nil
+	}
+	return &run
+}
+
+// PruneJobRuns drops the oldest runs of a job beyond the history limit
+func (d *DB) PruneJobRuns(job string) error {
+	keep := d.Model(&models.JobRun{}).Select("id").Where("job = ?", job).
+		Order("started_at DESC, id DESC").Limit(jobRunsKept)
+	return d.Where("job = ? AND id NOT IN (?)", job, keep).Delete(&models.JobRun{}).Error
+}
+
+// MarkInterruptedJobRuns closes runs left "running" by a previous process
+func (d *DB) MarkInterruptedJobRuns() (int64, error) {
+	now := time.Now()
+	result := d.Model(&models.JobRun{}).
+		Where("status = ?", models.JobStatusRunning).
+		Updates(map[string]interface{}{
+			"status":      models.JobStatusInterrupted,
+			"finished_at": now,
+			"error":       "server restarted while the job was running",
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (d *DB) GetJobSettings() (map[string]models.JobSetting, error) {
+	var rows []models.JobSetting
+	if err := d.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	settings := make(map[string]models.JobSetting, len(rows))
+	for _, s := range rows {
+		settings[s.Name] = s
+	}
+	return settings, nil
+}
+
+func (d *DB) SaveJobSetting(s *models.JobSetting) error {
+	return d.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"schedule", "paused", "updated_at"}),
+	}).Create(s).Error
+}
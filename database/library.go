@@ -0,0 +1,122 @@
+package database
+
+import (
+	"torrent-server/models"
+)
+
+func (d *DB) ListLibraryFolders() ([]models.LibraryFolder, error) {
+	var folders []models.LibraryFolder
+	err := d.Order("path ASC").Find(&folders).Error
+	return folders, err
+}
+
+func (d *DB) GetLibraryFolder(id uint) (*models.LibraryFolder, error) {
+	var f models.LibraryFolder
+	if err := d.First(&f, id).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (d *DB) CreateLibraryFolder(f *models.LibraryFolder) error {
+	return d.Create(f).Error
+}
+
+func (d *DB) SaveLibraryFolder(f *models.LibraryFolder) error {
+	return d.Save(f).Error
+}
+
+// DeleteLibraryFolder removes a folder and forgets its files. Nothing on
+// disk is touched.
+func (d *DB) DeleteLibraryFolder(id uint) error {
+	d.Where("folder_id = ?", id).Delete(&models.LocalFile{})
+	return d.Delete(&models.LibraryFolder{}, id).Error
+}
+
+// LocalFileFilter selects local files for the admin listing
+type LocalFileFilter struct {
+	FolderID  uint
+	Unmatched bool
+	Page      int
+	Limit     int
+}
+
+func (d *DB) ListLocalFiles(filter LocalFileFilter) ([]models.LocalFile, int64, error) {
+	query := d.Model(&models.LocalFile{})
+	if filter.FolderID > 0 {
+		query = query.Where("folder_id = ?", filter.FolderID)
+	}
+	if filter.Unmatched {
+		query = query.Where("movie_id IS NULL AND episode_id IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	var files []models.LocalFile
+	err := query.Order("path ASC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&files).Error
+	return files, total, err
+}
+
+func (d *DB) GetLocalFile(id uint) (*models.LocalFile, error) {
+	var f models.LocalFile
+	if err := d.First(&f, id).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetFolderLocalFiles returns a folder's known files keyed by path
+func (d *DB) GetFolderLocalFiles(folderID uint) (map[string]*models.LocalFile, error) {
+	var files []models.LocalFile
+	if err := d.Where("folder_id = ?", folderID).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*models.LocalFile, len(files))
+	for i := range files {
+		byPath[files[i].Path] = &files[i]
+	}
+	return byPath, nil
+}
+
+func (d *DB) SaveLocalFile(f *models.LocalFile) error {
+	return d.Save(f).Error
+}
+
+func (d *DB) DeleteLocalFiles(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.Where("id IN ?", ids).Delete(&models.LocalFile{}).Error
+}
+
+// CountMatchedLocalFiles counts a folder's files attached to a movie or episode
+func (d *DB) CountMatchedLocalFiles(folderID uint) int {
+	var n int64
+	d.Model(&models.LocalFile{}).
+		Where("folder_id = ? AND (movie_id IS NOT NULL OR episode_id IS NOT NULL)", folderID).
+		Count(&n)
+	return int(n)
+}
+
+// UnlinkLocalFiles detaches matching local files from their movie or
+// episode, leaving them to be re-matched on the next scan
+func (d *DB) UnlinkLocalFiles(query string, args ...interface{}) {
+	d.Model(&models.LocalFile{}).Where(query, args...).Updates(map[string]interface{}{
+		"movie_id":    nil,
+		"episode_id":  nil,
+		"imdb_code":   "",
+		"matched_by":  "",
+		"match_error": "",
+	})
+}
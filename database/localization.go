@@ -0,0 +1,53 @@
+package database
+
+import (
+	"gorm.io/gorm/clause"
+
+	"torrent-server/models"
+)
+
+// SaveLocalization inserts or replaces the translation for one content/locale pair
+func (d *DB) SaveLocalization(l *models.Localization) error {
+	return d.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_type"}, {Name: "content_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "summary", "tagline", "poster_image", "source", "updated_at"}),
+	}).Create(l).Error
+}
+
+// GetLocalizations returns translations for the given content IDs, keyed by content ID
+func (d *DB) GetLocalizations(contentType string, ids []uint, locale string) (map[uint]models.Localization, error) {
+	out := make(map[uint]models.Localization)
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	var rows []models.Localization
+	err := d.Where("content_type = ? AND locale = ? AND content_id IN ?", contentType, locale, ids).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range rows {
+		out[l.ContentID] = l
+	}
+	return out, nil
+}
+
+// SearchLocalizedContent returns IDs of content whose translated title matches query
+func (d *DB) SearchLocalizedContent(contentType, locale, query string, limit int) ([]uint, error) {
+	var ids []uint
+	err := d.Model(&models.Localization{}).
+		Where("content_type = ? AND locale = ? AND LOWER(title) LIKE LOWER(?)", contentType, locale, "%"+query+"%").
+		Limit(limit).
+		Pluck("content_id", &ids).Error
+	return ids, err
+}
+
+// DeleteLocalizations removes all translations for one piece of content
+func (d *DB) DeleteLocalizations(contentType string, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.Where("content_type = ? AND content_id IN ?", contentType, ids).
+		Delete(&models.Localization{}).Error
+}
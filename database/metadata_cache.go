@@ -0,0 +1,60 @@
+package database
+
+import (
+	"gorm.io/gorm/clause"
+
+	"torrent-server/models"
+)
+
+func (d *DB) GetMetadataCacheEntry(key string) (*models.MetadataCacheEntry, error) {
+	var e models.MetadataCacheEntry
+	if err := d.Where("cache_key = ?", key).First(&e).Error; err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (d *DB) SaveMetadataCacheEntry(e *models.MetadataCacheEntry) error {
+	return d.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"body", "fetched_at"}),
+	}).Create(e).Error
+}
+
+// PurgeMetadataCache deletes cached responses. Empty arguments match everything.
+func (d *DB) PurgeMetadataCache(provider, endpoint, imdbCode string) (int64, error) {
+	query := d.Where("1 = 1")
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	if imdbCode != "" {
+		query = query.Where("imdb_code = ?", imdbCode)
+	}
+	result := query.Delete(&models.MetadataCacheEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// CountMetadataCacheEntries returns the number of cached responses per provider/endpoint
+func (d *DB) CountMetadataCacheEntries() (map[string]int, error) {
+	var rows []struct {
+		Provider string
+		Endpoint string
+		Count    int
+	}
+	err := d.Model(&models.MetadataCacheEntry{}).
+		Select("provider, endpoint, COUNT(*) as count").
+		Group("provider, endpoint").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[r.Provider+"/"+r.Endpoint] = r.Count
+	}
+	return counts, nil
+}
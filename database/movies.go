@@ -23,6 +23,8 @@ type MovieFilter struct {
 	MaximumYear   int
 	MinimumYear   int
 	Status        string
+	PersonID      uint   // only movies crediting this person
+	PersonRole    string // with PersonID: actor, director or writer
 }
 
 func (d *DB) ListMovies(filter MovieFilter) ([]models.Movie, int, error) {
@@ -62,6 +64,14 @@ func (d *DB) ListMovies(filter MovieFilter) ([]models.Movie, int, error) {
 	if filter.Status != "" {
 		query = query.Where("COALESCE(status, 'available') = ?", filter.Status)
 	}
+	if filter.PersonID > 0 {
+		credits := d.DB.Model(&models.PersonCredit{}).Select("content_id").
+			Where("content_type = ? AND person_id = ?", "movie", filter.PersonID)
+		if filter.PersonRole != "" {
+			credits = credits.Where("role = ?", filter.PersonRole)
+		}
+		query = query.Where("id IN (?)", credits)
+	}
 
 	var totalCount int64
 	if err := query.Count(&totalCount).Error; err != nil {
@@ -97,6 +107,7 @@ func (d *DB) ListMovies(filter MovieFilter) ([]models.Movie, int, error) {
 
 	for i := range movies {
 		d.DB.Where("movie_id = ?", movies[i].ID).Find(&movies[i].Torrents)
+		d.DB.Where("movie_id = ?", movies[i].ID).Find(&movies[i].LocalFiles)
 	}
 
 	return movies, int(totalCount), nil
@@ -104,7 +115,7 @@ func (d *DB) ListMovies(filter MovieFilter) ([]models.Movie, int, error) {
 
 func (d *DB) GetMovie(id uint) (*models.Movie, error) {
 	var m models.Movie
-	if err := d.Preload("Torrents").First(&m, id).Error; err != nil {
+	if err := d.Preload("Torrents").Preload("LocalFiles").First(&m, id).Error; err != nil {
 		return nil, err
 	}
 	if m.Status == "" {
@@ -115,7 +126,7 @@ func (d *DB) GetMovie(id uint) (*models.Movie, error) {
 
 func (d *DB) GetMovieByIMDB(imdbCode string) (*models.Movie, error) {
 	var m models.Movie
-	if err := d.Preload("Torrents").Where("imdb_code = ?", imdbCode).First(&m).Error; err != nil {
+	if err := d.Preload("Torrents").Preload("LocalFiles").Where("imdb_code = ?", imdbCode).First(&m).Error; err != nil {
 		return nil, err
 	}
 	if m.Status == "" {
@@ -143,6 +154,9 @@ func (d *DB) UpdateMovie(m *models.Movie) error {
 }
 
 func (d *DB) DeleteMovie(id uint) error {
+	d.UnlinkLocalFiles("movie_id = ?", id)
+	d.DeleteLocalizations("movie", id)
+	d.DeleteCredits("movie", id)
 	return d.Delete(&models.Movie{}, id).Error
 }
 
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"torrent-server/models"
+)
+
+// findOrCreatePerson matches by IMDB code when known, otherwise by name among
+// people without one. A missing image or IMDB code is filled in on match.
+func findOrCreatePerson(tx *gorm.DB, name, imdbCode, imageURL string) (*models.Person, error) {
+	name = strings.TrimSpace(name)
+
+	var p models.Person
+	var err error
+	if imdbCode != "" {
+		err = tx.Where("imdb_code = ?", imdbCode).First(&p).Error
+		if err == gorm.ErrRecordNotFound {
+			// A name-only entry from an earlier sync can be claimed by the code
+			err = tx.Where("imdb_code = '' AND LOWER(name) = LOWER(?)", name).First(&p).Error
+		}
+	} else {
+		err = tx.Where("LOWER(name) = LOWER(?)", name).Order("id").First(&p).Error
+	}
+
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		p = models.Person{Name: name, ImdbCode: imdbCode, ImageURL: imageURL}
+		if err := tx.Create(&p).Error; err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case err != nil:
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if p.ImdbCode == "" && imdbCode != "" {
+		updates["imdb_code"] = imdbCode
+	}
+	if p.ImageURL == "" && imageURL != "" {
+		updates["image_url"] = imageURL
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&p).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// SyncCredits replaces the people credited on a movie or series
+func (d *DB) SyncCredits(contentType string, contentID uint, cast []models.Cast, directors, writers []string) error {
+	return d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("content_type = ? AND content_id = ?", contentType, contentID).
+			Delete(&models.PersonCredit{}).Error; err != nil {
+			return err
+		}
+
+		type creditKey struct {
+			role     string
+			personID uint
+		}
+		seen := make(map[creditKey]bool)
+		add := func(name, imdbCode, imageURL, role, character string, order int) error {
+			if strings.TrimSpace(name) == "" {
+				return nil
+			}
+			p, err := findOrCreatePerson(tx, name, imdbCode, imageURL)
+			if err != nil {
+				return err
+			}
+			key := creditKey{role, p.ID}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			return tx.Create(&models.PersonCredit{
+				PersonID:      p.ID,
+				ContentType:   contentType,
+				ContentID:     contentID,
+				Role:          role,
+				CharacterName: character,
+				BillingOrder:  order,
+			}).Error
+		}
+
+		for i, c := range cast {
+			if err := add(c.Name, c.ImdbCode, c.URLSmallImage, models.RoleActor, c.CharacterName, i); err != nil {
+				return err
+			}
+		}
+		for i, name := range directors {
+			if err := add(name, "", "", models.RoleDirector, "", i); err != nil {
+				return err
+			}
+		}
+		for i, name := range writers {
+			if err := add(name, "", "", models.RoleWriter, "", i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d *DB) GetPerson(id uint) (*models.Person, error) {
+	var p models.Person
+	if err := d.First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (d *DB) GetPersonByIMDB(imdbCode string) (*models.Person, error) {
+	var p models.Person
+	if err := d.Where("imdb_code = ?", imdbCode).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CountPersonCredits returns the number of credits per role for a person
+func (d *DB) CountPersonCredits(personID uint) (map[string]int, error) {
+	var rows []struct {
+		Role  string
+		Count int
+	}
+	err := d.Model(&models.PersonCredit{}).
+		Select("role, COUNT(*) as count").
+		Where("person_id = ?", personID).
+		Group("role").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[r.Role] = r.Count
+	}
+	return counts, nil
+}
+
+// GetPersonCredits returns a person's filmography, optionally limited to one role,
+// newest first
+func (d *DB) GetPersonCredits(personID uint, role string) ([]models.PersonCreditItem, error) {
+	query := d.Where("person_id = ?", personID)
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var credits []models.PersonCredit
+	if err := query.Order("billing_order").Find(&credits).Error; err != nil {
+		return nil, err
+	}
+
+	var movieIDs, seriesIDs []uint
+	for _, c := range credits {
+		if c.ContentType == "series" {
+			seriesIDs = append(seriesIDs, c.ContentID)
+		} else {
+			movieIDs = append(movieIDs, c.ContentID)
+		}
+	}
+
+	movies := make(map[uint]models.Movie)
+	if len(movieIDs) > 0 {
+		var rows []models.Movie
+		d.Select("id, imdb_code, title, year, rating, medium_cover_image").Where("id IN ?", movieIDs).Find(&rows)
+		for _, m := range rows {
+			movies[m.ID] = m
+		}
+	}
+	series := make(map[uint]models.Series)
+	if len(seriesIDs) > 0 {
+		var rows []models.Series
+		d.Select("id, imdb_code, title, year, rating, poster_image").Where("id IN ?", seriesIDs).Find(&rows)
+		for _, s := range rows {
+			series[s.ID] = s
+		}
+	}
+
+	items := make([]models.PersonCreditItem, 0, len(credits))
+	for _, c := range credits {
+		item := models.PersonCreditItem{
+			ContentType:   c.ContentType,
+			ContentID:     c.ContentID,
+			Role:          c.Role,
+			CharacterName: c.CharacterName,
+		}
+		if c.ContentType == "series" {
+			s, ok := series[c.ContentID]
+			if !ok {
+				continue
+			}
+			item.ImdbCode, item.Title, item.Year, item.Rating, item.PosterImage = s.ImdbCode, s.Title, s.Year, s.Rating, s.PosterImage
+		} else {
+			m, ok := movies[c.ContentID]
+			if !ok {
+				continue
+			}
+			item.ImdbCode, item.Title, item.Year, item.Rating, item.PosterImage = m.ImdbCode, m.Title, m.Year, m.Rating, m.MediumCoverImage
+		}
+		items = append(items, item)
+	}
+
+	// Newest first
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Year > items[j].Year })
+	return items, nil
+}
+
+// DeleteCredits removes all credits for a movie or series
+func (d *DB) DeleteCredits(contentType string, contentID uint) error {
+	return d.Where("content_type = ? AND content_id = ?", contentType, contentID).
+		Delete(&models.PersonCredit{}).Error
+}
+
+// BackfillPeople creates people and credits from the cast_json, director and
+// writers columns of movies that have no credits yet.
+func (d *DB) BackfillPeople() {
+	var movies []models.Movie
+	err := d.Select("id, title, cast_json, director, writers").
+		Where("id NOT IN (?)", d.DB.Model(&models.PersonCredit{}).Select("content_id").Where("content_type = ?", "movie")).
+		Find(&movies).Error
+	if err != nil {
+		log.Printf("[People] Backfill query failed: %v", err)
+		return
+	}
+
+	filled := 0
+	for _, m := range movies {
+		if len(m.Cast) == 0 && m.Director == "" && len(m.Writers) == 0 {
+			continue
+		}
+		var directors []string
+		if m.Director != "" {
+			directors = []string{m.Director}
+		}
+		if err := d.SyncCredits("movie", m.ID, m.Cast, directors, m.Writers); err != nil {
+			log.Printf("[People] Backfill failed for %s: %v", m.Title, err)
+			continue
+		}
+		filled++
+	}
+	if filled > 0 {
+		log.Printf("[People] Backfilled credits for %d movies", filled)
+	}
+}
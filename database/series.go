@@ -124,8 +124,16 @@ func (d *DB) DeleteSeries(id uint) error {
 	d.Where("episode_id IN (?)",
 		d.DB.Model(&models.Episode{}).Select("id").Where("series_id = ?", id),
 	).Delete(&models.EpisodeTorrent{})
+	d.Where("content_type = ? AND content_id IN (?)", "episode",
+		d.DB.Model(&models.Episode{}).Select("id").Where("series_id = ?", id),
+	).Delete(&models.Localization{})
+	d.UnlinkLocalFiles("episode_id IN (?)",
+		d.DB.Model(&models.Episode{}).Select("id").Where("series_id = ?", id),
+	)
 	d.Where("series_id = ?", id).Delete(&models.Episode{})
 	d.Where("series_id = ?", id).Delete(&models.SeasonPack{})
+	d.DeleteLocalizations("series", id)
+	d.DeleteCredits("series", id)
 	return d.Delete(&models.Series{}, id).Error
 }
 
@@ -143,6 +151,7 @@ func (d *DB) GetEpisodes(seriesID uint, season int) ([]models.Episode, error) {
 
 	for i := range episodes {
 		d.DB.Where("episode_id = ?", episodes[i].ID).Find(&episodes[i].Torrents)
+		d.DB.Where("episode_id = ?", episodes[i].ID).Find(&episodes[i].LocalFiles)
 	}
 
 	return episodes, nil
@@ -159,6 +168,17 @@ func (d *DB) CreateEpisode(e *models.Episode) error {
 	}).Create(e).Error
 }
 
+// GetEpisodeByNumber returns one episode of a series
+func (d *DB) GetEpisodeByNumber(seriesID uint, season, episode int) (*models.Episode, error) {
+	var e models.Episode
+	err := d.Where("series_id = ? AND season_number = ? AND episode_number = ?", seriesID, season, episode).
+		First(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
 func (d *DB) GetEpisodeTorrents(episodeID uint) ([]models.EpisodeTorrent, error) {
 	var torrents []models.EpisodeTorrent
 	err := d.Where("episode_id = ?", episodeID).Find(&torrents).Error
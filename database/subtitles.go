@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"gorm.io/gorm/clause"
@@ -33,8 +34,8 @@ func (d *DB) GetSubtitlesByIMDBEpisode(imdbCode, language string, season, episod
 
 	var subtitles []models.StoredSubtitle
 	err := query.
-		Select("id, imdb_code, language, language_name, release_name, hearing_impaired, source, season_number, episode_number, created_at").
-		Order("season_number, episode_number, created_at DESC").
+		Select("id, imdb_code, language, language_name, release_name, hearing_impaired, source, score, season_number, episode_number, created_at").
+		Order("season_number, episode_number, score DESC, created_at DESC").
 		Find(&subtitles).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to query subtitles: %w", err)
@@ -52,8 +53,13 @@ func (d *DB) GetSubtitleByID(id uint) (*models.StoredSubtitle, error) {
 
 func (d *DB) CreateSubtitle(sub *models.StoredSubtitle) error {
 	result := d.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "imdb_code"}, {Name: "language"}, {Name: "release_name"}},
-		DoNothing: true,
+		Columns: []clause.Column{
+			{Name: "imdb_code"}, {Name: "season_number"}, {Name: "episode_number"},
+			{Name: "language"}, {Name: "release_name"},
+		},
+		// Matches the partial index, see subtitleReleaseIndex
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "release_name <> ''"}}},
+		DoNothing:   true,
 	}).Create(sub)
 	if result.Error != nil {
 		return fmt.Errorf("failed to create subtitle: %w", result.Error)
@@ -66,6 +72,95 @@ func (d *DB) UpdateSubtitlePath(id uint, vttPath string) error {
 		Updates(map[string]interface{}{"vtt_path": vttPath, "vtt_content": ""}).Error
 }
 
+func (d *DB) UpdateSubtitleOriginal(id uint, path, format string) error {
+	return d.Model(&models.StoredSubtitle{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"original_path": path, "original_format": format}).Error
+}
+
+func (d *DB) UpdateSubtitleCharset(id uint, charset string) error {
+	return d.Model(&models.StoredSubtitle{}).Where("id = ?", id).Update("charset", charset).Error
+}
+
+// ListSubtitlesWithoutCharset returns subtitles stored before charset detection
+func (d *DB) ListSubtitlesWithoutCharset() ([]models.StoredSubtitle, error) {
+	var subs []models.StoredSubtitle
+	err := d.Where("charset = '' OR charset IS NULL").Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (d *DB) UpdateSubtitleScore(id uint, score int, fingerprint string) error {
+	return d.Model(&models.StoredSubtitle{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"score": score, "fingerprint": fingerprint}).Error
+}
+
+// FindSubtitleDuplicate returns the best-scored other subtitle of the same
+// movie or episode and language with the same fingerprint, or nil
+func (d *DB) FindSubtitleDuplicate(sub *models.StoredSubtitle) (*models.StoredSubtitle, error) {
+	if sub.Fingerprint == "" {
+		return nil, nil
+	}
+	var dups []models.StoredSubtitle
+	err := d.Where("imdb_code = ? AND season_number = ? AND episode_number = ? AND language = ? AND fingerprint = ? AND id != ?",
+		sub.ImdbCode, sub.SeasonNumber, sub.EpisodeNumber, sub.Language, sub.Fingerprint, sub.ID).
+		Order("score DESC, id").Limit(1).Find(&dups).Error
+	if err != nil || len(dups) == 0 {
+		return nil, err
+	}
+	return &dups[0], nil
+}
+
+// ListSubtitleIDs returns the ID of every stored subtitle
+func (d *DB) ListSubtitleIDs() ([]uint, error) {
+	var ids []uint
+	err := d.Model(&models.StoredSubtitle{}).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetSubtitleRuntime returns the runtime in minutes of a movie (season and
+// episode 0) or episode, falling back to the series runtime, or 0 if unknown
+func (d *DB) GetSubtitleRuntime(imdbCode string, season, episode int) int {
+	var runtime int
+	if season == 0 && episode == 0 {
+		d.Raw(`SELECT runtime FROM movies WHERE imdb_code = ? LIMIT 1`, imdbCode).Scan(&runtime)
+		return runtime
+	}
+	d.Raw(`SELECT CASE WHEN episodes.runtime > 0 THEN episodes.runtime ELSE series.runtime END FROM episodes
+		JOIN series ON series.id = episodes.series_id
+		WHERE series.imdb_code = ? AND episodes.season_number = ? AND episodes.episode_number = ? LIMIT 1`,
+		imdbCode, season, episode).Scan(&runtime)
+	return runtime
+}
+
+// ListSubtitleReleaseHints describes the releases we can play for a movie
+// (season and episode 0) or episode: the quality, source, codec and group of
+// its torrents and the names of its local files
+func (d *DB) ListSubtitleReleaseHints(imdbCode string, season, episode int) ([]string, error) {
+	var hints []string
+	var err error
+	if season == 0 && episode == 0 {
+		err = d.Raw(`SELECT quality || ' ' || type || ' ' || COALESCE(video_codec, '') FROM torrents
+			JOIN movies ON movies.id = torrents.movie_id WHERE movies.imdb_code = ?
+			UNION ALL
+			SELECT file_name FROM local_files JOIN movies ON movies.id = local_files.movie_id WHERE movies.imdb_code = ?`,
+			imdbCode, imdbCode).Scan(&hints).Error
+		return hints, err
+	}
+	err = d.Raw(`SELECT episode_torrents.quality || ' ' || COALESCE(episode_torrents.video_codec, '') || ' ' || COALESCE(episode_torrents.release_group, '') FROM episode_torrents
+		JOIN series ON series.id = episode_torrents.series_id
+		WHERE series.imdb_code = ? AND episode_torrents.season_number = ? AND episode_torrents.episode_number = ?
+		UNION ALL
+		SELECT season_packs.quality FROM season_packs
+		JOIN series ON series.id = season_packs.series_id
+		WHERE series.imdb_code = ? AND season_packs.season_number = ?
+		UNION ALL
+		SELECT local_files.file_name FROM local_files
+		JOIN episodes ON episodes.id = local_files.episode_id
+		JOIN series ON series.id = episodes.series_id
+		WHERE series.imdb_code = ? AND episodes.season_number = ? AND episodes.episode_number = ?`,
+		imdbCode, season, episode, imdbCode, season, imdbCode, season, episode).Scan(&hints).Error
+	return hints, err
+}
+
 func (d *DB) GetSubtitlesWithContent() ([]models.StoredSubtitle, error) {
 	var subs []models.StoredSubtitle
 	err := d.Select("id, imdb_code, vtt_content").
@@ -95,3 +190,74 @@ func (d *DB) CountSubtitlesByIMDBEpisode(imdbCode string, season, episode int) (
 		Count(&count).Error
 	return int(count), err
 }
+
+func (d *DB) ListSubtitleProviderSettings() ([]models.SubtitleProviderSetting, error) {
+	var settings []models.SubtitleProviderSetting
+	err := d.Find(&settings).Error
+	return settings, err
+}
+
+func (d *DB) SaveSubtitleProviderSetting(s *models.SubtitleProviderSetting) error {
+	return d.Save(s).Error
+}
+
+func (d *DB) ListSubtitleLanguageSettings() ([]models.SubtitleLanguageSetting, error) {
+	var settings []models.SubtitleLanguageSetting
+	err := d.Find(&settings).Error
+	return settings, err
+}
+
+func (d *DB) SaveSubtitleLanguageSetting(s *models.SubtitleLanguageSetting) error {
+	return d.Save(s).Error
+}
+
+// ListSubtitleReleases returns the language and release name of the subtitles
+// stored for one movie (season and episode 0) or episode
+func (d *DB) ListSubtitleReleases(imdbCode string, season, episode int) ([]models.StoredSubtitle, error) {
+	var subs []models.StoredSubtitle
+	err := d.Select("id, language, release_name").
+		Where("imdb_code = ? AND season_number = ? AND episode_number = ?", imdbCode, season, episode).
+		Find(&subs).Error
+	return subs, err
+}
+
+// ListSubtitleTargets returns every movie and episode that can be played:
+// movies with torrents or local files, and episodes with torrents, a season
+// pack or local files
+func (d *DB) ListSubtitleTargets() ([]models.SubtitleTarget, error) {
+	var movies []models.SubtitleTarget
+	err := d.Raw(`SELECT imdb_code, title, 0 AS season_number, 0 AS episode_number FROM movies
+		WHERE imdb_code != ''
+		AND (EXISTS (SELECT 1 FROM torrents WHERE torrents.movie_id = movies.id)
+			OR EXISTS (SELECT 1 FROM local_files WHERE local_files.movie_id = movies.id))
+		ORDER BY movies.id`).Scan(&movies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var episodes []models.SubtitleTarget
+	err = d.Raw(`SELECT series.imdb_code, series.title, episodes.season_number, episodes.episode_number FROM episodes
+		JOIN series ON series.id = episodes.series_id
+		WHERE series.imdb_code != '' AND episodes.season_number > 0 AND episodes.episode_number > 0
+		AND (EXISTS (SELECT 1 FROM episode_torrents WHERE episode_torrents.episode_id = episodes.id)
+			OR EXISTS (SELECT 1 FROM season_packs WHERE season_packs.series_id = episodes.series_id AND season_packs.season_number = episodes.season_number)
+			OR EXISTS (SELECT 1 FROM local_files WHERE local_files.episode_id = episodes.id))
+		ORDER BY series.id, episodes.season_number, episodes.episode_number`).Scan(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(movies, episodes...), nil
+}
+
+// removeSubtitleFiles deletes the VTT and original files of subtitle rows
+// that were dropped from the database
+func removeSubtitleFiles(subs []models.StoredSubtitle) {
+	for _, sub := range subs {
+		if sub.VTTPath != "" {
+			os.Remove(sub.VTTPath)
+		}
+		if sub.OriginalPath != "" {
+			os.Remove(sub.OriginalPath)
+		}
+	}
+}
@@ -412,7 +412,7 @@ export async function getIPTVSyncStatus() {
 }
 
 export async function getChannelStats() {
-  return request<{ channels: number; countries: number; categories: number; with_streams: number }>(
+  return request<{ channels: number; countries: number; categories: number; with_streams: number; blocklisted: number; disabled: number }>(
     `${API_BASE}/channels/stats`
   );
 }
@@ -444,7 +444,10 @@ export async function getHealthCheckStatus() {
     phase: string;
     total: number;
     checked: number;
-    removed: number;
+    healthy: number;
+    failed: number;
+    disabled: number;
+    restored: number;
     started_at?: string;
     completed_at?: string;
     last_error?: string;
@@ -527,3 +530,46 @@ export async function syncSubtitles(imdbCode: string, languages = 'en,sq,es,fr,d
 export async function logout() {
   window.location.href = '/admin/logout';
 }
+
+// Background jobs
+export interface JobProgress {
+  total: number;
+  processed: number;
+  added: number;
+  failed: number;
+  current?: string;
+  extra?: Record<string, number>;
+}
+
+export interface JobRunStatus {
+  run: {
+    id: number;
+    job: string;
+    status: 'running' | 'success' | 'failed' | 'cancelled' | 'interrupted';
+    error?: string;
+  };
+  running: boolean;
+  progress: JobProgress;
+}
+
+export async function getJobRun(id: number) {
+  return request<JobRunStatus>(`${API_BASE}/jobs/runs/${id}`);
+}
+
+export async function cancelJobRun(id: number) {
+  return request<{ status: string }>(`${API_BASE}/jobs/runs/${id}/cancel`, { method: 'POST' });
+}
+
+// Polls a job run until it finishes, reporting progress along the way
+export async function waitForJobRun(
+  id: number,
+  onProgress?: (status: JobRunStatus) => void,
+  intervalMs = 2000,
+): Promise<JobRunStatus> {
+  for (;;) {
+    const status = await getJobRun(id);
+    onProgress?.(status);
+    if (!status.running) return status;
+    await new Promise((resolve) => setTimeout(resolve, intervalMs));
+  }
+}
@@ -14,6 +14,7 @@ import (
 type APIHandler struct {
 	db          *database.DB
 	syncService *services.SyncService
+	images      *ImageRewriter
 }
 
 func NewAPIHandler(db *database.DB) *APIHandler {
@@ -25,6 +26,11 @@ func (h *APIHandler) SetSyncService(s *services.SyncService) {
 	h.syncService = s
 }
 
+// SetImageRewriter enables rewriting image URLs to the local image proxy.
+func (h *APIHandler) SetImageRewriter(p *ImageRewriter) {
+	h.images = p
+}
+
 // ListMovies handles GET /api/v2/list_movies.json
 func (h *APIHandler) ListMovies(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
@@ -42,6 +48,8 @@ func (h *APIHandler) ListMovies(w http.ResponseWriter, r *http.Request) {
 		MinimumYear:   parseInt(q.Get("minimum_year"), 0),
 		MaximumYear:   parseInt(q.Get("maximum_year"), 0),
 		Status:        q.Get("status"), // "available", "coming_soon", or "" for all
+		PersonID:      uint(parseInt(q.Get("person_id"), 0)),
+		PersonRole:    q.Get("person_role"),
 	}
 
 	movies, totalCount, err := h.db.ListMovies(filter)
@@ -60,6 +68,8 @@ func (h *APIHandler) ListMovies(w http.ResponseWriter, r *http.Request) {
 	if movies == nil {
 		movies = []models.Movie{}
 	}
+	localizeMovies(h.db, movies, requestLang(r))
+	h.images.Movies(r, movies)
 
 	data := models.MovieListData{
 		MovieCount: totalCount,
@@ -96,6 +106,8 @@ func (h *APIHandler) SearchOnline(w http.ResponseWriter, r *http.Request) {
 	if movies == nil {
 		movies = []models.Movie{}
 	}
+	localizeMovies(h.db, movies, requestLang(r))
+	h.images.Movies(r, movies)
 
 	data := map[string]interface{}{
 		"movie_count":  totalCount,
@@ -134,8 +146,12 @@ func (h *APIHandler) MovieDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	movies := []models.Movie{*movie}
+	localizeMovies(h.db, movies, requestLang(r))
+	h.images.Movies(r, movies)
+
 	data := models.MovieDetailsData{
-		Movie: *movie,
+		Movie: movies[0],
 	}
 
 	writeSuccess(w, data)
@@ -211,12 +227,13 @@ func toHeroMovie(m models.Movie) HomeMovieHero {
 // Home handles GET /api/v2/home.json - returns home page content
 func (h *APIHandler) Home(w http.ResponseWriter, r *http.Request) {
 	var sections []HomeSectionResponse
+	lang := requestLang(r)
 
 	// Get configured home sections from database
 	dbSections, err := h.db.ListHomeSections(false)
 	if err != nil || len(dbSections) == 0 {
 		// Fallback to default sections if none configured
-		sections = h.getDefaultHomeSections()
+		sections = h.getDefaultHomeSections(r, lang)
 	} else {
 		// Build sections from database config
 		for _, s := range dbSections {
@@ -274,6 +291,8 @@ func (h *APIHandler) Home(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if len(movies) > 0 {
+				localizeMovies(h.db, movies, lang)
+				h.images.Movies(r, movies)
 				sections = append(sections, HomeSectionResponse{
 					ID:          s.SectionID,
 					Title:       s.Title,
@@ -286,30 +305,34 @@ func (h *APIHandler) Home(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build hero slider from all hero/banner sections
-	var heroSlider []HomeMovieHero
+	var heroMovies []models.Movie
 	for _, s := range dbSections {
 		if (s.DisplayType == "hero" || s.DisplayType == "banner" || s.DisplayType == "slider") && s.ContentID != nil {
 			if s.ContentType == "movie" {
 				movie, err := h.db.GetMovie(*s.ContentID)
 				if err == nil && movie != nil {
-					heroSlider = append(heroSlider, toHeroMovie(*movie))
+					heroMovies = append(heroMovies, *movie)
 				}
 			}
 		}
 	}
 
 	// If no hero movies configured, use top 5 rated
-	if len(heroSlider) == 0 {
-		topMovies, _, _ := h.db.ListMovies(database.MovieFilter{
+	if len(heroMovies) == 0 {
+		heroMovies, _, _ = h.db.ListMovies(database.MovieFilter{
 			Limit:         5,
 			Page:          1,
 			MinimumRating: 8.0,
 			SortBy:        "rating",
 			OrderBy:       "desc",
 		})
-		for _, m := range topMovies {
-			heroSlider = append(heroSlider, toHeroMovie(m))
-		}
+	}
+
+	localizeMovies(h.db, heroMovies, lang)
+	h.images.Movies(r, heroMovies)
+	var heroSlider []HomeMovieHero
+	for _, m := range heroMovies {
+		heroSlider = append(heroSlider, toHeroMovie(m))
 	}
 
 	writeSuccess(w, map[string]interface{}{
@@ -319,7 +342,7 @@ func (h *APIHandler) Home(w http.ResponseWriter, r *http.Request) {
 }
 
 // getDefaultHomeSections returns fallback sections when none are configured
-func (h *APIHandler) getDefaultHomeSections() []HomeSectionResponse {
+func (h *APIHandler) getDefaultHomeSections(r *http.Request, lang string) []HomeSectionResponse {
 	var sections []HomeSectionResponse
 
 	// Recently Added
@@ -327,6 +350,8 @@ func (h *APIHandler) getDefaultHomeSections() []HomeSectionResponse {
 		Limit: 10, Page: 1, SortBy: "date_uploaded", OrderBy: "desc",
 	})
 	if len(recentMovies) > 0 {
+		localizeMovies(h.db, recentMovies, lang)
+		h.images.Movies(r, recentMovies)
 		sections = append(sections, HomeSectionResponse{ID: "recently_added", Title: "Recently Added", Type: "recent", DisplayType: "carousel", Movies: toSlimMovies(recentMovies)})
 	}
 
@@ -335,6 +360,8 @@ func (h *APIHandler) getDefaultHomeSections() []HomeSectionResponse {
 		Limit: 10, Page: 1, MinimumRating: 7.0, SortBy: "rating", OrderBy: "desc",
 	})
 	if len(topRated) > 0 {
+		localizeMovies(h.db, topRated, lang)
+		h.images.Movies(r, topRated)
 		sections = append(sections, HomeSectionResponse{ID: "top_rated", Title: "Top Rated", Type: "top_rated", DisplayType: "carousel", Movies: toSlimMovies(topRated)})
 	}
 
@@ -343,6 +370,8 @@ func (h *APIHandler) getDefaultHomeSections() []HomeSectionResponse {
 	for _, list := range curatedLists {
 		movies, _ := h.db.GetCuratedListMovies(&list)
 		if len(movies) > 0 {
+			localizeMovies(h.db, movies, lang)
+			h.images.Movies(r, movies)
 			sections = append(sections, HomeSectionResponse{ID: "curated_" + list.Slug, Title: list.Name, Type: "curated_list", DisplayType: "carousel", Movies: toSlimMovies(movies)})
 		}
 	}
@@ -364,6 +393,7 @@ func (h *APIHandler) MovieSuggestions(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		movies = []models.Movie{}
 	}
+	h.images.Movies(r, movies)
 
 	data := models.MovieSuggestionsData{
 		MovieCount: len(movies),
@@ -405,6 +435,7 @@ func (h *APIHandler) FranchiseMovies(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		movies = []models.Movie{}
 	}
+	h.images.Movies(r, movies)
 
 	data := models.MovieSuggestionsData{
 		MovieCount: len(movies),
@@ -505,6 +536,7 @@ func (h *APIHandler) UnifiedSearch(w http.ResponseWriter, r *http.Request) {
 	if limit > 50 {
 		limit = 50
 	}
+	lang := requestLang(r)
 
 	// Search movies
 	movies, _, _ := h.db.ListMovies(database.MovieFilter{
@@ -516,6 +548,25 @@ func (h *APIHandler) UnifiedSearch(w http.ResponseWriter, r *http.Request) {
 		movies = []models.Movie{}
 	}
 
+	// Also match translated titles in the requested language
+	if lang != "" && len(movies) < limit {
+		ids, _ := h.db.SearchLocalizedContent("movie", lang, query, limit)
+		seen := make(map[uint]bool, len(movies))
+		for _, m := range movies {
+			seen[m.ID] = true
+		}
+		for _, id := range ids {
+			if seen[id] || len(movies) >= limit {
+				continue
+			}
+			if m, err := h.db.GetMovie(id); err == nil && m != nil {
+				movies = append(movies, *m)
+			}
+		}
+	}
+	localizeMovies(h.db, movies, lang)
+	h.images.Movies(r, movies)
+
 	// Search series (in-memory case-insensitive match below)
 	series, _, _ := h.db.ListSeries(database.SeriesFilter{Limit: limit, Page: 1})
 	var matchedSeries []models.Series
@@ -528,9 +579,26 @@ func (h *APIHandler) UnifiedSearch(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	if lang != "" && len(matchedSeries) < limit {
+		ids, _ := h.db.SearchLocalizedContent("series", lang, query, limit)
+		seen := make(map[uint]bool, len(matchedSeries))
+		for _, s := range matchedSeries {
+			seen[s.ID] = true
+		}
+		for _, id := range ids {
+			if seen[id] || len(matchedSeries) >= limit {
+				continue
+			}
+			if s, err := h.db.GetSeries(id); err == nil && s != nil {
+				matchedSeries = append(matchedSeries, *s)
+			}
+		}
+	}
 	if matchedSeries == nil {
 		matchedSeries = []models.Series{}
 	}
+	localizeSeries(h.db, matchedSeries, lang)
+	h.images.Series(r, matchedSeries)
 
 	// Search channels
 	channels, _, _ := h.db.ListChannels(database.ChannelFilter{
@@ -541,6 +609,7 @@ func (h *APIHandler) UnifiedSearch(w http.ResponseWriter, r *http.Request) {
 	if channels == nil {
 		channels = []models.Channel{}
 	}
+	h.images.Channels(r, channels)
 
 	response := UnifiedSearchResponse{
 		Query:    query,
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"torrent-server/models"
+)
+
+// maxAvailabilityWait caps how long an events request may long-poll
+const maxAvailabilityWait = 60
+
+type availabilityWatchRequest struct {
+	DeviceID  string   `json:"device_id"`
+	ImdbCodes []string `json:"imdb_codes"`
+}
+
+func decodeAvailabilityWatch(w http.ResponseWriter, r *http.Request) (*availabilityWatchRequest, bool) {
+	var req availabilityWatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body")
+		return nil, false
+	}
+	if req.DeviceID == "" {
+		writeError(w, "device_id is required")
+		return nil, false
+	}
+	var codes []string
+	for _, code := range req.ImdbCodes {
+		code = strings.TrimSpace(code)
+		if strings.HasPrefix(code, "tt") {
+			codes = append(codes, code)
+		}
+	}
+	req.ImdbCodes = codes
+	return &req, true
+}
+
+// WatchAvailability handles POST /api/v2/availability/watch
+// Registers a device's interest in coming-soon titles
+func (h *APIHandler) WatchAvailability(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAvailabilityWatch(w, r)
+	if !ok {
+		return
+	}
+	if len(req.ImdbCodes) == 0 {
+		writeError(w, "imdb_codes must contain at least one IMDb code")
+		return
+	}
+
+	added, err := h.db.AddAvailabilityWatches(req.DeviceID, req.ImdbCodes)
+	if err != nil {
+		writeError(w, "Failed to register interest")
+		return
+	}
+	watches, _ := h.db.ListAvailabilityWatches(req.DeviceID)
+
+	writeSuccess(w, map[string]interface{}{
+		"device_id":   req.DeviceID,
+		"added":       added,
+		"watch_count": len(watches),
+	})
+}
+
+// UnwatchAvailability handles POST /api/v2/availability/unwatch
+// Without imdb_codes every watch of the device is removed
+func (h *APIHandler) UnwatchAvailability(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAvailabilityWatch(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := h.db.RemoveAvailabilityWatches(req.DeviceID, req.ImdbCodes)
+	if err != nil {
+		writeError(w, "Failed to remove interest")
+		return
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"device_id": req.DeviceID,
+		"removed":   removed,
+	})
+}
+
+// AvailabilityWatches handles GET /api/v2/availability/watches.json
+func (h *APIHandler) AvailabilityWatches(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.URL.Query().Get("device_id")
+	if deviceID == "" {
+		writeError(w, "device_id parameter is required")
+		return
+	}
+
+	watches, err := h.db.ListAvailabilityWatches(deviceID)
+	if err != nil {
+		writeError(w, "Failed to list watches")
+		return
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"device_id":   deviceID,
+		"watch_count": len(watches),
+		"watches":     watches,
+	})
+}
+
+// AvailabilityEvents handles GET /api/v2/availability/events.json
+// Returns availability events for the titles a device watches. With wait,
+// the request blocks up to that many seconds until an event arrives.
+func (h *APIHandler) AvailabilityEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	deviceID := q.Get("device_id")
+	if deviceID == "" {
+		writeError(w, "device_id parameter is required")
+		return
+	}
+	since := uint(parseInt(q.Get("since"), 0))
+	limit := parseInt(q.Get("limit"), 50)
+	wait := parseInt(q.Get("wait"), 0)
+	if wait > maxAvailabilityWait {
+		wait = maxAvailabilityWait
+	}
+
+	var deadline <-chan time.Time
+	if wait > 0 && h.syncService != nil {
+		timer := time.NewTimer(time.Duration(wait) * time.Second)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var events []models.AvailabilityEvent
+	for {
+		// Subscribe before querying so an event recorded in between isn't missed
+		var updates <-chan struct{}
+		if deadline != nil {
+			updates = h.syncService.AvailabilityUpdates()
+		}
+
+		var err error
+		events, err = h.db.ListDeviceAvailabilityEvents(deviceID, since, limit)
+		if err != nil {
+			writeError(w, "Failed to list events")
+			return
+		}
+		if len(events) > 0 || deadline == nil {
+			break
+		}
+
+		select {
+		case <-updates:
+			continue
+		case <-deadline:
+		case <-r.Context().Done():
+			return
+		}
+		break
+	}
+
+	next := since
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+
+	writeSuccess(w, models.AvailabilityEventsData{
+		DeviceID:   deviceID,
+		NextSince:  next,
+		EventCount: len(events),
+		Events:     events,
+	})
+}
+
+// AdminAvailabilityEvents handles GET /admin/api/availability/events
+func (h *APIHandler) AdminAvailabilityEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := h.db.ListAvailabilityEvents(parseInt(r.URL.Query().Get("limit"), 100))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
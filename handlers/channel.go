@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -15,6 +19,7 @@ type ChannelHandler struct {
 	db            *database.DB
 	iptvService   *services.IPTVSyncService
 	healthService *services.ChannelHealthService
+	images        *ImageRewriter
 }
 
 func NewChannelHandler(db *database.DB) *ChannelHandler {
@@ -25,6 +30,21 @@ func NewChannelHandler(db *database.DB) *ChannelHandler {
 	}
 }
 
+// IPTVService returns the sync service shared by the admin endpoints and scheduled jobs
+func (h *ChannelHandler) IPTVService() *services.IPTVSyncService {
+	return h.iptvService
+}
+
+// HealthService returns the health checker shared by the admin endpoints and scheduled jobs
+func (h *ChannelHandler) HealthService() *services.ChannelHealthService {
+	return h.healthService
+}
+
+// SetImageRewriter enables rewriting channel logos to the local image proxy.
+func (h *ChannelHandler) SetImageRewriter(p *ImageRewriter) {
+	h.images = p
+}
+
 // ListChannels handles GET /api/v2/list_channels.json
 func (h *ChannelHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
@@ -35,6 +55,7 @@ func (h *ChannelHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
 		Country:   q.Get("country"),
 		Category:  q.Get("category"),
 		QueryTerm: q.Get("query_term"),
+		Disabled:  q.Get("disabled"),
 	}
 
 	channels, totalCount, err := h.db.ListChannels(filter)
@@ -46,6 +67,7 @@ func (h *ChannelHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
 	if channels == nil {
 		channels = []models.Channel{}
 	}
+	h.images.Channels(r, channels)
 
 	data := map[string]interface{}{
 		"channel_count": totalCount,
@@ -71,8 +93,11 @@ func (h *ChannelHandler) GetChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	list := []models.Channel{*channel}
+	h.images.Channels(r, list)
+
 	writeSuccess(w, map[string]interface{}{
-		"channel": channel,
+		"channel": list[0],
 	})
 }
 
@@ -130,6 +155,7 @@ func (h *ChannelHandler) GetChannelsByCountry(w http.ResponseWriter, r *http.Req
 	if channels == nil {
 		channels = []models.Channel{}
 	}
+	h.images.Channels(r, channels)
 
 	writeSuccess(w, map[string]interface{}{
 		"channels": channels,
@@ -159,10 +185,94 @@ func (h *ChannelHandler) GetEPG(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// exportFilter reads the playlist and guide filters; NSFW channels are left
+// out unless nsfw=include or nsfw=only
+func exportFilter(r *http.Request) database.ChannelFilter {
+	q := r.URL.Query()
+	filter := database.ChannelFilter{
+		Country:  q.Get("country"),
+		Category: q.Get("category"),
+		Language: q.Get("language"),
+		NSFW:     "exclude",
+	}
+	switch q.Get("nsfw") {
+	case "include":
+		filter.NSFW = ""
+	case "only":
+		filter.NSFW = "only"
+	}
+	return filter
+}
+
+// baseURL returns the public URL of the server for links in exports
+func (h *ChannelHandler) baseURL(r *http.Request) string {
+	if h.images != nil {
+		return h.images.base(r)
+	}
+	return requestBaseURL(r)
+}
+
+// ExportM3U handles GET /api/v2/channels.m3u?country=&category=&language=&nsfw=
+func (h *ChannelHandler) ExportM3U(w http.ResponseWriter, r *http.Request) {
+	channels, err := h.db.ListChannelsForExport(exportFilter(r))
+	if err != nil {
+		http.Error(w, "Failed to fetch channels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.images.Channels(r, channels)
+
+	categoryNames := make(map[string]string)
+	if cats, err := h.db.ListChannelCategories(); err == nil {
+		for _, c := range cats {
+			categoryNames[c.ID] = c.Name
+		}
+	}
+
+	epgURL := h.baseURL(r) + "/api/v2/epg.xml"
+	if r.URL.RawQuery != "" {
+		epgURL += "?" + r.URL.RawQuery
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="channels.m3u"`)
+	if err := services.WriteM3U(w, channels, categoryNames, epgURL); err != nil {
+		log.Printf("[ChannelHandler] Failed to write playlist: %v", err)
+	}
+}
+
+// ExportEPG handles GET /api/v2/epg.xml?country=&category=&language=&nsfw=&days=
+// It takes the same filters as ExportM3U so a playlist and its guide match.
+func (h *ChannelHandler) ExportEPG(w http.ResponseWriter, r *http.Request) {
+	filter := exportFilter(r)
+	days := parseInt(r.URL.Query().Get("days"), 3)
+	if days < 1 || days > 14 {
+		days = 3
+	}
+
+	channels, err := h.db.ListChannelsForExport(filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch channels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	programmes, err := h.db.ListEPGForExport(filter, now, now.AddDate(0, 0, days))
+	if err != nil {
+		http.Error(w, "Failed to fetch EPG: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.images.Channels(r, channels)
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if err := services.WriteXMLTV(w, channels, programmes); err != nil {
+		log.Printf("[ChannelHandler] Failed to write guide: %v", err)
+	}
+}
+
 // --- Admin endpoints ---
 
 // SyncIPTV handles POST /admin/api/channels/sync
-// Accepts optional JSON body: { "m3u_url": "https://..." }
+// Accepts optional JSON body: { "m3u_url": "https://..." }. Without a URL
+// every enabled source is synced; a new URL is added as a source.
 func (h *ChannelHandler) SyncIPTV(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		M3UURL string `json:"m3u_url"`
@@ -183,11 +293,31 @@ func (h *ChannelHandler) SyncIPTV(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "ok",
 		"message": "IPTV sync started from M3U",
-		"m3u_url": services.IPTVM3UURL,
+		"m3u_url": req.M3UURL,
 	})
 }
 
+// primarySource returns the first source created, which the single-URL
+// settings endpoints read and change
+func (h *ChannelHandler) primarySource() (*models.IPTVSource, error) {
+	sources, err := h.iptvService.Sources()
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no IPTV sources configured")
+	}
+	primary := sources[0]
+	for _, src := range sources {
+		if src.ID < primary.ID {
+			primary = src
+		}
+	}
+	return &primary, nil
+}
+
 // UpdateM3UURL handles PUT /admin/api/channels/settings
+// Changes the URL of the first source; see /admin/api/channels/sources
 func (h *ChannelHandler) UpdateM3UURL(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		M3UURL string `json:"m3u_url"`
@@ -197,20 +327,129 @@ func (h *ChannelHandler) UpdateM3UURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	services.IPTVM3UURL = req.M3UURL
+	primary, err := h.primarySource()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	src, err := h.iptvService.UpdateSource(primary.ID, services.IPTVSourceUpdate{URL: &req.M3UURL})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "ok",
-		"m3u_url": services.IPTVM3UURL,
+		"m3u_url": src.URL,
 	})
 }
 
 // GetChannelSettings handles GET /admin/api/channels/settings
 func (h *ChannelHandler) GetChannelSettings(w http.ResponseWriter, r *http.Request) {
+	m3uURL := ""
+	if primary, err := h.primarySource(); err == nil {
+		m3uURL = primary.URL
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"m3u_url": services.IPTVM3UURL,
+		"m3u_url": m3uURL,
+	})
+}
+
+// ListSources handles GET /admin/api/channels/sources
+func (h *ChannelHandler) ListSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := h.iptvService.Sources()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sources == nil {
+		sources = []models.IPTVSource{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sources)
+}
+
+// CreateSource handles POST /admin/api/channels/sources
+func (h *ChannelHandler) CreateSource(w http.ResponseWriter, r *http.Request) {
+	var src models.IPTVSource
+	if err := json.NewDecoder(r.Body).Decode(&src); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.iptvService.CreateSource(&src); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(src)
+}
+
+// UpdateSource handles PUT /admin/api/channels/sources/{id}
+func (h *ChannelHandler) UpdateSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req services.IPTVSourceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	src, err := h.iptvService.UpdateSource(uint(id), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(src)
+}
+
+// DeleteSource handles DELETE /admin/api/channels/sources/{id}
+// The source's channels are removed with it.
+func (h *ChannelHandler) DeleteSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := h.iptvService.DeleteSource(uint(id)); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// SyncSource handles POST /admin/api/channels/sources/{id}/sync
+func (h *ChannelHandler) SyncSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.iptvService.SyncSource(uint(id)); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"message": "IPTV source sync started",
 	})
 }
 
@@ -259,6 +498,51 @@ func (h *ChannelHandler) GetHealthCheckStatus(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(status)
 }
 
+// GetChannelHealth handles GET /admin/api/channels/{id}/health?limit=50
+// Returns the channel's health state and its most recent checks
+func (h *ChannelHandler) GetChannelHealth(w http.ResponseWriter, r *http.Request) {
+	channel, err := h.db.GetChannel(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+	history, err := h.db.ListChannelHealthChecks(channel.ID, parseInt(r.URL.Query().Get("limit"), 50))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []models.ChannelHealthCheck{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": channel,
+		"history": history,
+	})
+}
+
+// CheckChannelHealth handles POST /admin/api/channels/{id}/health-check
+// Checks one channel now; a passing check restores a disabled channel
+func (h *ChannelHandler) CheckChannelHealth(w http.ResponseWriter, r *http.Request) {
+	check, err := h.healthService.CheckChannel(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	channel, err := h.db.GetChannel(check.ChannelID)
+	if err != nil {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": channel,
+		"check":   check,
+	})
+}
+
 // ClearBlocklist handles DELETE /admin/api/channels/blocklist
 func (h *ChannelHandler) ClearBlocklist(w http.ResponseWriter, r *http.Request) {
 	if err := h.db.ClearBlocklist(); err != nil {
@@ -2,22 +2,40 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 
 	"torrent-server/database"
 	"torrent-server/models"
+	"torrent-server/services"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// listImportJob is the scheduler job that imports uploaded list files
+const listImportJob = "list_import"
+
 type CuratedHandler struct {
-	db *database.DB
+	db          *database.DB
+	syncService *services.SyncService
+	scheduler   *services.Scheduler
 }
 
 func NewCuratedHandler(db *database.DB) *CuratedHandler {
 	return &CuratedHandler{db: db}
 }
 
+func (h *CuratedHandler) SetSyncService(s *services.SyncService) {
+	h.syncService = s
+}
+
+// SetScheduler lets list imports run as the list_import job
+func (h *CuratedHandler) SetScheduler(s *services.Scheduler) {
+	h.scheduler = s
+}
+
 // ListCuratedLists handles GET /api/v2/curated_lists.json
 func (h *CuratedHandler) ListCuratedLists(w http.ResponseWriter, r *http.Request) {
 	lists, err := h.db.ListCuratedLists(false)
@@ -167,3 +185,128 @@ func (h *CuratedHandler) AdminRemoveMovieFromList(w http.ResponseWriter, r *http
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// AdminImportList handles POST /admin/api/curated/import
+// Multipart form: file (IMDb CSV, Letterboxd CSV or Trakt JSON export),
+// format (auto|imdb|letterboxd|trakt), and either list_id to update an
+// existing list or name to create one. replace=true swaps the list's movies
+// for the imported ones. The import runs as the list_import job.
+func (h *CuratedHandler) AdminImportList(w http.ResponseWriter, r *http.Request) {
+	if h.syncService == nil || h.scheduler == nil {
+		http.Error(w, "Sync service not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(r.FormValue("format"))
+	if format == "auto" {
+		format = ""
+	}
+	entries, format, err := services.ParseListExport(format, data)
+	if err != nil {
+		http.Error(w, "Failed to parse list: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var list *models.CuratedList
+	created := false
+	if listID := r.FormValue("list_id"); listID != "" {
+		list, err = h.db.GetCuratedList(listID)
+		if err != nil {
+			http.Error(w, "Curated list not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "list_id or name is required", http.StatusBadRequest)
+			return
+		}
+		list = &models.CuratedList{
+			Name:        name,
+			Description: r.FormValue("description"),
+			SortBy:      "rating",
+			OrderBy:     "desc",
+			LimitCount:  50,
+			IsActive:    true,
+		}
+		if err := h.db.CreateCuratedList(list); err != nil {
+			http.Error(w, "Failed to create curated list: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		created = true
+	}
+
+	imp := &services.ListImport{
+		ListID:  list.ID,
+		Format:  format,
+		Replace: r.FormValue("replace") == "true",
+		Entries: entries,
+	}
+	err = h.syncService.QueueListImport(imp)
+	var run *models.JobRun
+	if err == nil {
+		if run, err = h.scheduler.Trigger(listImportJob); err != nil {
+			h.syncService.DropListImport(imp)
+		}
+	}
+	if err != nil {
+		if created {
+			h.db.DeleteCuratedList(list.ID)
+		}
+		if errors.Is(err, services.ErrListImportPending) || errors.Is(err, services.ErrJobRunning) {
+			http.Error(w, services.ErrListImportPending.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to start import: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"list":   list,
+		"format": format,
+		"total":  len(entries),
+		"job_id": run.ID,
+	})
+}
+
+// AdminGetImportReport handles GET /admin/api/curated/{id}/import
+// Returns the report of the last import into the list
+func (h *CuratedHandler) AdminGetImportReport(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.GetCuratedList(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Curated list not found", http.StatusNotFound)
+		return
+	}
+	var report *services.ListImportReport
+	if h.syncService != nil {
+		report = h.syncService.ListImportReport(list.ID)
+	}
+	if report == nil {
+		http.Error(w, "No import report for this list", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"list":   list,
+		"report": report,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"torrent-server/database"
+	"torrent-server/models"
+	"torrent-server/services"
+)
+
+type DuplicatesHandler struct {
+	db *database.DB
+}
+
+func NewDuplicatesHandler(db *database.DB) *DuplicatesHandler {
+	return &DuplicatesHandler{db: db}
+}
+
+// ListDuplicates handles GET /admin/api/duplicates?min_similarity=0.85
+func (h *DuplicatesHandler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	minSimilarity := float64(parseFloat(r.URL.Query().Get("min_similarity"), services.DefaultDuplicateSimilarity))
+
+	pairs, err := services.FindDuplicates(h.db, minSimilarity)
+	if err != nil {
+		http.Error(w, "Failed to find duplicates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"duplicates": pairs,
+		"count":      len(pairs),
+	})
+}
+
+// MergeDuplicates handles POST /admin/api/duplicates/merge
+// Body: {"survivor_id": 12, "duplicate_id": 40, "survivor_type": "movie"}
+// The duplicate is always a movie; survivor_type "series" folds a series
+// stored as a movie into the real series entry.
+func (h *DuplicatesHandler) MergeDuplicates(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SurvivorID   uint   `json:"survivor_id"`
+		DuplicateID  uint   `json:"duplicate_id"`
+		SurvivorType string `json:"survivor_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SurvivorID == 0 || req.DuplicateID == 0 {
+		http.Error(w, "survivor_id and duplicate_id are required", http.StatusBadRequest)
+		return
+	}
+
+	var result *models.MergeResult
+	var err error
+	switch req.SurvivorType {
+	case "", "movie":
+		result, err = h.db.MergeMovies(req.SurvivorID, req.DuplicateID)
+	case "series":
+		result, err = h.db.MergeMovieIntoSeries(req.DuplicateID, req.SurvivorID)
+	default:
+		http.Error(w, "survivor_type must be movie or series", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Merge failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[Duplicates] Merged movie %d into %s %d: %+v", req.DuplicateID, req.SurvivorType, req.SurvivorID, *result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"merged": result,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"torrent-server/models"
+	"torrent-server/services"
+)
+
+type ImageHandler struct {
+	images *services.ImageService
+}
+
+func NewImageHandler(images *services.ImageService) *ImageHandler {
+	return &ImageHandler{images: images}
+}
+
+// Serve handles GET /img/{preset}/{sig}/{key}
+func (h *ImageHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	preset := chi.URLParam(r, "preset")
+	if _, ok := services.ImagePresets[preset]; !ok {
+		http.Error(w, "Unknown image size", http.StatusNotFound)
+		return
+	}
+	src, err := h.images.Decode(chi.URLParam(r, "sig"), chi.URLParam(r, "key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	img, err := h.images.Get(src, preset)
+	if err != nil {
+		if !errors.Is(err, services.ErrImageFailedBefore) {
+			log.Printf("[Images] Failed to fetch %s: %v", src, err)
+		}
+		http.Error(w, "Image unavailable", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("ETag", img.ETag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, img.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	f, err := os.Open(img.Path)
+	if err != nil {
+		http.Error(w, "Image unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", img.ContentType)
+	http.ServeContent(w, r, "", img.ModTime, f)
+}
+
+// ImageRewriter points image URLs in API responses at the local /img proxy.
+// A nil rewriter leaves URLs untouched, so handlers can call it unconditionally.
+type ImageRewriter struct {
+	images    *services.ImageService
+	publicURL string
+}
+
+func NewImageRewriter(images *services.ImageService, publicURL string) *ImageRewriter {
+	return &ImageRewriter{images: images, publicURL: strings.TrimRight(publicURL, "/")}
+}
+
+// base returns the configured public URL, or one derived from the request
+func (p *ImageRewriter) base(r *http.Request) string {
+	if p.publicURL != "" {
+		return p.publicURL
+	}
+	return requestBaseURL(r)
+}
+
+// requestBaseURL returns the scheme and host the request was made to,
+// honouring X-Forwarded-Proto from a reverse proxy
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// Movies rewrites cover, background and gallery images in place
+func (p *ImageRewriter) Movies(r *http.Request, movies []models.Movie) {
+	if p == nil || len(movies) == 0 {
+		return
+	}
+	base := p.base(r)
+	for i := range movies {
+		m := &movies[i]
+		m.SmallCoverImage = p.images.ProxyURL(base, m.SmallCoverImage, "w185")
+		m.MediumCoverImage = p.images.ProxyURL(base, m.MediumCoverImage, "w342")
+		m.LargeCoverImage = p.images.ProxyURL(base, m.LargeCoverImage, "w500")
+		m.BackgroundImage = p.images.ProxyURL(base, m.BackgroundImage, "w1280")
+		m.BackgroundImageOriginal = p.images.ProxyURL(base, m.BackgroundImageOriginal, "original")
+		if len(m.AllImages) > 0 {
+			all := make(models.StringSlice, len(m.AllImages))
+			for j, img := range m.AllImages {
+				all[j] = p.images.ProxyURL(base, img, "w780")
+			}
+			m.AllImages = all
+		}
+		if len(m.BackgroundImages) > 0 {
+			bgs := make([]string, len(m.BackgroundImages))
+			for j, img := range m.BackgroundImages {
+				bgs[j] = p.images.ProxyURL(base, img, "w1280")
+			}
+			m.BackgroundImages = bgs
+		}
+	}
+}
+
+// Series rewrites poster, background and season poster images in place
+func (p *ImageRewriter) Series(r *http.Request, series []models.Series) {
+	if p == nil || len(series) == 0 {
+		return
+	}
+	base := p.base(r)
+	for i := range series {
+		s := &series[i]
+		s.PosterImage = p.images.ProxyURL(base, s.PosterImage, "w342")
+		s.BackgroundImage = p.images.ProxyURL(base, s.BackgroundImage, "w1280")
+		for j := range s.Seasons {
+			s.Seasons[j].PosterImage = p.images.ProxyURL(base, s.Seasons[j].PosterImage, "w342")
+		}
+	}
+}
+
+// Episodes rewrites episode stills in place
+func (p *ImageRewriter) Episodes(r *http.Request, episodes []models.Episode) {
+	if p == nil || len(episodes) == 0 {
+		return
+	}
+	base := p.base(r)
+	for i := range episodes {
+		episodes[i].StillImage = p.images.ProxyURL(base, episodes[i].StillImage, "w300")
+	}
+}
+
+// UpcomingEpisodes rewrites calendar posters and stills in place
+func (p *ImageRewriter) UpcomingEpisodes(r *http.Request, episodes []models.UpcomingEpisode) {
+	if p == nil || len(episodes) == 0 {
+		return
+	}
+	base := p.base(r)
+	for i := range episodes {
+		episodes[i].PosterImage = p.images.ProxyURL(base, episodes[i].PosterImage, "w342")
+		episodes[i].StillImage = p.images.ProxyURL(base, episodes[i].StillImage, "w300")
+	}
+}
+
+// Channels rewrites channel logos in place
+func (p *ImageRewriter) Channels(r *http.Request, channels []models.Channel) {
+	if p == nil || len(channels) == 0 {
+		return
+	}
+	base := p.base(r)
+	for i := range channels {
+		channels[i].Logo = p.images.ProxyURL(base, channels[i].Logo, "w185")
+	}
+}
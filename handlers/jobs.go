@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"torrent-server/services"
+)
+
+type JobsHandler struct {
+	scheduler *services.Scheduler
+}
+
+func NewJobsHandler(scheduler *services.Scheduler) *JobsHandler {
+	return &JobsHandler{scheduler: scheduler}
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound), errors.Is(err, services.ErrJobRunNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrJobRunning), errors.Is(err, services.ErrJobNotRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ListJobs handles GET /admin/api/jobs
+func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs": h.scheduler.Jobs(),
+	})
+}
+
+// GetJob handles GET /admin/api/jobs/{name}
+func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	info, err := h.scheduler.Job(chi.URLParam(r, "name"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// UpdateJob handles PUT /admin/api/jobs/{name}
+// Body: {"schedule": "0 4 * * *"}; an empty schedule makes the job manual-only
+func (h *JobsHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Schedule *string `json:"schedule"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Schedule == nil {
+		http.Error(w, "schedule required", http.StatusBadRequest)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := h.scheduler.SetSchedule(name, *req.Schedule); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	h.GetJob(w, r)
+}
+
+// TriggerJob handles POST /admin/api/jobs/{name}/run
+func (h *JobsHandler) TriggerJob(w http.ResponseWriter, r *http.Request) {
+	run, err := h.scheduler.Trigger(chi.URLParam(r, "name"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"run":    run,
+	})
+}
+
+// StartJob returns a handler that starts the named job and responds with
+// its job ID for polling via /admin/api/jobs/runs/{id}
+func (h *JobsHandler) StartJob(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		run, err := h.scheduler.Trigger(name)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "started",
+			"job":    name,
+			"job_id": run.ID,
+		})
+	}
+}
+
+// CancelJob handles POST /admin/api/jobs/{name}/cancel
+func (h *JobsHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if err := h.scheduler.CancelJob(chi.URLParam(r, "name")); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelling"})
+}
+
+// RunStatus handles GET /admin/api/jobs/runs/{id}
+// Returns the run with live progress (processed, total, added, failed, current item)
+func (h *JobsHandler) RunStatus(w http.ResponseWriter, r *http.Request) {
+	id := parseInt(chi.URLParam(r, "id"), 0)
+	if id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	status, err := h.scheduler.RunStatus(uint(id))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// CancelRun handles POST /admin/api/jobs/runs/{id}/cancel
+func (h *JobsHandler) CancelRun(w http.ResponseWriter, r *http.Request) {
+	id := parseInt(chi.URLParam(r, "id"), 0)
+	if id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := h.scheduler.Cancel(uint(id)); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelling"})
+}
+
+// PauseJob handles POST /admin/api/jobs/{name}/pause
+func (h *JobsHandler) PauseJob(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// ResumeJob handles POST /admin/api/jobs/{name}/resume
+func (h *JobsHandler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+func (h *JobsHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if err := h.scheduler.SetPaused(chi.URLParam(r, "name"), paused); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	h.GetJob(w, r)
+}
+
+// JobHistory handles GET /admin/api/jobs/{name}/runs?limit=50
+func (h *JobsHandler) JobHistory(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	runs, err := h.scheduler.History(name, parseInt(r.URL.Query().Get("limit"), 50))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job":  name,
+		"runs": runs,
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+
+	"torrent-server/database"
+	"torrent-server/models"
+	"torrent-server/services"
+)
+
+// libraryScanJob is the scheduler job that rescans library folders
+const libraryScanJob = "library_scan"
+
+type LibraryHandler struct {
+	db        *database.DB
+	library   *services.LibraryService
+	scheduler *services.Scheduler
+}
+
+func NewLibraryHandler(db *database.DB, library *services.LibraryService) *LibraryHandler {
+	return &LibraryHandler{db: db, library: library}
+}
+
+// SetScheduler lets folder changes start a rescan
+func (h *LibraryHandler) SetScheduler(s *services.Scheduler) {
+	h.scheduler = s
+}
+
+// startScan triggers a library scan; one already running picks up the change next time
+func (h *LibraryHandler) startScan() *models.JobRun {
+	if h.scheduler == nil {
+		return nil
+	}
+	run, _ := h.scheduler.Trigger(libraryScanJob)
+	return run
+}
+
+func validLibraryKind(kind string) bool {
+	return kind == models.LibraryKindAuto || kind == models.LibraryKindMovies || kind == models.LibraryKindSeries
+}
+
+// ListFolders handles GET /admin/api/library/folders
+func (h *LibraryHandler) ListFolders(w http.ResponseWriter, r *http.Request) {
+	folders, err := h.db.ListLibraryFolders()
+	if err != nil {
+		http.Error(w, "Failed to fetch library folders", http.StatusInternalServerError)
+		return
+	}
+	if folders == nil {
+		folders = []models.LibraryFolder{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folders)
+}
+
+// CreateFolder handles POST /admin/api/library/folders
+// Body: {"path": "/mnt/nas/movies", "kind": "movies"}
+func (h *LibraryHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string `json:"path"`
+		Kind string `json:"kind"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = models.LibraryKindAuto
+	}
+	if !validLibraryKind(req.Kind) {
+		http.Error(w, "kind must be auto, movies or series", http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(req.Path) {
+		http.Error(w, "path must be absolute", http.StatusBadRequest)
+		return
+	}
+	if info, err := os.Stat(req.Path); err != nil || !info.IsDir() {
+		http.Error(w, "path is not a readable directory", http.StatusBadRequest)
+		return
+	}
+
+	folder := models.LibraryFolder{Path: filepath.Clean(req.Path), Kind: req.Kind, Enabled: true}
+	if err := h.db.CreateLibraryFolder(&folder); err != nil {
+		http.Error(w, "Failed to add library folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{"folder": folder}
+	if run := h.startScan(); run != nil {
+		resp["job_id"] = run.ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// UpdateFolder handles PUT /admin/api/library/folders/{id}
+// Body: {"kind": "series", "enabled": false}
+func (h *LibraryHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) {
+	folder, err := h.db.GetLibraryFolder(uint(parseInt(chi.URLParam(r, "id"), 0)))
+	if err != nil {
+		http.Error(w, "Library folder not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Kind    *string `json:"kind"`
+		Enabled *bool   `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != nil {
+		if !validLibraryKind(*req.Kind) {
+			http.Error(w, "kind must be auto, movies or series", http.StatusBadRequest)
+			return
+		}
+		if *req.Kind != folder.Kind {
+			// Files need re-matching as movies or episodes
+			h.db.UnlinkLocalFiles("folder_id = ?", folder.ID)
+		}
+		folder.Kind = *req.Kind
+	}
+	if req.Enabled != nil {
+		folder.Enabled = *req.Enabled
+	}
+
+	if err := h.db.SaveLibraryFolder(folder); err != nil {
+		http.Error(w, "Failed to update library folder", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folder)
+}
+
+// DeleteFolder handles DELETE /admin/api/library/folders/{id}
+// Files are only forgotten, never deleted from disk.
+func (h *LibraryHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.DeleteLibraryFolder(uint(parseInt(chi.URLParam(r, "id"), 0))); err != nil {
+		http.Error(w, "Failed to delete library folder", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFiles handles GET /admin/api/library/files?folder_id=&unmatched=true&page=&limit=
+func (h *LibraryHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := database.LocalFileFilter{
+		FolderID:  uint(parseInt(q.Get("folder_id"), 0)),
+		Unmatched: q.Get("unmatched") == "true",
+		Page:      parseInt(q.Get("page"), 1),
+		Limit:     parseInt(q.Get("limit"), 100),
+	}
+
+	files, total, err := h.db.ListLocalFiles(filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch library files", http.StatusInternalServerError)
+		return
+	}
+	adminFiles := make([]models.AdminLocalFile, len(files))
+	for i, f := range files {
+		adminFiles[i] = models.NewAdminLocalFile(f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files": adminFiles,
+		"total": total,
+	})
+}
+
+// MatchFile handles PUT /admin/api/library/files/{id}
+// Body: {"imdb_code": "tt0903747", "season": 1, "episode": 2}; season and
+// episode are only given for episodes
+func (h *LibraryHandler) MatchFile(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ImdbCode string `json:"imdb_code"`
+		Season   int    `json:"season"`
+		Episode  int    `json:"episode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	file, err := h.library.MatchFile(uint(parseInt(chi.URLParam(r, "id"), 0)), req.ImdbCode, req.Season, req.Episode)
+	if err != nil {
+		http.Error(w, "Failed to match file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewAdminLocalFile(*file))
+}
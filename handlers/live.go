@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"torrent-server/database"
+	"torrent-server/services"
+)
+
+type LiveHandler struct {
+	db    *database.DB
+	proxy *services.HLSProxy
+}
+
+func NewLiveHandler(db *database.DB, proxy *services.HLSProxy) *LiveHandler {
+	return &LiveHandler{db: db, proxy: proxy}
+}
+
+// Playlist handles GET /live/{channelID}/index.m3u8
+// Relays the channel's HLS playlist with every URI pointing back at /live
+func (h *LiveHandler) Playlist(w http.ResponseWriter, r *http.Request) {
+	channelID := chi.URLParam(r, "channelID")
+	channel, err := h.db.GetChannel(channelID)
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if channel.Disabled {
+		http.Error(w, "Channel is disabled", http.StatusNotFound)
+		return
+	}
+	if channel.StreamURL == "" {
+		http.Error(w, "Channel has no stream", http.StatusNotFound)
+		return
+	}
+
+	resp, err := h.proxy.Playlist(channel.ID, channel.StreamURL)
+	if err != nil {
+		h.writeUpstreamError(w, channel.ID, err)
+		return
+	}
+	writeLive(w, resp)
+}
+
+// Segment handles GET /live/{channelID}/{sig}/{key}
+// Relays a variant playlist, media segment or key handed out by Playlist
+func (h *LiveHandler) Segment(w http.ResponseWriter, r *http.Request) {
+	channelID := chi.URLParam(r, "channelID")
+	src, err := h.proxy.Decode(channelID, chi.URLParam(r, "sig"), chi.URLParam(r, "key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	resp, err := h.proxy.Get(channelID, src)
+	if err != nil {
+		h.writeUpstreamError(w, channelID, err)
+		return
+	}
+	writeLive(w, resp)
+}
+
+// Stats handles GET /admin/api/channels/proxy/stats
+func (h *LiveHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.proxy.Stats())
+}
+
+func writeLive(w http.ResponseWriter, resp *services.HLSResponse) {
+	w.Header().Set("Content-Type", resp.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	if resp.Playlist {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}
+	w.Write(resp.Body)
+}
+
+func (h *LiveHandler) writeUpstreamError(w http.ResponseWriter, channelID string, err error) {
+	var upstream *services.HLSUpstreamError
+	switch {
+	case errors.Is(err, services.ErrNotHLS):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, services.ErrPrivateHost):
+		http.Error(w, "Stream host is not allowed", http.StatusForbidden)
+	case errors.As(err, &upstream) && upstream.StatusCode == http.StatusNotFound:
+		http.Error(w, "Stream not found upstream", http.StatusNotFound)
+	default:
+		log.Printf("[Live] Failed to relay channel %s: %v", channelID, err)
+		http.Error(w, "Stream unavailable", http.StatusBadGateway)
+	}
+}
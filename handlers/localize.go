@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"torrent-server/database"
+	"torrent-server/models"
+)
+
+// requestLang returns the primary language subtag of the lang query param
+// ("sq-AL" -> "sq"), or "" when the request wants English.
+func requestLang(r *http.Request) string {
+	lang := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("lang")))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "en" {
+		return ""
+	}
+	return lang
+}
+
+// localizeMovies overlays translated fields onto movies in place.
+// Fields without a translation keep their English value.
+func localizeMovies(db *database.DB, movies []models.Movie, lang string) {
+	if lang == "" || len(movies) == 0 {
+		return
+	}
+	ids := make([]uint, len(movies))
+	for i, m := range movies {
+		ids[i] = m.ID
+	}
+	locs, err := db.GetLocalizations("movie", ids, lang)
+	if err != nil {
+		return
+	}
+	for i := range movies {
+		l, ok := locs[movies[i].ID]
+		if !ok {
+			continue
+		}
+		m := &movies[i]
+		if l.Title != "" {
+			m.Title = l.Title
+		}
+		if l.Summary != "" {
+			m.Summary = l.Summary
+			m.DescriptionFull = l.Summary
+			m.Synopsis = l.Summary
+		}
+		if l.Tagline != "" {
+			m.Tagline = l.Tagline
+		}
+		if l.PosterImage != "" {
+			m.SmallCoverImage = l.PosterImage
+			m.MediumCoverImage = l.PosterImage
+			m.LargeCoverImage = l.PosterImage
+		}
+	}
+}
+
+// localizeSeries overlays translated fields onto series in place
+func localizeSeries(db *database.DB, series []models.Series, lang string) {
+	if lang == "" || len(series) == 0 {
+		return
+	}
+	ids := make([]uint, len(series))
+	for i, s := range series {
+		ids[i] = s.ID
+	}
+	locs, err := db.GetLocalizations("series", ids, lang)
+	if err != nil {
+		return
+	}
+	for i := range series {
+		l, ok := locs[series[i].ID]
+		if !ok {
+			continue
+		}
+		s := &series[i]
+		if l.Title != "" {
+			s.Title = l.Title
+		}
+		if l.Summary != "" {
+			s.Summary = l.Summary
+		}
+		if l.Tagline != "" {
+			s.Tagline = l.Tagline
+		}
+		if l.PosterImage != "" {
+			s.PosterImage = l.PosterImage
+		}
+	}
+}
+
+// localizeEpisodes overlays translated fields onto episodes in place
+func localizeEpisodes(db *database.DB, episodes []models.Episode, lang string) {
+	if lang == "" || len(episodes) == 0 {
+		return
+	}
+	ids := make([]uint, len(episodes))
+	for i, e := range episodes {
+		ids[i] = e.ID
+	}
+	locs, err := db.GetLocalizations("episode", ids, lang)
+	if err != nil {
+		return
+	}
+	for i := range episodes {
+		l, ok := locs[episodes[i].ID]
+		if !ok {
+			continue
+		}
+		e := &episodes[i]
+		if l.Title != "" {
+			e.Title = l.Title
+		}
+		if l.Summary != "" {
+			e.Summary = l.Summary
+		}
+		if l.PosterImage != "" {
+			e.StillImage = l.PosterImage
+		}
+	}
+}
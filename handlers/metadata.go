@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"torrent-server/services"
+)
+
+type MetadataHandler struct {
+	metadata *services.MetadataService
+}
+
+func NewMetadataHandler(metadata *services.MetadataService) *MetadataHandler {
+	return &MetadataHandler{metadata: metadata}
+}
+
+// CacheStats handles GET /admin/api/metadata-cache/stats
+// Returns hit rate and entry counts for the upstream response cache
+func (h *MetadataHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	cache := h.metadata.Cache()
+	if cache == nil {
+		http.Error(w, "metadata cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cache.Stats())
+}
+
+// PurgeCache handles DELETE /admin/api/metadata-cache
+// Optional provider, endpoint and imdb_code query params narrow what is purged
+func (h *MetadataHandler) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	cache := h.metadata.Cache()
+	if cache == nil {
+		http.Error(w, "metadata cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	purged, err := cache.Purge(q.Get("provider"), q.Get("endpoint"), q.Get("imdb_code"))
+	if err != nil {
+		http.Error(w, "failed to purge cache: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"purged": purged,
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"torrent-server/database"
+	"torrent-server/models"
+)
+
+type PersonHandler struct {
+	db *database.DB
+}
+
+func NewPersonHandler(db *database.DB) *PersonHandler {
+	return &PersonHandler{db: db}
+}
+
+// lookupPerson resolves person_id or imdb_id from the query string
+func (h *PersonHandler) lookupPerson(r *http.Request) (*models.Person, string) {
+	q := r.URL.Query()
+	if id := parseInt(q.Get("person_id"), 0); id > 0 {
+		p, err := h.db.GetPerson(uint(id))
+		if err != nil {
+			return nil, "Person not found"
+		}
+		return p, ""
+	}
+	if imdbID := q.Get("imdb_id"); imdbID != "" {
+		p, err := h.db.GetPersonByIMDB(imdbID)
+		if err != nil {
+			return nil, "Person not found"
+		}
+		return p, ""
+	}
+	return nil, "person_id or imdb_id is required"
+}
+
+// PersonDetails handles GET /api/v2/person_details.json
+func (h *PersonHandler) PersonDetails(w http.ResponseWriter, r *http.Request) {
+	person, errMsg := h.lookupPerson(r)
+	if person == nil {
+		writeError(w, errMsg)
+		return
+	}
+
+	counts, _ := h.db.CountPersonCredits(person.ID)
+	if counts == nil {
+		counts = map[string]int{}
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"person":        person,
+		"credit_counts": counts,
+	})
+}
+
+// PersonCredits handles GET /api/v2/person_credits.json
+// Optional role param: actor, director or writer
+func (h *PersonHandler) PersonCredits(w http.ResponseWriter, r *http.Request) {
+	person, errMsg := h.lookupPerson(r)
+	if person == nil {
+		writeError(w, errMsg)
+		return
+	}
+
+	credits, err := h.db.GetPersonCredits(person.ID, r.URL.Query().Get("role"))
+	if err != nil {
+		writeError(w, "Failed to fetch credits: "+err.Error())
+		return
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"person":       person,
+		"credit_count": len(credits),
+		"credits":      credits,
+	})
+}
@@ -13,12 +13,19 @@ import (
 type RatingsHandler struct {
 	db          *database.DB
 	syncService *services.SyncService
+	scheduler   *services.Scheduler
 }
 
 func NewRatingsHandler(db *database.DB, syncService *services.SyncService) *RatingsHandler {
 	return &RatingsHandler{db: db, syncService: syncService}
 }
 
+// SetScheduler routes refresh-all requests through the job scheduler so
+// they are recorded and never overlap.
+func (h *RatingsHandler) SetScheduler(s *services.Scheduler) {
+	h.scheduler = s
+}
+
 // GetRatings handles POST /api/v2/get_ratings
 // Takes array of IMDB codes, returns map of ratings
 func (h *RatingsHandler) GetRatings(w http.ResponseWriter, r *http.Request) {
@@ -243,32 +250,40 @@ func (h *RatingsHandler) SyncMovies(w http.ResponseWriter, r *http.Request) {
 // RefreshAllMovies handles POST /admin/api/refresh_all_movies
 // Refreshes all movies in the background
 func (h *RatingsHandler) RefreshAllMovies(w http.ResponseWriter, r *http.Request) {
-	if h.syncService == nil {
-		writeError(w, "Sync service not available")
+	if h.scheduler == nil {
+		writeError(w, "Scheduler not available")
 		return
 	}
 
-	// Start background refresh
-	go h.syncService.RefreshAllMovies()
+	run, err := h.scheduler.Trigger("refresh_movies")
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
 
 	writeSuccess(w, map[string]interface{}{
 		"started": true,
 		"message": "Background refresh started",
+		"job_id":  run.ID,
 	})
 }
 
 func (h *RatingsHandler) RefreshAllSeries(w http.ResponseWriter, r *http.Request) {
-	if h.syncService == nil {
-		writeError(w, "Sync service not available")
+	if h.scheduler == nil {
+		writeError(w, "Scheduler not available")
 		return
 	}
 
-	// Start background refresh
-	go h.syncService.RefreshAllSeries()
+	run, err := h.scheduler.Trigger("refresh_series")
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
 
 	writeSuccess(w, map[string]interface{}{
 		"started": true,
 		"message": "Background TV series refresh started",
+		"job_id":  run.ID,
 	})
 }
 
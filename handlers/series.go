@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -17,6 +18,7 @@ import (
 type SeriesHandler struct {
 	db          *database.DB
 	syncService *services.SyncService
+	images      *ImageRewriter
 }
 
 func NewSeriesHandler(db *database.DB) *SeriesHandler {
@@ -28,6 +30,11 @@ func (h *SeriesHandler) SetSyncService(s *services.SyncService) {
 	h.syncService = s
 }
 
+// SetImageRewriter enables rewriting image URLs to the local image proxy.
+func (h *SeriesHandler) SetImageRewriter(p *ImageRewriter) {
+	h.images = p
+}
+
 // ListSeries handles GET /api/v2/list_series.json
 func (h *SeriesHandler) ListSeries(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
@@ -61,6 +68,8 @@ func (h *SeriesHandler) ListSeries(w http.ResponseWriter, r *http.Request) {
 	if series == nil {
 		series = []models.Series{}
 	}
+	localizeSeries(h.db, series, requestLang(r))
+	h.images.Series(r, series)
 
 	data := map[string]interface{}{
 		"series_count": totalCount,
@@ -97,6 +106,8 @@ func (h *SeriesHandler) SearchSeriesOnline(w http.ResponseWriter, r *http.Reques
 	if series == nil {
 		series = []models.Series{}
 	}
+	localizeSeries(h.db, series, requestLang(r))
+	h.images.Series(r, series)
 
 	data := map[string]interface{}{
 		"series_count": totalCount,
@@ -139,8 +150,15 @@ func (h *SeriesHandler) SeriesDetails(w http.ResponseWriter, r *http.Request) {
 	// Get season packs
 	seasonPacks, _ := h.db.GetSeasonPacks(uint(seriesID))
 
+	lang := requestLang(r)
+	list := []models.Series{*series}
+	localizeSeries(h.db, list, lang)
+	h.images.Series(r, list)
+	localizeEpisodes(h.db, episodes, lang)
+	h.images.Episodes(r, episodes)
+
 	data := map[string]interface{}{
-		"series":       series,
+		"series":       list[0],
 		"episodes":     episodes,
 		"season_packs": seasonPacks,
 	}
@@ -173,10 +191,64 @@ func (h *SeriesHandler) SeasonEpisodes(w http.ResponseWriter, r *http.Request) {
 	if episodes == nil {
 		episodes = []models.Episode{}
 	}
+	localizeEpisodes(h.db, episodes, requestLang(r))
+	h.images.Episodes(r, episodes)
 
 	writeSuccess(w, episodes)
 }
 
+// UpcomingEpisodes handles GET /api/v2/upcoming_episodes.json
+// Params: days (ahead, default 7), past_days (recently aired, default 0), series_id
+func (h *SeriesHandler) UpcomingEpisodes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	days := parseInt(q.Get("days"), 7)
+	if days < 0 || days > 90 {
+		days = 7
+	}
+	pastDays := parseInt(q.Get("past_days"), 0)
+	if pastDays < 0 || pastDays > 30 {
+		pastDays = 0
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -pastDays).Format("2006-01-02")
+	to := now.AddDate(0, 0, days).Format("2006-01-02")
+
+	episodes, err := h.db.ListUpcomingEpisodes(from, to, uint(parseInt(q.Get("series_id"), 0)))
+	if err != nil {
+		writeError(w, "Failed to fetch upcoming episodes: "+err.Error())
+		return
+	}
+	if episodes == nil {
+		episodes = []models.UpcomingEpisode{}
+	}
+	h.images.UpcomingEpisodes(r, episodes)
+
+	writeSuccess(w, models.UpcomingEpisodesData{
+		From:     from,
+		To:       to,
+		Count:    len(episodes),
+		Episodes: episodes,
+	})
+}
+
+// ListAcquisitions handles GET /admin/api/episodes/acquisitions?status=searching
+func (h *SeriesHandler) ListAcquisitions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	list, err := h.db.ListEpisodeAcquisitions(q.Get("status"), parseInt(q.Get("limit"), 100))
+	if err != nil {
+		http.Error(w, "Failed to fetch acquisitions", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.EpisodeAcquisition{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
 // AddSeries handles POST /admin/series
 func (h *SeriesHandler) AddSeries(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
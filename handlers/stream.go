@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -63,6 +64,38 @@ func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	serveRange(w, r, reader, fileName, fileSize)
+}
+
+// StreamLocal handles GET /stream/local/{id}, serving a library file from disk
+func (h *StreamHandler) StreamLocal(w http.ResponseWriter, r *http.Request) {
+	localFile, err := h.db.GetLocalFile(uint(parseInt(chi.URLParam(r, "id"), 0)))
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	// Errors name the path on disk, so they are only logged
+	f, err := os.Open(localFile.Path)
+	if err != nil {
+		log.Printf("[Stream] Failed to open local file %d: %v", localFile.ID, err)
+		http.Error(w, "File not available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Printf("[Stream] Failed to stat local file %d: %v", localFile.ID, err)
+		http.Error(w, "File not available", http.StatusInternalServerError)
+		return
+	}
+
+	serveRange(w, r, f, filepath.Base(localFile.Path), info.Size())
+}
+
+// serveRange writes reader to w, honouring a single-range Range header
+func serveRange(w http.ResponseWriter, r *http.Request, reader io.ReadSeeker, fileName string, fileSize int64) {
 	// Set content type based on extension
 	contentType := services.GetContentType(fileName)
 	w.Header().Set("Content-Type", contentType)
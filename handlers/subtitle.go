@@ -3,19 +3,24 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 
 	"torrent-server/database"
+	"torrent-server/models"
 	"torrent-server/services"
 )
 
 type SubtitleHandler struct {
 	subtitleService *services.SubtitleService
+	torrentService  *services.TorrentService
 	db              *database.DB
 }
 
@@ -23,17 +28,26 @@ func NewSubtitleHandler(ss *services.SubtitleService, db *database.DB) *Subtitle
 	return &SubtitleHandler{subtitleService: ss, db: db}
 }
 
+// SetTorrentService enables moviehash matching for torrent files
+func (h *SubtitleHandler) SetTorrentService(ts *services.TorrentService) {
+	h.torrentService = ts
+}
+
 // Search handles GET /api/v2/subtitles/search?imdb_id={id}&languages={langs}&season={s}&episode={e}
-// Returns stored subs from DB, supplements with external API for missing languages
+// Returns stored subs from DB, supplements with external API for missing languages.
+// With hash (and optionally file_index), or moviehash and moviebytesize, exact
+// matches for that file are looked up for every language and listed first.
 func (h *SubtitleHandler) Search(w http.ResponseWriter, r *http.Request) {
-	imdbID := r.URL.Query().Get("imdb_id")
+	q := r.URL.Query()
+	imdbID := q.Get("imdb_id")
 	if imdbID == "" {
 		http.Error(w, "imdb_id required", http.StatusBadRequest)
 		return
 	}
-	languages := r.URL.Query().Get("languages")
-	season, _ := strconv.Atoi(r.URL.Query().Get("season"))
-	episode, _ := strconv.Atoi(r.URL.Query().Get("episode"))
+	languages := q.Get("languages")
+	season, _ := strconv.Atoi(q.Get("season"))
+	episode, _ := strconv.Atoi(q.Get("episode"))
+	movieHash := h.movieHash(r)
 
 	var subtitles []services.Subtitle
 
@@ -42,6 +56,7 @@ func (h *SubtitleHandler) Search(w http.ResponseWriter, r *http.Request) {
 		stored, err := h.db.GetSubtitlesByIMDBEpisode(imdbID, languages, season, episode)
 		if err == nil && len(stored) > 0 {
 			for _, s := range stored {
+				score := s.Score
 				subtitles = append(subtitles, services.Subtitle{
 					ID:              fmt.Sprintf("stored-%d", s.ID),
 					Language:        s.Language,
@@ -49,52 +64,42 @@ func (h *SubtitleHandler) Search(w http.ResponseWriter, r *http.Request) {
 					DownloadURL:     fmt.Sprintf("/api/v2/subtitles/stored/%d", s.ID),
 					ReleaseName:     s.ReleaseName,
 					HearingImpaired: s.HearingImpaired,
+					Score:           &score,
 				})
 			}
 		}
 	}
 
-	// Find which requested languages are missing from stored results
-	if languages != "" {
-		haveLangs := make(map[string]bool)
-		for _, s := range subtitles {
-			haveLangs[s.Language] = true
-		}
-		var missingLangs []string
-		for _, lang := range strings.Split(languages, ",") {
-			lang = strings.TrimSpace(lang)
-			if lang != "" && !haveLangs[lang] {
-				missingLangs = append(missingLangs, lang)
-			}
-		}
+	haveLangs := make(map[string]bool)
+	for _, s := range subtitles {
+		haveLangs[s.Language] = true
+	}
 
-		// Search external APIs for missing languages
-		if len(missingLangs) > 0 {
-			missingStr := strings.Join(missingLangs, ",")
-			var extResult *services.SubtitleSearchResult
-			var err error
-			if season > 0 && episode > 0 {
-				extResult, err = h.subtitleService.SearchByIMDBEpisode(imdbID, missingStr, season, episode)
-			} else {
-				extResult, err = h.subtitleService.SearchByIMDB(imdbID, missingStr)
-			}
-			if err == nil {
-				subtitles = append(subtitles, extResult.Subtitles...)
-			}
+	// Search external APIs for languages with nothing stored. A moviehash is
+	// worth a search for every language since stored subs may be timed for
+	// another release.
+	var searchLangs []string
+	for _, lang := range services.SplitLanguages(languages) {
+		if movieHash != nil || !haveLangs[lang] {
+			searchLangs = append(searchLangs, lang)
 		}
-	} else if len(subtitles) == 0 {
-		// No languages specified and nothing stored — search externally
-		var extResult *services.SubtitleSearchResult
-		var err error
-		if season > 0 && episode > 0 {
-			extResult, err = h.subtitleService.SearchByIMDBEpisode(imdbID, "", season, episode)
-		} else {
-			extResult, err = h.subtitleService.SearchByIMDB(imdbID, "")
-		}
-		if err == nil {
-			subtitles = append(subtitles, extResult.Subtitles...)
+	}
+	if len(searchLangs) > 0 || (languages == "" && (len(subtitles) == 0 || movieHash != nil)) {
+		ext := h.subtitleService.Search(services.SubtitleQuery{
+			ImdbID:    "tt" + strings.TrimPrefix(imdbID, "tt"),
+			Languages: searchLangs,
+			Season:    season,
+			Episode:   episode,
+			MovieHash: movieHash,
+		})
+		for _, s := range ext.Subtitles {
+			// Languages already stored only gain exact matches
+			if s.HashMatch || movieHash == nil || !haveLangs[s.Language] {
+				subtitles = append(subtitles, s)
+			}
 		}
 	}
+	services.RankHashMatches(subtitles)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(&services.SubtitleSearchResult{
@@ -103,6 +108,38 @@ func (h *SubtitleHandler) Search(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// movieHashRe matches an OpenSubtitles moviehash, which providers put in
+// their request paths
+var movieHashRe = regexp.MustCompile(`^[0-9a-fA-F]{16}$`)
+
+// movieHash returns the moviehash given by the client, if well-formed, or computes it from
+// the torrent file given by hash and file_index
+func (h *SubtitleHandler) movieHash(r *http.Request) *services.MovieHash {
+	q := r.URL.Query()
+	if mh := q.Get("moviehash"); mh != "" {
+		size, _ := strconv.ParseInt(q.Get("moviebytesize"), 10, 64)
+		if size > 0 && movieHashRe.MatchString(mh) {
+			return &services.MovieHash{Hash: strings.ToLower(mh), Size: size}
+		}
+		return nil
+	}
+
+	infoHash := q.Get("hash")
+	if infoHash == "" || h.torrentService == nil {
+		return nil
+	}
+	fileIndex := -1
+	if fi, err := strconv.Atoi(q.Get("file_index")); err == nil {
+		fileIndex = fi
+	}
+	mh, err := h.subtitleService.TorrentMovieHash(h.torrentService, infoHash, fileIndex)
+	if err != nil {
+		log.Printf("[Subtitles] Failed to compute moviehash for %s/%d: %v", infoHash, fileIndex, err)
+		return nil
+	}
+	return mh
+}
+
 // SearchByFilename handles GET /api/v2/subtitles/search_by_filename?filename={name}&languages={langs}
 func (h *SubtitleHandler) SearchByFilename(w http.ResponseWriter, r *http.Request) {
 	filename := r.URL.Query().Get("filename")
@@ -142,8 +179,10 @@ func (h *SubtitleHandler) Download(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(vtt))
 }
 
-// ServeStored handles GET /api/v2/subtitles/stored/{id}
-// Serves VTT content from disk file (fallback to DB for old rows)
+// ServeStored handles GET /api/v2/subtitles/stored/{id}?format=vtt|srt|ass|ttml
+// Serves VTT content from disk file (fallback to DB for old rows). Other
+// formats are served from the original file when it has that format and
+// converted from the VTT otherwise.
 func (h *SubtitleHandler) ServeStored(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
@@ -158,22 +197,134 @@ func (h *SubtitleHandler) ServeStored(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var vttContent string
-	if sub.VTTPath != "" {
-		data, err := os.ReadFile(sub.VTTPath)
-		if err == nil {
-			vttContent = string(data)
-		} else {
-			// File missing, fallback to DB content
-			vttContent = sub.VTTContent
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = services.SubtitleFormatVTT
+	}
+	contentType := services.SubtitleContentType(format)
+	if contentType == "" {
+		http.Error(w, "format must be one of vtt, srt, ass, ttml", http.StatusBadRequest)
+		return
+	}
+
+	content, err := services.RenderStored(sub, format)
+	if err != nil {
+		http.Error(w, "subtitle file not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write([]byte(content))
+}
+
+// retimeRequest is the body of a retime POST
+type retimeRequest struct {
+	services.RetimeOptions
+	Save        bool   `json:"save"`
+	ReleaseName string `json:"release_name"`
+}
+
+// parseRetimeRequest reads retime options from a JSON body, or for GET from
+// offset_ms, from_fps, to_fps and points=from_ms:to_ms,from_ms:to_ms
+func parseRetimeRequest(r *http.Request) (retimeRequest, error) {
+	var req retimeRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, err
 		}
-	} else {
-		vttContent = sub.VTTContent
+		return req, nil
+	}
+	q := r.URL.Query()
+	req.OffsetMs, _ = strconv.ParseInt(q.Get("offset_ms"), 10, 64)
+	req.FromFPS, _ = strconv.ParseFloat(q.Get("from_fps"), 64)
+	req.ToFPS, _ = strconv.ParseFloat(q.Get("to_fps"), 64)
+	for _, pair := range strings.Split(q.Get("points"), ",") {
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		var p services.SubtitleSyncPoint
+		p.FromMs, _ = strconv.ParseInt(strings.TrimSpace(from), 10, 64)
+		p.ToMs, _ = strconv.ParseInt(strings.TrimSpace(to), 10, 64)
+		req.Points = append(req.Points, p)
+	}
+	return req, nil
+}
+
+// retimeStored loads the subtitle named by the id URL param and retimes it,
+// writing an error response and returning ok=false on failure
+func (h *SubtitleHandler) retimeStored(w http.ResponseWriter, r *http.Request, opts services.RetimeOptions) (*models.StoredSubtitle, string, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, "", false
+	}
+	sub, err := h.db.GetSubtitleByID(uint(id))
+	if err != nil {
+		http.Error(w, "subtitle not found", http.StatusNotFound)
+		return nil, "", false
+	}
+	vtt, err := h.subtitleService.RetimeStored(sub, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+	return sub, vtt, true
+}
+
+// Retime handles GET and POST /api/v2/subtitles/stored/{id}/retime
+// Returns the subtitle as VTT with a constant offset, a framerate conversion
+// or a two-point linear fit applied, without storing anything. GET takes
+// offset_ms, from_fps, to_fps and points=from_ms:to_ms,from_ms:to_ms; POST
+// takes the same fields as JSON. Saving goes through the admin endpoint.
+func (h *SubtitleHandler) Retime(w http.ResponseWriter, r *http.Request) {
+	req, err := parseRetimeRequest(r)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Save {
+		http.Error(w, "saving a retimed subtitle requires POST /admin/api/subtitles/{id}/retime", http.StatusForbidden)
+		return
+	}
+
+	_, vtt, ok := h.retimeStored(w, r, req.RetimeOptions)
+	if !ok {
+		return
 	}
 
 	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Write([]byte(vttContent))
+	w.Write([]byte(vtt))
+}
+
+// SaveRetimed handles POST /admin/api/subtitles/{id}/retime
+// Takes the retime options as JSON plus release_name, which must match a
+// torrent or local file of the title, and stores the result as a new subtitle
+func (h *SubtitleHandler) SaveRetimed(w http.ResponseWriter, r *http.Request) {
+	var req retimeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sub, vtt, ok := h.retimeStored(w, r, req.RetimeOptions)
+	if !ok {
+		return
+	}
+
+	saved, err := h.subtitleService.SaveRetimed(sub, vtt, req.ReleaseName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "ok",
+		"subtitle":     saved,
+		"download_url": fmt.Sprintf("/api/v2/subtitles/stored/%d", saved.ID),
+	})
 }
 
 // SyncSubtitles handles POST /admin/api/subtitles/sync
@@ -193,10 +344,6 @@ func (h *SubtitleHandler) SyncSubtitles(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "imdb_code required", http.StatusBadRequest)
 		return
 	}
-	if req.Languages == "" {
-		req.Languages = "en,sq,es,fr,de,it,pt,tr,ar"
-	}
-
 	var count int
 	var err error
 	if req.Season > 0 && req.Episode > 0 {
@@ -248,6 +395,9 @@ func (h *SubtitleHandler) DeleteStored(w http.ResponseWriter, r *http.Request) {
 	if err == nil && sub.VTTPath != "" {
 		os.Remove(sub.VTTPath)
 	}
+	if err == nil && sub.OriginalPath != "" {
+		os.Remove(sub.OriginalPath)
+	}
 
 	if err := h.db.DeleteSubtitle(uint(id)); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -258,6 +408,129 @@ func (h *SubtitleHandler) DeleteStored(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// UploadStored handles POST /admin/api/subtitles/upload
+// Multipart form: file (SRT, ASS, VTT or a ZIP of them), imdb_code, and
+// optionally season and episode, language, release_name and hearing_impaired.
+// The language is detected from the file name or text when not given.
+func (h *SubtitleHandler) UploadStored(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxSubtitleUploadSize)
+	if err := r.ParseMultipartForm(services.MaxSubtitleUploadSize); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	hearingImpaired, _ := strconv.ParseBool(r.FormValue("hearing_impaired"))
+	results, err := h.subtitleService.StoreUpload(services.SubtitleUpload{
+		ImdbCode:        strings.TrimSpace(r.FormValue("imdb_code")),
+		FileName:        header.Filename,
+		Data:            data,
+		Language:        r.FormValue("language"),
+		ReleaseName:     r.FormValue("release_name"),
+		HearingImpaired: hearingImpaired,
+		Season:          parseInt(r.FormValue("season"), 0),
+		Episode:         parseInt(r.FormValue("episode"), 0),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored := 0
+	for _, res := range results {
+		if res.Subtitle != nil {
+			stored++
+		}
+	}
+	code, status := http.StatusOK, "ok"
+	if stored == 0 {
+		code, status = http.StatusUnprocessableEntity, "error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"stored": stored,
+		"files":  results,
+	})
+}
+
+// ListCues handles GET /admin/api/subtitles/{id}/cues
+// Returns the parsed cues of a stored subtitle for editing
+func (h *SubtitleHandler) ListCues(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	sub, err := h.db.GetSubtitleByID(uint(id))
+	if err != nil {
+		http.Error(w, "subtitle not found", http.StatusNotFound)
+		return
+	}
+	vtt, err := services.ReadStoredVTT(sub)
+	if err != nil {
+		http.Error(w, "subtitle file not found", http.StatusNotFound)
+		return
+	}
+
+	cues := services.ParseCues(vtt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    sub.ID,
+		"cues":  cues,
+		"count": len(cues),
+	})
+}
+
+// EditCues handles PATCH /admin/api/subtitles/{id}/cues
+// Body: {"edits": [{"index": 12, "text": "..."}, {"index": 40, "shift_ms": -500}, {"index": 41, "delete": true}]}
+// Edits refer to cue indexes as returned by ListCues and are applied together.
+func (h *SubtitleHandler) EditCues(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Edits []services.SubtitleCueEdit `json:"edits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Edits) == 0 {
+		http.Error(w, "edits required", http.StatusBadRequest)
+		return
+	}
+	sub, err := h.db.GetSubtitleByID(uint(id))
+	if err != nil {
+		http.Error(w, "subtitle not found", http.StatusNotFound)
+		return
+	}
+
+	cues, err := h.subtitleService.EditStoredCues(sub, req.Edits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("[Subtitles] Edited %d cues of subtitle %d", len(req.Edits), sub.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"cues":   cues,
+		"count":  len(cues),
+	})
+}
+
 // PreviewStored handles GET /admin/api/subtitles/{id}/preview
 // Returns the first 20 lines of VTT content for preview
 func (h *SubtitleHandler) PreviewStored(w http.ResponseWriter, r *http.Request) {
@@ -305,3 +578,53 @@ func (h *SubtitleHandler) Languages(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(services.GetSubtitleLanguages())
 }
+
+// ListProviders handles GET /admin/api/subtitles/providers
+// Returns every subtitle provider with its settings and success metrics
+func (h *SubtitleHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.subtitleService.ProviderStatuses())
+}
+
+// UpdateProvider handles PUT /admin/api/subtitles/providers/{name}
+func (h *SubtitleHandler) UpdateProvider(w http.ResponseWriter, r *http.Request) {
+	var req services.SubtitleProviderUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.subtitleService.UpdateProvider(chi.URLParam(r, "name"), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// ListLanguages handles GET /admin/api/subtitles/languages
+// Returns the auto-sync settings of every subtitle language
+func (h *SubtitleHandler) ListLanguages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.subtitleService.LanguageSettings())
+}
+
+// UpdateLanguage handles PUT /admin/api/subtitles/languages/{code}
+func (h *SubtitleHandler) UpdateLanguage(w http.ResponseWriter, r *http.Request) {
+	var req services.SubtitleLanguageUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.subtitleService.UpdateLanguage(chi.URLParam(r, "code"), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(setting)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
@@ -150,6 +151,7 @@ func main() {
 
 	// Initialize services
 	subtitleService := services.NewSubtitleServiceWithDB(db, subtitlesDir)
+	subtitleService.WarnMissingAPIKeys()
 	imdbService := services.NewIMDBService()
 
 	// Initialize handlers
@@ -158,20 +160,13 @@ func main() {
 	adminHandler := handlers.NewAdminHandler(db, torrentService)
 	adminHandler.SetTemplates(templates)
 	subtitleHandler := handlers.NewSubtitleHandler(subtitleService, db)
+	subtitleHandler.SetTorrentService(torrentService)
 	imdbHandler := handlers.NewIMDBHandler(imdbService)
 	configHandler := handlers.NewConfigHandler(db)
 
 	// Initialize auth middleware
 	auth := authMiddleware.NewAuthMiddleware(cfg.AdminPassword)
 
-	// Start session cleanup goroutine
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		for range ticker.C {
-			auth.CleanupExpiredSessions()
-		}
-	}()
-
 	// Create router
 	r := chi.NewRouter()
 
@@ -184,8 +179,43 @@ func main() {
 	// Health check
 	r.Get("/health", streamHandler.Health)
 
+	// Initialize metadata providers in configured fallback order
+	metadataService := services.NewMetadataService(imdbService, services.NewTMDBService(cfg.TmdbAPIKey))
+	metadataService.SetOrder(strings.Split(cfg.MetadataProviders, ","))
+	log.Printf("Metadata providers: %v", metadataService.Order())
+
+	// Cache upstream metadata responses so bulk refreshes stay under rate limits
+	metadataCache := services.NewResponseCache(db)
+	if err := metadataCache.SetTTLs(cfg.MetadataCacheTTLs); err != nil {
+		log.Printf("Ignoring METADATA_CACHE_TTLS: %v", err)
+	}
+	metadataService.SetCache(metadataCache)
+	metadataHandler := handlers.NewMetadataHandler(metadataService)
+
 	// Initialize sync service (for syncing movies from external sources)
 	syncService := services.NewSyncService(db, subtitlesDir)
+	syncService.SetMetadataService(metadataService)
+	syncService.SetSubtitleService(subtitleService)
+	if metadataService.SupportsTranslations() {
+		syncService.SetLocales(strings.Split(cfg.MetadataLocales, ","))
+	} else if strings.TrimSpace(cfg.MetadataLocales) != "" {
+		log.Printf("Metadata localization disabled: no active metadata provider supports translations")
+	}
+
+	// OMDB supplies Rotten Tomatoes and backs up IMDb/Metacritic ratings
+	omdbService.SetCache(metadataCache)
+	syncService.SetOMDBService(omdbService)
+	if precedence, err := services.ParseRatingsPrecedence(cfg.RatingsPrecedence); err != nil {
+		log.Printf("Ignoring RATINGS_PRECEDENCE: %v", err)
+	} else {
+		syncService.SetRatingsPrecedence(precedence)
+		log.Printf("Ratings precedence: %s", precedence)
+	}
+	if maxAge, err := time.ParseDuration(cfg.RatingsMaxAge); err != nil {
+		log.Printf("Ignoring RATINGS_MAX_AGE: %v", err)
+	} else {
+		syncService.SetRatingsMaxAge(maxAge)
+	}
 
 	// Wire sync service into APIHandler for search auto-import
 	apiHandler.SetSyncService(syncService)
@@ -199,16 +229,195 @@ func main() {
 
 	// Initialize curated handler
 	curatedHandler := handlers.NewCuratedHandler(db)
+	curatedHandler.SetSyncService(syncService)
+
+	duplicatesHandler := handlers.NewDuplicatesHandler(db)
 
 	// Initialize home handler
 	homeHandler := handlers.NewHomeHandler(db)
 
 	// Initialize channel handler
 	channelHandler := handlers.NewChannelHandler(db)
+	if failures, err := strconv.Atoi(cfg.ChannelHealthFailures); err != nil || failures < 1 {
+		log.Printf("Ignoring CHANNEL_HEALTH_FAILURES: %q", cfg.ChannelHealthFailures)
+	} else {
+		channelHandler.HealthService().SetFailureThreshold(failures)
+	}
+
+	// Local media library
+	libraryService := services.NewLibraryService(db, syncService)
+	libraryHandler := handlers.NewLibraryHandler(db, libraryService)
+
+	// Initialize person handler
+	personHandler := handlers.NewPersonHandler(db)
+
+	// Local image cache/resizer; API responses point at it when IMAGE_PROXY is on
+	var imageHandler *handlers.ImageHandler
+	if cfg.ImageProxy {
+		imageSecret := signingSecret(cfg.ImageProxySecret, "IMAGE_PROXY_SECRET", filepath.Join(filepath.Dir(cfg.DatabasePath), "image_proxy.secret"))
+		imageService := services.NewImageService(cfg.ImageCacheDir, imageSecret)
+		imageHandler = handlers.NewImageHandler(imageService)
+		imageRewriter := handlers.NewImageRewriter(imageService, cfg.PublicURL)
+		apiHandler.SetImageRewriter(imageRewriter)
+		seriesHandler.SetImageRewriter(imageRewriter)
+		channelHandler.SetImageRewriter(imageRewriter)
+		log.Printf("Image proxy enabled (cache: %s)", cfg.ImageCacheDir)
+	}
+
+	// IPTV restream proxy; segment URLs are signed with their own key so a
+	// leaked image key can't turn the proxy into an open relay
+	liveCacheTTL, err := time.ParseDuration(cfg.LiveCacheTTL)
+	if err != nil {
+		log.Printf("Ignoring LIVE_CACHE_TTL: %v", err)
+	}
+	liveCacheMB, err := strconv.Atoi(cfg.LiveCacheMB)
+	if err != nil {
+		log.Printf("Ignoring LIVE_CACHE_MB: %v", err)
+	}
+	liveSecret := signingSecret(cfg.LiveProxySecret, "LIVE_PROXY_SECRET", filepath.Join(filepath.Dir(cfg.DatabasePath), "live_proxy.secret"))
+	liveHandler := handlers.NewLiveHandler(db, services.NewHLSProxy(liveSecret, liveCacheTTL, liveCacheMB))
+
+	// Backfill people from existing cast_json/director/writers columns
+	go db.BackfillPeople()
 
 	// Initialize analytics handler
 	analyticsHandler := handlers.NewAnalyticsHandler(db, torrentService)
 
+	// Background jobs. Heavy jobs start paused; admins resume them from the jobs API.
+	scheduler := services.NewScheduler(db)
+	jobs := []services.Job{
+		{
+			Name:        "session_cleanup",
+			Description: "Remove expired admin sessions",
+			Schedule:    "@hourly",
+			Run: func(ctx context.Context, p *services.JobProgress) error {
+				auth.CleanupExpiredSessions()
+				return nil
+			},
+		},
+		{
+			Name:        "content_sync",
+			Description: "Rescan torrents and fill in missing subtitles for all movies",
+			Schedule:    "0 3 * * *",
+			Paused:      true,
+			Run:         syncService.SyncAll,
+		},
+		{
+			Name:        "refresh_movies",
+			Description: "Re-fetch metadata and ratings for every movie",
+			Schedule:    "0 2 * * 0",
+			Paused:      true,
+			Run:         syncService.RefreshAllMovies,
+		},
+		{
+			Name:        "refresh_series",
+			Description: "Re-fetch metadata, episodes and torrents for every series",
+			Schedule:    "0 2 * * 3",
+			Paused:      true,
+			Run:         syncService.RefreshAllSeries,
+		},
+		{
+			Name:        "imdb_top250",
+			Description: "Import the IMDb top 250 movies",
+			Schedule:    "0 6 1 * *",
+			Paused:      true,
+			Run: func(ctx context.Context, p *services.JobProgress) error {
+				_, err := syncService.ImportIMDBTop250(ctx, p)
+				return err
+			},
+		},
+		{
+			Name:        "imdb_latest",
+			Description: "Import recent IMDb releases, marking ones without torrents as coming soon",
+			Run: func(ctx context.Context, p *services.JobProgress) error {
+				_, err := syncService.ImportLatestMovies(ctx, p)
+				return err
+			},
+		},
+		{
+			Name:        "yts_featured",
+			Description: "Import YTS featured movies",
+			Run: func(ctx context.Context, p *services.JobProgress) error {
+				_, err := syncService.ImportYTSFeatured(ctx, p, ytsAPIBaseURL)
+				return err
+			},
+		},
+		{
+			Name:        "iptv_sync",
+			Description: "Import channels from the M3U sources whose own schedule is due",
+			Schedule:    "*/15 * * * *",
+			Paused:      true,
+			Run: func(ctx context.Context, p *services.JobProgress) error {
+				iptv := channelHandler.IPTVService()
+				err := iptv.Sync(ctx)
+				st := iptv.GetStatus()
+				p.SetTotal(st.Total)
+				p.Add(st.Progress, st.Channels, 0)
+				return err
+			},
+		},
+		{
+			Name:        "channel_health",
+			Description: "Check channel streams, soft-disabling dead ones and restoring recovered ones",
+			Schedule:    "0 5 * * 0",
+			Paused:      true,
+			Run: func(ctx context.Context, p *services.JobProgress) error {
+				st, err := channelHandler.HealthService().Check(ctx)
+				p.SetTotal(st.Total)
+				p.Add(st.Checked, st.Restored, st.Failed)
+				return err
+			},
+		},
+		{
+			Name:        "coming_soon_watch",
+			Description: "Search for torrents for coming-soon movies and mark them available",
+			Schedule:    "30 */2 * * *",
+			Run:         syncService.WatchComingSoon,
+		},
+		{
+			Name:        "episode_acquisition",
+			Description: "Search for torrents for newly aired episodes of ongoing series",
+			Schedule:    "15 * * * *",
+			Run:         syncService.AcquireNewEpisodes,
+		},
+		{
+			Name:        "library_scan",
+			Description: "Rescan local library folders for added and removed files",
+			Schedule:    "0 */6 * * *",
+			Run:         libraryService.ScanAll,
+		},
+		{
+			Name:        "list_import",
+			Description: "Import an uploaded IMDb, Letterboxd or Trakt list into a curated list",
+			Run:         syncService.RunListImport,
+		},
+		{
+			Name:        "subtitle_redecode",
+			Description: "Re-decode subtitles stored before charset detection that came out garbled",
+			Run:         subtitleService.RedecodeStoredSubtitles,
+		},
+		{
+			Name:        "subtitle_resync_missing",
+			Description: "Fill missing subtitles for every playable movie and episode under the language settings",
+			Run:         syncService.ResyncMissingSubtitles,
+		},
+		{
+			Name:        "subtitle_score",
+			Description: "Rescore stored subtitles and drop duplicates with the same content",
+			Run:         subtitleService.ScoreStoredSubtitles,
+		},
+	}
+	for _, job := range jobs {
+		if err := scheduler.Register(job); err != nil {
+			log.Printf("Failed to register job: %v", err)
+		}
+	}
+	scheduler.Start()
+	ratingsHandler.SetScheduler(scheduler)
+	libraryHandler.SetScheduler(scheduler)
+	curatedHandler.SetScheduler(scheduler)
+	jobsHandler := handlers.NewJobsHandler(scheduler)
+
 	// YTS-compatible API (public)
 	r.Route("/api/v2", func(r chi.Router) {
 		// Server config (client reads this to build sidebar)
@@ -227,12 +436,21 @@ func main() {
 		r.Get("/movie_suggestions.json", apiHandler.MovieSuggestions)
 		r.Get("/franchise_movies.json", apiHandler.FranchiseMovies)
 		r.Get("/check_availability", apiHandler.CheckAvailability)
+		r.Post("/availability/watch", apiHandler.WatchAvailability)
+		r.Post("/availability/unwatch", apiHandler.UnwatchAvailability)
+		r.Get("/availability/watches.json", apiHandler.AvailabilityWatches)
+		r.Get("/availability/events.json", apiHandler.AvailabilityEvents)
+
+		// People
+		r.Get("/person_details.json", personHandler.PersonDetails)
+		r.Get("/person_credits.json", personHandler.PersonCredits)
 
 		// Series
 		r.Get("/list_series.json", seriesHandler.ListSeries)
 		r.Get("/search_series_online.json", seriesHandler.SearchSeriesOnline)
 		r.Get("/series_details.json", seriesHandler.SeriesDetails)
 		r.Get("/season_episodes.json", seriesHandler.SeasonEpisodes)
+		r.Get("/upcoming_episodes.json", seriesHandler.UpcomingEpisodes)
 
 		// Channels (IPTV)
 		r.Get("/list_channels.json", channelHandler.ListChannels)
@@ -241,6 +459,8 @@ func main() {
 		r.Get("/channel_categories.json", channelHandler.ListCategories)
 		r.Get("/channels_by_country.json", channelHandler.GetChannelsByCountry)
 		r.Get("/channel_epg.json", channelHandler.GetEPG)
+		r.Get("/channels.m3u", channelHandler.ExportM3U)
+		r.Get("/epg.xml", channelHandler.ExportEPG)
 
 		// Ratings & Sync
 		r.Post("/get_ratings", ratingsHandler.GetRatings)
@@ -263,6 +483,8 @@ func main() {
 		r.Get("/subtitles/search_by_filename", subtitleHandler.SearchByFilename)
 		r.Get("/subtitles/download", subtitleHandler.Download)
 		r.Get("/subtitles/stored/{id}", subtitleHandler.ServeStored)
+		r.Get("/subtitles/stored/{id}/retime", subtitleHandler.Retime)
+		r.Post("/subtitles/stored/{id}/retime", subtitleHandler.Retime)
 		r.Get("/subtitle_languages", subtitleHandler.Languages)
 
 		// Stream management
@@ -278,9 +500,19 @@ func main() {
 	})
 
 	// Video streaming (public)
+	r.Get("/stream/local/{id}", streamHandler.StreamLocal)
 	r.Get("/stream/{infoHash}/{fileIndex}", streamHandler.Stream)
 	r.Get("/stats", streamHandler.Stats)
 
+	// Cached, resized images (public)
+	if imageHandler != nil {
+		r.Get("/img/{preset}/{sig}/{key}", imageHandler.Serve)
+	}
+
+	// IPTV restreaming (public)
+	r.Get("/live/{channelID}/index.m3u8", liveHandler.Playlist)
+	r.Get("/live/{channelID}/{sig}/{key}", liveHandler.Segment)
+
 	// Admin routes
 	r.Route("/admin", func(r chi.Router) {
 		// Public auth endpoints
@@ -435,6 +667,8 @@ func main() {
 			// Curated lists admin API
 			r.Get("/api/curated", curatedHandler.AdminListCuratedLists)
 			r.Post("/api/curated", curatedHandler.AdminCreateCuratedList)
+			r.Post("/api/curated/import", curatedHandler.AdminImportList)
+			r.Get("/api/curated/{id}/import", curatedHandler.AdminGetImportReport)
 			r.Put("/api/curated/{id}", curatedHandler.AdminUpdateCuratedList)
 			r.Delete("/api/curated/{id}", curatedHandler.AdminDeleteCuratedList)
 			r.Post("/api/curated/{id}/movies", curatedHandler.AdminAddMovieToList)
@@ -455,255 +689,70 @@ func main() {
 			r.Get("/api/subtitles", subtitleHandler.ListStored)
 			r.Delete("/api/subtitles/{id}", subtitleHandler.DeleteStored)
 			r.Get("/api/subtitles/{id}/preview", subtitleHandler.PreviewStored)
+			r.Post("/api/subtitles/upload", subtitleHandler.UploadStored)
+			r.Post("/api/subtitles/{id}/retime", subtitleHandler.SaveRetimed)
+			r.Get("/api/subtitles/{id}/cues", subtitleHandler.ListCues)
+			r.Patch("/api/subtitles/{id}/cues", subtitleHandler.EditCues)
+			r.Get("/api/subtitles/providers", subtitleHandler.ListProviders)
+			r.Put("/api/subtitles/providers/{name}", subtitleHandler.UpdateProvider)
+			r.Get("/api/subtitles/languages", subtitleHandler.ListLanguages)
+			r.Put("/api/subtitles/languages/{code}", subtitleHandler.UpdateLanguage)
 
 			// Services config admin API
 			r.Get("/api/services", configHandler.AdminListServices)
 			r.Put("/api/services", configHandler.AdminUpdateServices)
 
+			// Background jobs admin API
+			r.Get("/api/jobs", jobsHandler.ListJobs)
+			r.Get("/api/jobs/{name}", jobsHandler.GetJob)
+			r.Put("/api/jobs/{name}", jobsHandler.UpdateJob)
+			r.Post("/api/jobs/{name}/run", jobsHandler.TriggerJob)
+			r.Post("/api/jobs/{name}/pause", jobsHandler.PauseJob)
+			r.Post("/api/jobs/{name}/resume", jobsHandler.ResumeJob)
+			r.Post("/api/jobs/{name}/cancel", jobsHandler.CancelJob)
+			r.Get("/api/jobs/{name}/runs", jobsHandler.JobHistory)
+			r.Get("/api/jobs/runs/{id}", jobsHandler.RunStatus)
+			r.Post("/api/jobs/runs/{id}/cancel", jobsHandler.CancelRun)
+
+			r.Get("/api/episodes/acquisitions", seriesHandler.ListAcquisitions)
+			r.Get("/api/availability/events", apiHandler.AdminAvailabilityEvents)
+
+			// Local media library
+			r.Get("/api/library/folders", libraryHandler.ListFolders)
+			r.Post("/api/library/folders", libraryHandler.CreateFolder)
+			r.Put("/api/library/folders/{id}", libraryHandler.UpdateFolder)
+			r.Delete("/api/library/folders/{id}", libraryHandler.DeleteFolder)
+			r.Post("/api/library/scan", jobsHandler.StartJob("library_scan"))
+			r.Get("/api/library/files", libraryHandler.ListFiles)
+			r.Put("/api/library/files/{id}", libraryHandler.MatchFile)
+
+			// Metadata cache admin API
+			r.Get("/api/metadata-cache/stats", metadataHandler.CacheStats)
+			r.Delete("/api/metadata-cache", metadataHandler.PurgeCache)
+
 			// Channels admin API (IPTV sync)
 			r.Post("/api/channels/sync", channelHandler.SyncIPTV)
 			r.Get("/api/channels/sync/status", channelHandler.SyncStatus)
 			r.Get("/api/channels/stats", channelHandler.ChannelStats)
+			r.Get("/api/channels/proxy/stats", liveHandler.Stats)
 			r.Get("/api/channels/settings", channelHandler.GetChannelSettings)
 			r.Put("/api/channels/settings", channelHandler.UpdateM3UURL)
+			r.Get("/api/channels/sources", channelHandler.ListSources)
+			r.Post("/api/channels/sources", channelHandler.CreateSource)
+			r.Put("/api/channels/sources/{id}", channelHandler.UpdateSource)
+			r.Delete("/api/channels/sources/{id}", channelHandler.DeleteSource)
+			r.Post("/api/channels/sources/{id}/sync", channelHandler.SyncSource)
 			r.Post("/api/channels/health-check", channelHandler.StartHealthCheck)
 			r.Get("/api/channels/health-check/status", channelHandler.GetHealthCheckStatus)
+			r.Get("/api/channels/{id}/health", channelHandler.GetChannelHealth)
+			r.Post("/api/channels/{id}/health-check", channelHandler.CheckChannelHealth)
 			r.Delete("/api/channels/blocklist", channelHandler.ClearBlocklist)
 			r.Delete("/api/channels/{id}", channelHandler.DeleteChannel)
 
-			// YTS Sync Featured
-			r.Post("/api/yts/sync-featured", func(w http.ResponseWriter, rq *http.Request) {
-				// Fetch featured movies from YTS
-				client := &http.Client{Timeout: 15 * time.Second}
-				apiURL := ytsAPIBaseURL + "/list_movies.json?sort_by=featured&limit=20"
-				resp, err := client.Get(apiURL)
-				if err != nil {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusBadGateway)
-					json.NewEncoder(w).Encode(map[string]string{"error": "YTS API unavailable: " + err.Error()})
-					return
-				}
-				defer resp.Body.Close()
-
-				var ytsResp struct {
-					Status string `json:"status"`
-					Data   struct {
-						Movies []struct {
-							IMDBCode string `json:"imdb_code"`
-							Title    string `json:"title"`
-							Year     int    `json:"year"`
-						} `json:"movies"`
-					} `json:"data"`
-				}
-				if err := json.NewDecoder(resp.Body).Decode(&ytsResp); err != nil {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusInternalServerError)
-					json.NewEncoder(w).Encode(map[string]string{"error": "Failed to parse YTS response"})
-					return
-				}
-
-				imported := 0
-				skipped := 0
-				var errors []string
-				for _, m := range ytsResp.Data.Movies {
-					if m.IMDBCode == "" {
-						skipped++
-						continue
-					}
-					// Check if already in DB
-					existing, _ := db.GetMovieByIMDB(m.IMDBCode)
-					if existing != nil {
-						skipped++
-						continue
-					}
-					_, err := syncService.SyncMovie(m.IMDBCode)
-					if err != nil {
-						log.Printf("[SyncFeatured] Failed to sync %s (%s): %v", m.Title, m.IMDBCode, err)
-						errors = append(errors, fmt.Sprintf("%s: %v", m.Title, err))
-						continue
-					}
-					imported++
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"status":   "ok",
-					"imported": imported,
-					"skipped":  skipped,
-					"errors":   errors,
-					"total":    len(ytsResp.Data.Movies),
-				})
-			})
-
-			// IMDB Top 250 Sync
-			r.Post("/api/imdb/sync-top250", func(w http.ResponseWriter, rq *http.Request) {
-				// Use the imdbapi.dev /titles endpoint sorted by rating
-				client := &http.Client{Timeout: 30 * time.Second}
-
-				imported := 0
-				skipped := 0
-				var errors []string
-				pageToken := ""
-				targetCount := 250
-
-				for imported+skipped < targetCount {
-					apiURL := fmt.Sprintf("%s/titles?types=MOVIE&sortBy=SORT_BY_USER_RATING&sortOrder=DESC&minVoteCount=25000", imdbAPIBaseURL)
-					if pageToken != "" {
-						apiURL += "&pageToken=" + url.QueryEscape(pageToken)
-					}
-
-					resp, err := client.Get(apiURL)
-					if err != nil {
-						log.Printf("[SyncTop250] API error: %v", err)
-						break
-					}
-
-					var imdbResp struct {
-						Titles []struct {
-							ID           string `json:"id"`
-							PrimaryTitle string `json:"primaryTitle"`
-							StartYear    int    `json:"startYear"`
-						} `json:"titles"`
-						NextPageToken string `json:"nextPageToken"`
-					}
-					if err := json.NewDecoder(resp.Body).Decode(&imdbResp); err != nil {
-						resp.Body.Close()
-						log.Printf("[SyncTop250] Decode error: %v", err)
-						break
-					}
-					resp.Body.Close()
-
-					if len(imdbResp.Titles) == 0 {
-						break
-					}
-
-					for _, t := range imdbResp.Titles {
-						if imported+skipped >= targetCount {
-							break
-						}
-						if t.ID == "" {
-							skipped++
-							continue
-						}
-						// Check if already in DB
-						existing, _ := db.GetMovieByIMDB(t.ID)
-						if existing != nil {
-							skipped++
-							continue
-						}
-						_, err := syncService.SyncMovie(t.ID)
-						if err != nil {
-							log.Printf("[SyncTop250] Failed to sync %s (%s): %v", t.PrimaryTitle, t.ID, err)
-							errors = append(errors, fmt.Sprintf("%s: %v", t.PrimaryTitle, err))
-							skipped++
-							continue
-						}
-						imported++
-					}
-
-					if imdbResp.NextPageToken == "" {
-						break
-					}
-					pageToken = imdbResp.NextPageToken
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"status":   "ok",
-					"imported": imported,
-					"skipped":  skipped,
-					"errors":   errors,
-				})
-			})
-
-			// Sync Latest Movies (from IMDB, mark as coming_soon if no torrents)
-			r.Post("/api/imdb/sync-latest", func(w http.ResponseWriter, rq *http.Request) {
-				client := &http.Client{Timeout: 30 * time.Second}
-				currentYear := time.Now().Year()
-
-				imported := 0
-				comingSoon := 0
-				skipped := 0
-				var syncErrors []string
-				pageToken := ""
-				targetCount := 50
-
-				for imported+comingSoon+skipped < targetCount {
-					apiURL := fmt.Sprintf("%s/titles?types=MOVIE&sortBy=SORT_BY_RELEASE_DATE&sortOrder=DESC&startYear=%d&minVoteCount=1000", imdbAPIBaseURL, currentYear-1)
-					if pageToken != "" {
-						apiURL += "&pageToken=" + url.QueryEscape(pageToken)
-					}
-
-					resp, err := client.Get(apiURL)
-					if err != nil {
-						log.Printf("[SyncLatest] API error: %v", err)
-						break
-					}
-
-					var imdbResp struct {
-						Titles []struct {
-							ID           string `json:"id"`
-							PrimaryTitle string `json:"primaryTitle"`
-							StartYear    int    `json:"startYear"`
-						} `json:"titles"`
-						NextPageToken string `json:"nextPageToken"`
-					}
-					if err := json.NewDecoder(resp.Body).Decode(&imdbResp); err != nil {
-						resp.Body.Close()
-						log.Printf("[SyncLatest] Decode error: %v", err)
-						break
-					}
-					resp.Body.Close()
-
-					if len(imdbResp.Titles) == 0 {
-						break
-					}
-
-					for _, t := range imdbResp.Titles {
-						if imported+comingSoon+skipped >= targetCount {
-							break
-						}
-						if t.ID == "" {
-							skipped++
-							continue
-						}
-						existing, _ := db.GetMovieByIMDB(t.ID)
-						if existing != nil {
-							skipped++
-							continue
-						}
-						movie, err := syncService.SyncMovie(t.ID)
-						if err != nil {
-							log.Printf("[SyncLatest] Failed to sync %s (%s): %v", t.PrimaryTitle, t.ID, err)
-							syncErrors = append(syncErrors, fmt.Sprintf("%s: %v", t.PrimaryTitle, err))
-							skipped++
-							continue
-						}
-						// Check if movie got any torrents — if not, mark as coming_soon
-						if len(movie.Torrents) == 0 {
-							movie.Status = "coming_soon"
-							db.UpdateMovie(movie)
-							comingSoon++
-						} else {
-							imported++
-						}
-					}
-
-					if imdbResp.NextPageToken == "" {
-						break
-					}
-					pageToken = imdbResp.NextPageToken
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"status":      "ok",
-					"imported":    imported,
-					"coming_soon": comingSoon,
-					"skipped":     skipped,
-					"errors":      syncErrors,
-				})
-			})
+			// Long-running imports run as jobs; poll /api/jobs/runs/{job_id} for progress
+			r.Post("/api/yts/sync-featured", jobsHandler.StartJob("yts_featured"))
+			r.Post("/api/imdb/sync-top250", jobsHandler.StartJob("imdb_top250"))
+			r.Post("/api/imdb/sync-latest", jobsHandler.StartJob("imdb_latest"))
 
 			// Scan all movies for new YTS torrents
 			r.Post("/api/yts/scan-torrents", func(w http.ResponseWriter, rq *http.Request) {
@@ -721,6 +770,10 @@ func main() {
 			r.Post("/api/refresh_all_movies", ratingsHandler.RefreshAllMovies)
 			r.Post("/api/refresh_all_series", ratingsHandler.RefreshAllSeries)
 
+			// Duplicate finder
+			r.Get("/api/duplicates", duplicatesHandler.ListDuplicates)
+			r.Post("/api/duplicates/merge", duplicatesHandler.MergeDuplicates)
+
 			// Move movie to series (when IMDB type is actually a series)
 			r.Post("/api/move-to-series", func(w http.ResponseWriter, rq *http.Request) {
 				var req struct {
@@ -751,9 +804,9 @@ func main() {
 					series = refreshed
 				}
 
-				// Delete the movie
-				if err := db.DeleteMovie(req.MovieID); err != nil {
-					log.Printf("[MoveToSeries] Warning: failed to delete movie %d: %v", req.MovieID, err)
+				// Move home section references and analytics over, then delete the movie
+				if _, err := db.MergeMovieIntoSeries(req.MovieID, series.ID); err != nil {
+					log.Printf("[MoveToSeries] Warning: failed to merge movie %d: %v", req.MovieID, err)
 				}
 
 				w.Header().Set("Content-Type", "application/json")
@@ -1115,6 +1168,7 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 		log.Println("Shutting down...")
+		scheduler.Stop()
 		torrentService.Close()
 		db.Close()
 		os.Exit(0)
@@ -1123,9 +1177,22 @@ func main() {
 	if err := http.ListenAndServe(serverAddr, r); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
+}
 
-	// Keep reference to avoid unused import error
-	_ = omdbService
+// signingSecret returns the configured URL signing key, or the one generated
+// and kept at path when none is configured. Short keys stop startup.
+func signingSecret(configured, envName, path string) string {
+	if configured == "" {
+		secret, err := services.LoadSecret(path)
+		if err != nil {
+			log.Fatalf("Failed to load %s: %v", filepath.Base(path), err)
+		}
+		return secret
+	}
+	if len(configured) < services.MinSecretLength {
+		log.Fatalf("%s must be at least %d characters", envName, services.MinSecretLength)
+	}
+	return configured
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// AvailabilityWatch records a device's interest in a coming-soon title
+type AvailabilityWatch struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	DeviceID  string    `json:"device_id" gorm:"uniqueIndex:idx_availability_watch;not null"`
+	ImdbCode  string    `json:"imdb_code" gorm:"uniqueIndex:idx_availability_watch;index;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AvailabilityWatch) TableName() string {
+	return "availability_watches"
+}
+
+// AvailabilityEvent is emitted when a coming-soon movie gets its first torrents
+type AvailabilityEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	MovieID   uint      `json:"movie_id" gorm:"index"`
+	ImdbCode  string    `json:"imdb_code" gorm:"index"`
+	Title     string    `json:"title"`
+	Year      uint      `json:"year"`
+	Poster    string    `json:"poster,omitempty"`
+	Torrents  int       `json:"torrents"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AvailabilityEvent) TableName() string {
+	return "availability_events"
+}
+
+// AvailabilityEventsData is the response for a device's availability events.
+// Clients pass NextSince back as since to only get newer events.
+type AvailabilityEventsData struct {
+	DeviceID   string              `json:"device_id"`
+	NextSince  uint                `json:"next_since"`
+	EventCount int                 `json:"event_count"`
+	Events     []AvailabilityEvent `json:"events"`
+}
@@ -12,10 +12,53 @@ type Channel struct {
 	StreamURL  string      `json:"stream_url,omitempty"`
 	IsNSFW     bool        `json:"is_nsfw,omitempty" gorm:"default:false"`
 	Website    string      `json:"website,omitempty"`
-	CreatedAt  time.Time   `json:"-" gorm:"autoCreateTime"`
-	UpdatedAt  time.Time   `json:"-" gorm:"autoUpdateTime"`
+	SourceID   uint        `json:"source_id,omitempty" gorm:"index;default:0"` // IPTV source the channel was imported from
+	// Health check state; soft-disabled channels are hidden until they recover
+	Disabled      bool       `json:"disabled,omitempty" gorm:"index;default:false"`
+	FailCount     int        `json:"fail_count,omitempty" gorm:"default:0"` // consecutive failed checks
+	LatencyMs     int        `json:"latency_ms,omitempty" gorm:"default:0"` // of the last passed check
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	CreatedAt     time.Time  `json:"-" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"-" gorm:"autoUpdateTime"`
 }
 
+// ChannelHealthCheck is one health check of a channel's stream. Stage is
+// where a failed check stopped: playlist, variant, segment or stream.
+type ChannelHealthCheck struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ChannelID  string    `json:"channel_id" gorm:"index;not null"`
+	OK         bool      `json:"ok"`
+	Stage      string    `json:"stage,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	LatencyMs  int       `json:"latency_ms"`
+	Attempts   int       `json:"attempts"`
+	CheckedAt  time.Time `json:"checked_at" gorm:"index"`
+}
+
+func (ChannelHealthCheck) TableName() string { return "channel_health_checks" }
+
+// IPTVSource is an M3U playlist channels are imported from. When several
+// sources list the same channel, the one with the lowest priority keeps it.
+type IPTVSource struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"uniqueIndex;not null"`
+	URL      string `json:"url" gorm:"not null"` // http(s) URL, or a local file path
+	Enabled  bool   `json:"enabled"`
+	Priority int    `json:"priority"`           // lower wins duplicate channels
+	Schedule string `json:"schedule,omitempty"` // cron expression; empty syncs by hand only
+	// Overrides applied to every channel of the source; empty keeps the playlist's values
+	Country      string     `json:"country,omitempty"`
+	Category     string     `json:"category,omitempty"`
+	LastSyncAt   *time.Time `json:"last_sync_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	ChannelCount int        `json:"channel_count" gorm:"-"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (IPTVSource) TableName() string { return "iptv_sources" }
+
 type ChannelCountry struct {
 	Code         string `json:"code" gorm:"primaryKey"`
 	Name         string `json:"name" gorm:"not null"`
@@ -0,0 +1,34 @@
+package models
+
+// DuplicateCandidate is the catalog entry summary the duplicate finder compares
+type DuplicateCandidate struct {
+	ID         uint     `json:"id"`
+	Type       string   `json:"type"` // movie or series
+	ImdbCode   string   `json:"imdb_code"`
+	Title      string   `json:"title"`
+	Year       uint     `json:"year"`
+	Runtime    uint     `json:"runtime"`
+	Rating     float32  `json:"rating"`
+	ImdbRating *float32 `json:"imdb_rating,omitempty"`
+	Torrents   int      `json:"torrents"`
+}
+
+// DuplicatePair is two entries that look like the same title. Survivor is
+// the one suggested to keep when merging.
+type DuplicatePair struct {
+	Kind       string             `json:"kind"` // movie (two movies) or movie_series (a series stored as a movie)
+	Similarity float64            `json:"similarity"`
+	Reasons    []string           `json:"reasons"`
+	Survivor   DuplicateCandidate `json:"survivor"`
+	Duplicate  DuplicateCandidate `json:"duplicate"`
+}
+
+// MergeResult counts what a merge moved to the survivor
+type MergeResult struct {
+	Torrents     int `json:"torrents"`
+	Subtitles    int `json:"subtitles"`
+	CuratedLists int `json:"curated_lists"`
+	HomeSections int `json:"home_sections"`
+	Views        int `json:"views"`
+	LocalFiles   int `json:"local_files"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Job run statuses
+const (
+	JobStatusRunning     = "running"
+	JobStatusSuccess     = "success"
+	JobStatusFailed      = "failed"
+	JobStatusCancelled   = "cancelled"
+	JobStatusInterrupted = "interrupted"
+)
+
+// JobRun records one execution of a scheduled job
+type JobRun struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Job        string     `json:"job" gorm:"index;not null"`
+	Trigger    string     `json:"trigger"` // "schedule" or "manual"
+	Status     string     `json:"status" gorm:"index"`
+	StartedAt  time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Added      int        `json:"added"`
+	Failed     int        `json:"failed"`
+	Extra      IntMap     `json:"extra,omitempty" gorm:"type:text"` // job-specific counts, e.g. coming_soon
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+}
+
+func (JobRun) TableName() string { return "job_runs" }
+
+// JobSetting persists admin overrides for a job across restarts
+type JobSetting struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	Schedule  string    `json:"schedule"`
+	Paused    bool      `json:"paused"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (JobSetting) TableName() string { return "job_settings" }
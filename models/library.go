@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Library folder kinds
+const (
+	LibraryKindAuto   = "auto"
+	LibraryKindMovies = "movies"
+	LibraryKindSeries = "series"
+)
+
+// LibraryFolder is a directory of local media files scanned into the library
+type LibraryFolder struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Path         string     `json:"path" gorm:"uniqueIndex;not null"`
+	Kind         string     `json:"kind" gorm:"default:'auto'"` // auto, movies or series
+	Enabled      bool       `json:"enabled" gorm:"default:true"`
+	FileCount    int        `json:"file_count" gorm:"default:0"`
+	MatchedCount int        `json:"matched_count" gorm:"default:0"`
+	LastScanAt   *time.Time `json:"last_scan_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (LibraryFolder) TableName() string { return "library_folders" }
+
+// LocalFile is a video file on disk, matched to a movie or an episode.
+// It is served by /stream/local/{id} as an alternative to torrents. Its path
+// is only shown to admins, through AdminLocalFile.
+type LocalFile struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	FolderID  uint      `json:"folder_id" gorm:"index;not null"`
+	Path      string    `json:"-" gorm:"uniqueIndex;not null"`
+	FileName  string    `json:"file_name"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mod_time"`
+	Quality   string    `json:"quality,omitempty"`
+
+	// What was parsed from the filename, folder and NFO
+	ParsedTitle   string `json:"parsed_title,omitempty"`
+	ParsedYear    int    `json:"parsed_year,omitempty"`
+	SeasonNumber  int    `json:"season_number,omitempty"`
+	EpisodeNumber int    `json:"episode_number,omitempty"`
+
+	// Match result. MatchedBy is "nfo", "filename", "search" or "manual";
+	// empty when unmatched, with the reason in MatchError.
+	ImdbCode   string `json:"imdb_code,omitempty" gorm:"index"`
+	MovieID    *uint  `json:"movie_id,omitempty" gorm:"index"`
+	EpisodeID  *uint  `json:"episode_id,omitempty" gorm:"index"`
+	MatchedBy  string `json:"matched_by,omitempty"`
+	MatchError string `json:"match_error,omitempty"`
+
+	// Not stored in DB
+	StreamURL string `json:"stream_url" gorm:"-"`
+
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (LocalFile) TableName() string { return "local_files" }
+
+// AdminLocalFile is a LocalFile with its path on disk, for admin endpoints
+type AdminLocalFile struct {
+	LocalFile
+	Path string `json:"path"`
+}
+
+func NewAdminLocalFile(f LocalFile) AdminLocalFile {
+	return AdminLocalFile{LocalFile: f, Path: f.Path}
+}
+
+func (f *LocalFile) AfterFind(tx *gorm.DB) error {
+	f.StreamURL = fmt.Sprintf("/stream/local/%d", f.ID)
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Localization holds translated metadata for one movie, series or episode.
+// Empty fields fall back to the English values on the content itself.
+type Localization struct {
+	ID          uint      `json:"-" gorm:"primaryKey"`
+	ContentType string    `json:"content_type" gorm:"uniqueIndex:idx_localization;not null"` // movie, series, episode
+	ContentID   uint      `json:"content_id" gorm:"uniqueIndex:idx_localization;not null"`
+	Locale      string    `json:"locale" gorm:"uniqueIndex:idx_localization;not null"`
+	Title       string    `json:"title,omitempty"`
+	Summary     string    `json:"summary,omitempty" gorm:"type:text"`
+	Tagline     string    `json:"tagline,omitempty"`
+	PosterImage string    `json:"poster_image,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Localization) TableName() string { return "localizations" }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// MetadataCacheEntry is a cached upstream metadata response body
+type MetadataCacheEntry struct {
+	Key       string    `json:"key" gorm:"column:cache_key;primaryKey"`
+	Provider  string    `json:"provider" gorm:"index;not null"`
+	Endpoint  string    `json:"endpoint" gorm:"index;not null"`
+	ImdbCode  string    `json:"imdb_code" gorm:"index"`
+	Body      string    `json:"-" gorm:"type:text"`
+	FetchedAt time.Time `json:"fetched_at" gorm:"index"`
+}
+
+func (MetadataCacheEntry) TableName() string { return "metadata_cache" }
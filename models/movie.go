@@ -17,6 +17,7 @@ type Movie struct {
 	Summary                 string      `json:"summary"`
 	DescriptionFull         string      `json:"description_full"`
 	Synopsis                string      `json:"synopsis"`
+	Tagline                 string      `json:"tagline,omitempty"`
 	YtTrailerCode           string      `json:"yt_trailer_code" gorm:"column:yt_trailer_code"`
 	Language                string      `json:"language" gorm:"default:'en'"`
 	MpaRating               string      `json:"mpa_rating,omitempty"`
@@ -30,7 +31,8 @@ type Movie struct {
 	CreatedAt               time.Time   `json:"created_at,omitempty" gorm:"autoCreateTime"`
 
 	// Relationships
-	Torrents []Torrent `json:"torrents" gorm:"foreignKey:MovieID;constraint:OnDelete:CASCADE"`
+	Torrents   []Torrent   `json:"torrents" gorm:"foreignKey:MovieID;constraint:OnDelete:CASCADE"`
+	LocalFiles []LocalFile `json:"local_files,omitempty" gorm:"foreignKey:MovieID"`
 
 	// Rich data (JSON-serialized in DB)
 	Cast       CastSlice   `json:"cast,omitempty" gorm:"column:cast_json;type:text"`
@@ -48,6 +50,9 @@ type Movie struct {
 	ContentType string `json:"content_type,omitempty" gorm:"default:'movie'"`
 	Provider    string `json:"provider,omitempty"`
 
+	// Metadata provider that supplied each field (e.g. {"plot": "tmdb"})
+	MetadataSources StringMap `json:"metadata_sources,omitempty" gorm:"column:metadata_sources;type:text"`
+
 	// External ratings
 	ImdbRating       *float32 `json:"imdb_rating,omitempty"`
 	ImdbVotes        string   `json:"imdb_votes,omitempty"`
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Person is an actor, director or writer shared across movies and series
+type Person struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ImdbCode  string    `json:"imdb_code,omitempty" gorm:"index"` // nm..., empty when only the name is known
+	Name      string    `json:"name" gorm:"index;not null"`
+	ImageURL  string    `json:"image_url,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (Person) TableName() string { return "people" }
+
+// Credit roles
+const (
+	RoleActor    = "actor"
+	RoleDirector = "director"
+	RoleWriter   = "writer"
+)
+
+// PersonCredit links a person to a movie or series in a role
+type PersonCredit struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	PersonID      uint   `json:"person_id" gorm:"uniqueIndex:idx_person_credit;not null"`
+	ContentType   string `json:"content_type" gorm:"uniqueIndex:idx_person_credit;index:idx_credit_content;not null"` // movie, series
+	ContentID     uint   `json:"content_id" gorm:"uniqueIndex:idx_person_credit;index:idx_credit_content;not null"`
+	Role          string `json:"role" gorm:"uniqueIndex:idx_person_credit;not null"`
+	CharacterName string `json:"character_name,omitempty"`
+	BillingOrder  int    `json:"billing_order"`
+}
+
+func (PersonCredit) TableName() string { return "person_credits" }
+
+// PersonCreditItem is a credit joined with the title it belongs to
+type PersonCreditItem struct {
+	ContentType   string  `json:"content_type"`
+	ContentID     uint    `json:"content_id"`
+	ImdbCode      string  `json:"imdb_code"`
+	Title         string  `json:"title"`
+	Year          uint    `json:"year"`
+	Rating        float32 `json:"rating"`
+	PosterImage   string  `json:"poster_image"`
+	Role          string  `json:"role"`
+	CharacterName string  `json:"character_name,omitempty"`
+}
@@ -1,28 +1,36 @@
 package models
 
+import "time"
+
 type Series struct {
-	ID              uint        `json:"id" gorm:"primaryKey"`
-	ImdbCode        string      `json:"imdb_code" gorm:"uniqueIndex"`
-	TvdbID          *uint       `json:"tvdb_id,omitempty"`
-	Title           string      `json:"title" gorm:"not null"`
-	TitleSlug       string      `json:"title_slug"`
-	Year            uint        `json:"year"`
-	EndYear         *uint       `json:"end_year,omitempty"`
-	Rating          float32     `json:"rating" gorm:"default:0"`
-	Runtime         uint        `json:"runtime" gorm:"default:0"`
-	Genres          StringSlice `json:"genres" gorm:"column:genres;type:text"`
-	Summary         string      `json:"summary"`
-	Status          string      `json:"status" gorm:"default:'ongoing'"`
-	Network         string      `json:"network,omitempty"`
-	PosterImage     string      `json:"poster_image"`
-	BackgroundImage string      `json:"background_image"`
-	TotalSeasons    uint        `json:"total_seasons" gorm:"default:0"`
-	TotalEpisodes   uint        `json:"total_episodes" gorm:"default:0"`
-	DateAdded       string      `json:"date_added"`
-	DateAddedUnix   int64       `json:"date_added_unix"`
-	ImdbRating      *float32    `json:"imdb_rating,omitempty"`
-	RottenTomatoes  *int        `json:"rotten_tomatoes,omitempty"`
-	Franchise       string      `json:"franchise,omitempty"`
+	ID               uint        `json:"id" gorm:"primaryKey"`
+	ImdbCode         string      `json:"imdb_code" gorm:"uniqueIndex"`
+	TvdbID           *uint       `json:"tvdb_id,omitempty"`
+	Title            string      `json:"title" gorm:"not null"`
+	TitleSlug        string      `json:"title_slug"`
+	Year             uint        `json:"year"`
+	EndYear          *uint       `json:"end_year,omitempty"`
+	Rating           float32     `json:"rating" gorm:"default:0"`
+	Runtime          uint        `json:"runtime" gorm:"default:0"`
+	Genres           StringSlice `json:"genres" gorm:"column:genres;type:text"`
+	Summary          string      `json:"summary"`
+	Tagline          string      `json:"tagline,omitempty"`
+	Status           string      `json:"status" gorm:"default:'ongoing'"`
+	Network          string      `json:"network,omitempty"`
+	PosterImage      string      `json:"poster_image"`
+	BackgroundImage  string      `json:"background_image"`
+	TotalSeasons     uint        `json:"total_seasons" gorm:"default:0"`
+	TotalEpisodes    uint        `json:"total_episodes" gorm:"default:0"`
+	DateAdded        string      `json:"date_added"`
+	DateAddedUnix    int64       `json:"date_added_unix"`
+	ImdbRating       *float32    `json:"imdb_rating,omitempty"`
+	RottenTomatoes   *int        `json:"rotten_tomatoes,omitempty"`
+	Metacritic       *int        `json:"metacritic,omitempty"`
+	RatingsUpdatedAt string      `json:"ratings_updated_at,omitempty"`
+	Franchise        string      `json:"franchise,omitempty"`
+
+	// Metadata provider that supplied each field (e.g. {"plot": "tmdb"})
+	MetadataSources StringMap `json:"metadata_sources,omitempty" gorm:"column:metadata_sources;type:text"`
 
 	// Relationships
 	Seasons  []Season  `json:"seasons,omitempty" gorm:"foreignKey:SeriesID;constraint:OnDelete:CASCADE"`
@@ -56,7 +64,8 @@ type Episode struct {
 	ImdbCode      string `json:"imdb_code,omitempty"`
 
 	// Relationships
-	Torrents []EpisodeTorrent `json:"torrents,omitempty" gorm:"foreignKey:EpisodeID;constraint:OnDelete:CASCADE"`
+	Torrents   []EpisodeTorrent `json:"torrents,omitempty" gorm:"foreignKey:EpisodeID;constraint:OnDelete:CASCADE"`
+	LocalFiles []LocalFile      `json:"local_files,omitempty" gorm:"foreignKey:EpisodeID"`
 }
 
 func (Episode) TableName() string { return "episodes" }
@@ -97,6 +106,31 @@ type SeasonPack struct {
 
 func (SeasonPack) TableName() string { return "season_packs" }
 
+// Episode acquisition statuses
+const (
+	AcquisitionSearching = "searching"
+	AcquisitionAcquired  = "acquired"
+	AcquisitionGaveUp    = "gave_up"
+)
+
+// EpisodeAcquisition tracks the automatic torrent search for a newly aired episode
+type EpisodeAcquisition struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	EpisodeID     uint       `json:"episode_id" gorm:"uniqueIndex;not null"`
+	SeriesID      uint       `json:"series_id" gorm:"index"`
+	SeasonNumber  uint       `json:"season_number"`
+	EpisodeNumber uint       `json:"episode_number"`
+	AirDate       string     `json:"air_date"`
+	Status        string     `json:"status" gorm:"index"`
+	Attempts      int        `json:"attempts"`
+	Torrents      int        `json:"torrents"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"index"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+func (EpisodeAcquisition) TableName() string { return "episode_acquisitions" }
+
 // API response wrappers
 
 type SeriesListData struct {
@@ -109,3 +143,27 @@ type SeriesListData struct {
 type SeriesDetailsData struct {
 	Series Series `json:"series"`
 }
+
+// UpcomingEpisode is one entry of the episode air calendar
+type UpcomingEpisode struct {
+	SeriesID          uint   `json:"series_id"`
+	SeriesTitle       string `json:"series_title"`
+	SeriesImdbCode    string `json:"series_imdb_code"`
+	PosterImage       string `json:"poster_image,omitempty"`
+	EpisodeID         uint   `json:"episode_id"`
+	SeasonNumber      uint   `json:"season_number"`
+	EpisodeNumber     uint   `json:"episode_number"`
+	Title             string `json:"title"`
+	AirDate           string `json:"air_date"`
+	StillImage        string `json:"still_image,omitempty"`
+	TorrentCount      int    `json:"torrent_count"`
+	Available         bool   `json:"available"`
+	AcquisitionStatus string `json:"acquisition_status,omitempty"`
+}
+
+type UpcomingEpisodesData struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Count    int               `json:"episode_count"`
+	Episodes []UpcomingEpisode `json:"episodes"`
+}
@@ -12,9 +12,51 @@ type StoredSubtitle struct {
 	Source          string    `json:"source"`
 	VTTContent      string    `json:"-" gorm:"column:vtt_content"`
 	VTTPath         string    `json:"-" gorm:"column:vtt_path;default:''"`
+	OriginalPath    string    `json:"-" gorm:"column:original_path;default:''"` // source file before VTT conversion
+	OriginalFormat  string    `json:"original_format,omitempty"`                // srt, ass or vtt
+	Charset         string    `json:"charset,omitempty"`                        // encoding the file was decoded from; empty before detection
+	Score           int       `json:"score"`                                    // 0-100 quality score; 0 until scored
+	Fingerprint     string    `json:"-" gorm:"index;default:''"`                // hash of the cue text and timing, for finding duplicates
 	SeasonNumber    int       `json:"season_number,omitempty" gorm:"default:0"`
 	EpisodeNumber   int       `json:"episode_number,omitempty" gorm:"default:0"`
 	CreatedAt       time.Time `json:"created_at,omitempty" gorm:"autoCreateTime"`
 }
 
 func (StoredSubtitle) TableName() string { return "subtitles" }
+
+// SubtitleProviderSetting is the admin configuration of a subtitle provider.
+// Providers without a row use their built-in defaults.
+type SubtitleProviderSetting struct {
+	Name              string    `json:"name" gorm:"primaryKey"`
+	Enabled           bool      `json:"enabled"`
+	Priority          int       `json:"priority"` // lower is searched first
+	APIKey            string    `json:"-"`
+	UseForSync        bool      `json:"use_for_sync"`        // downloads are stored by the subtitle sync
+	MovieLimit        int       `json:"movie_limit"`         // subtitles stored per language per movie
+	EpisodeLimit      int       `json:"episode_limit"`       // subtitles stored per language per episode
+	RequestsPerMinute int       `json:"requests_per_minute"` // paces the sync's searches and downloads; 0 is unlimited
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (SubtitleProviderSetting) TableName() string { return "subtitle_provider_settings" }
+
+// SubtitleLanguageSetting is the admin configuration of a language for the
+// subtitle sync. Languages without a row use the built-in defaults.
+type SubtitleLanguageSetting struct {
+	Code            string    `json:"code" gorm:"primaryKey"` // ISO 639-1
+	Enabled         bool      `json:"enabled"`
+	MaxSubtitles    int       `json:"max_subtitles"`    // stored per movie or episode
+	HearingImpaired string    `json:"hearing_impaired"` // any, prefer, avoid or exclude
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (SubtitleLanguageSetting) TableName() string { return "subtitle_language_settings" }
+
+// SubtitleTarget is a playable movie or episode the subtitle sync covers.
+// Season and Episode are 0 for movies.
+type SubtitleTarget struct {
+	ImdbCode string `json:"imdb_code"`
+	Title    string `json:"title"`
+	Season   int    `json:"season" gorm:"column:season_number"`
+	Episode  int    `json:"episode" gorm:"column:episode_number"`
+}
@@ -69,3 +69,67 @@ func (c *CastSlice) Scan(value any) error {
 	}
 	return json.Unmarshal(bytes, c)
 }
+
+// IntMap handles map[string]int <-> JSON text in the database.
+type IntMap map[string]int
+
+func (m IntMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	return string(data), err
+}
+
+func (m *IntMap) Scan(value any) error {
+	if value == nil {
+		*m = IntMap{}
+		return nil
+	}
+	var bytes []byte
+	switch v := value.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return fmt.Errorf("cannot scan %T into IntMap", value)
+	}
+	if len(bytes) == 0 {
+		*m = IntMap{}
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// StringMap handles map[string]string <-> JSON text in the database.
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	return string(data), err
+}
+
+func (m *StringMap) Scan(value any) error {
+	if value == nil {
+		*m = StringMap{}
+		return nil
+	}
+	var bytes []byte
+	switch v := value.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return fmt.Errorf("cannot scan %T into StringMap", value)
+	}
+	if len(bytes) == 0 {
+		*m = StringMap{}
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
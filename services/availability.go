@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"torrent-server/models"
+)
+
+// availabilityNotifier wakes clients waiting for availability events. Each
+// waiter gets a channel that is closed when the next event is recorded.
+type availabilityNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func (n *availabilityNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	return n.ch
+}
+
+func (n *availabilityNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
+}
+
+// AvailabilityUpdates returns a channel that is closed when the next
+// availability event is recorded
+func (s *SyncService) AvailabilityUpdates() <-chan struct{} {
+	return s.availability.wait()
+}
+
+// markAvailable flips a coming-soon movie to available and records an
+// availability event for the devices watching it
+func (s *SyncService) markAvailable(movie *models.Movie, torrents int) {
+	if err := s.db.SetMovieStatus(movie.ID, "available"); err != nil {
+		log.Printf("[Availability] Failed to mark %s available: %v", movie.Title, err)
+		return
+	}
+	movie.Status = "available"
+
+	event := &models.AvailabilityEvent{
+		MovieID:  movie.ID,
+		ImdbCode: movie.ImdbCode,
+		Title:    movie.Title,
+		Year:     movie.Year,
+		Poster:   movie.MediumCoverImage,
+		Torrents: torrents,
+	}
+	if err := s.db.CreateAvailabilityEvent(event); err != nil {
+		log.Printf("[Availability] Failed to record event for %s: %v", movie.Title, err)
+		return
+	}
+	log.Printf("[Availability] %s (%s) is now available with %d torrents, %d devices watching",
+		movie.Title, movie.ImdbCode, torrents, s.db.CountAvailabilityWatchers(movie.ImdbCode))
+	s.availability.notify()
+}
+
+// WatchComingSoon searches the torrent providers for every coming-soon movie,
+// marking the ones that turned up torrents as available
+func (s *SyncService) WatchComingSoon(ctx context.Context, p *JobProgress) error {
+	movies, err := s.db.ListComingSoonMovies()
+	if err != nil {
+		return err
+	}
+	p.SetTotal(len(movies))
+
+	for i := range movies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		movie := &movies[i]
+		p.SetCurrent(movie.Title)
+
+		if added := s.syncMovieTorrents(movie); added > 0 {
+			p.Add(1, 1, 0)
+		} else {
+			p.Add(1, 0, 0)
+		}
+		if err := sleepCtx(ctx, 1*time.Second); err != nil { // Rate limiting
+			return err
+		}
+	}
+	return nil
+}
@@ -1,20 +1,60 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"torrent-server/database"
+	"torrent-server/models"
 )
 
+// defaultHealthFailures is how many consecutive failed checks soft-disable a channel
+const defaultHealthFailures = 3
+
+// A failed check is retried once after a pause, since many servers blip briefly
+const (
+	healthCheckAttempts   = 2
+	healthCheckRetryDelay = 3 * time.Second
+)
+
+// healthHistory is how long per-channel check history is kept
+const healthHistory = 30 * 24 * time.Hour
+
+// Bytes read from a playlist and from a segment; a segment only has to start
+const (
+	maxHealthPlaylistBytes = 256 << 10
+	maxHealthSegmentBytes  = 64 << 10
+)
+
+// Stages a health check can fail at
+const (
+	HealthStagePlaylist = "playlist" // the channel's stream URL
+	HealthStageVariant  = "variant"  // the first variant of a master playlist
+	HealthStageSegment  = "segment"  // the first media segment
+	HealthStageStream   = "stream"   // a stream URL that is not HLS
+)
+
+// ChannelHealthService checks channel streams in stages: it fetches the
+// playlist, follows the first variant and fetches the start of a segment.
+// Every check is kept as history. Channels are soft-disabled after a number
+// of consecutive failures and restored as soon as a check passes again;
+// nothing is deleted or blocklisted.
 type ChannelHealthService struct {
-	db     *database.DB
-	mu     sync.Mutex
-	status HealthCheckStatus
+	db       *database.DB
+	client   *http.Client
+	failures int
+	mu       sync.Mutex
+	status   HealthCheckStatus
 }
 
 type HealthCheckStatus struct {
@@ -22,14 +62,36 @@ type HealthCheckStatus struct {
 	Phase       string `json:"phase"`
 	Total       int    `json:"total"`
 	Checked     int    `json:"checked"`
-	Removed     int    `json:"removed"`
+	Healthy     int    `json:"healthy"`
+	Failed      int    `json:"failed"`   // checks that failed this run
+	Disabled    int    `json:"disabled"` // channels soft-disabled this run
+	Restored    int    `json:"restored"` // disabled channels that passed again
 	StartedAt   string `json:"started_at,omitempty"`
 	CompletedAt string `json:"completed_at,omitempty"`
 	LastError   string `json:"last_error,omitempty"`
 }
 
 func NewChannelHealthService(db *database.DB) *ChannelHealthService {
-	return &ChannelHealthService{db: db}
+	return &ChannelHealthService{
+		db: db,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return fmt.Errorf("too many redirects")
+				}
+				return nil
+			},
+		},
+		failures: defaultHealthFailures,
+	}
+}
+
+// SetFailureThreshold sets how many consecutive failed checks soft-disable a channel
+func (s *ChannelHealthService) SetFailureThreshold(n int) {
+	if n > 0 {
+		s.failures = n
+	}
 }
 
 func (s *ChannelHealthService) GetStatus() HealthCheckStatus {
@@ -39,9 +101,42 @@ func (s *ChannelHealthService) GetStatus() HealthCheckStatus {
 }
 
 func (s *ChannelHealthService) RunHealthCheck() error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	go func() {
+		s.finish(s.doHealthCheck(context.Background()))
+	}()
+	return nil
+}
+
+// Check runs a health check and waits for it to finish
+func (s *ChannelHealthService) Check(ctx context.Context) (HealthCheckStatus, error) {
+	if err := s.begin(); err != nil {
+		return HealthCheckStatus{}, err
+	}
+	err := s.doHealthCheck(ctx)
+	s.finish(err)
+	return s.GetStatus(), err
+}
+
+// CheckChannel checks one channel now, recording the result like a full run
+func (s *ChannelHealthService) CheckChannel(ctx context.Context, id string) (*models.ChannelHealthCheck, error) {
+	ch, err := s.db.GetChannel(id)
+	if err != nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+	if ch.StreamURL == "" {
+		return nil, fmt.Errorf("channel has no stream")
+	}
+	check, _, _ := s.checkChannel(ctx, *ch)
+	return check, nil
+}
+
+func (s *ChannelHealthService) begin() error {
 	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.status.Running {
-		s.mu.Unlock()
 		return fmt.Errorf("health check already in progress")
 	}
 	s.status = HealthCheckStatus{
@@ -49,29 +144,27 @@ func (s *ChannelHealthService) RunHealthCheck() error {
 		Phase:     "starting",
 		StartedAt: time.Now().Format(time.RFC3339),
 	}
-	s.mu.Unlock()
-
-	go func() {
-		err := s.doHealthCheck()
-		s.mu.Lock()
-		s.status.Running = false
-		s.status.CompletedAt = time.Now().Format(time.RFC3339)
-		if err != nil {
-			s.status.LastError = err.Error()
-			s.status.Phase = "error: " + err.Error()
-		} else {
-			s.status.Phase = "completed"
-			s.status.LastError = ""
-		}
-		s.mu.Unlock()
-	}()
-
 	return nil
 }
 
-func (s *ChannelHealthService) doHealthCheck() error {
+func (s *ChannelHealthService) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.CompletedAt = time.Now().Format(time.RFC3339)
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.Phase = "error: " + err.Error()
+	} else {
+		s.status.Phase = "completed"
+		s.status.LastError = ""
+	}
+}
+
+func (s *ChannelHealthService) doHealthCheck(ctx context.Context) error {
 	s.setPhase("fetching channels")
 
+	// Disabled channels are checked too, so they can be restored
 	channels, err := s.db.GetAllChannelsWithStreams()
 	if err != nil {
 		return fmt.Errorf("failed to fetch channels: %w", err)
@@ -86,17 +179,15 @@ func (s *ChannelHealthService) doHealthCheck() error {
 
 	s.setPhase("checking streams")
 
-	var checked atomic.Int64
-	var removed atomic.Int64
-
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 3 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
+	var checked, healthy, failed, disabled, restored atomic.Int64
+	progress := func() {
+		s.mu.Lock()
+		s.status.Checked = int(checked.Load())
+		s.status.Healthy = int(healthy.Load())
+		s.status.Failed = int(failed.Load())
+		s.status.Disabled = int(disabled.Load())
+		s.status.Restored = int(restored.Load())
+		s.mu.Unlock()
 	}
 
 	// Semaphore for 50 concurrent workers
@@ -104,47 +195,52 @@ func (s *ChannelHealthService) doHealthCheck() error {
 	var wg sync.WaitGroup
 
 	for _, ch := range channels {
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
 		sem <- struct{}{} // acquire
 
-		go func(id, streamURL string) {
+		go func(ch models.Channel) {
 			defer wg.Done()
 			defer func() { <-sem }() // release
 
-			alive := checkStream(client, streamURL)
+			check, wasDisabled, wasRestored := s.checkChannel(ctx, ch)
 
 			currentChecked := checked.Add(1)
-			if !alive {
-				s.db.AddToBlocklist(id, "dead_stream")
-				if err := s.db.DeleteChannel(id); err == nil {
-					removed.Add(1)
-				}
+			if check.OK {
+				healthy.Add(1)
+			} else {
+				failed.Add(1)
+			}
+			if wasDisabled {
+				disabled.Add(1)
+			}
+			if wasRestored {
+				restored.Add(1)
 			}
 
 			// Log progress every 500 channels
 			if currentChecked%500 == 0 {
-				s.mu.Lock()
-				s.status.Checked = int(currentChecked)
-				s.status.Removed = int(removed.Load())
-				s.mu.Unlock()
-				log.Printf("[Health Check] Progress: %d/%d checked, %d removed",
-					currentChecked, total, removed.Load())
+				progress()
+				log.Printf("[Health Check] Progress: %d/%d checked, %d failed, %d disabled, %d restored",
+					currentChecked, total, failed.Load(), disabled.Load(), restored.Load())
 			}
-		}(ch.ID, ch.StreamURL)
+		}(ch)
 	}
 
 	wg.Wait()
+	progress()
 
-	finalChecked := int(checked.Load())
-	finalRemoved := int(removed.Load())
-
-	s.mu.Lock()
-	s.status.Checked = finalChecked
-	s.status.Removed = finalRemoved
-	s.mu.Unlock()
+	if pruned, err := s.db.PruneChannelHealthChecks(time.Now().Add(-healthHistory)); err != nil {
+		log.Printf("[Health Check] Failed to prune history: %v", err)
+	} else if pruned > 0 {
+		log.Printf("[Health Check] Pruned %d old history entries", pruned)
+	}
 
-	log.Printf("[Health Check] Completed: %d/%d checked, %d removed", finalChecked, total, finalRemoved)
-	return nil
+	log.Printf("[Health Check] Completed: %d/%d checked, %d healthy, %d failed, %d disabled, %d restored",
+		checked.Load(), total, healthy.Load(), failed.Load(), disabled.Load(), restored.Load())
+	return ctx.Err()
 }
 
 func (s *ChannelHealthService) setPhase(phase string) {
@@ -153,13 +249,186 @@ func (s *ChannelHealthService) setPhase(phase string) {
 	s.mu.Unlock()
 }
 
-func checkStream(client *http.Client, url string) bool {
-	resp, err := client.Head(url)
+// checkChannel probes a channel, retrying a failure once, and records the
+// result in its history. It reports whether the channel was soft-disabled
+// or restored by this check.
+func (s *ChannelHealthService) checkChannel(ctx context.Context, ch models.Channel) (check *models.ChannelHealthCheck, disabled, restored bool) {
+	var res streamProbe
+	attempts := 0
+	for attempts < healthCheckAttempts {
+		attempts++
+		res = s.probe(ctx, ch.StreamURL)
+		if res.ok || attempts == healthCheckAttempts || sleepCtx(ctx, healthCheckRetryDelay) != nil {
+			break
+		}
+	}
+
+	now := time.Now()
+	check = &models.ChannelHealthCheck{
+		ChannelID:  ch.ID,
+		OK:         res.ok,
+		StatusCode: res.statusCode,
+		LatencyMs:  int(res.latency.Milliseconds()),
+		Attempts:   attempts,
+		CheckedAt:  now,
+	}
+	if !res.ok {
+		check.Stage = res.stage
+		check.Error = res.err
+	}
+	if err := s.db.AddChannelHealthCheck(check); err != nil {
+		log.Printf("[Health Check] Failed to record check of %s: %v", ch.ID, err)
+	}
+
+	failCount, isDisabled, latency := 0, false, check.LatencyMs
+	if !res.ok {
+		failCount = ch.FailCount + 1
+		isDisabled = ch.Disabled || failCount >= s.failures
+		latency = ch.LatencyMs
+	}
+	if err := s.db.UpdateChannelHealth(ch.ID, isDisabled, failCount, latency, now); err != nil {
+		log.Printf("[Health Check] Failed to update %s: %v", ch.ID, err)
+	}
+
+	disabled = isDisabled && !ch.Disabled
+	restored = ch.Disabled && !isDisabled
+	if disabled {
+		log.Printf("[Health Check] Disabled %s after %d failed checks (%s: %s)", ch.ID, failCount, res.stage, res.err)
+	} else if restored {
+		log.Printf("[Health Check] Restored %s", ch.ID)
+	}
+	return check, disabled, restored
+}
+
+// streamProbe is the outcome of one staged check of a stream
+type streamProbe struct {
+	ok         bool
+	stage      string
+	statusCode int
+	err        string
+	latency    time.Duration // until the playlist's response headers
+}
+
+// probe fetches the stream URL and, for HLS, the first variant playlist and
+// the start of its first segment. Streams that aren't HLS pass when they
+// send any data.
+func (s *ChannelHealthService) probe(ctx context.Context, streamURL string) streamProbe {
+	res := streamProbe{stage: HealthStagePlaylist}
+	playlist, err := s.fetch(ctx, streamURL, maxHealthPlaylistBytes)
+	if playlist != nil {
+		res.statusCode = playlist.statusCode
+		res.latency = playlist.latency
+	}
+	if err != nil {
+		res.err = err.Error()
+		return res
+	}
+	if !isHLSPlaylist(playlist.contentType, playlist.body) {
+		res.stage = HealthStageStream
+		if len(playlist.body) == 0 {
+			res.err = "empty response"
+			return res
+		}
+		res.ok = true
+		return res
+	}
+
+	uri, err := firstPlaylistURI(playlist.url, playlist.body)
+	if err != nil {
+		res.err = err.Error()
+		return res
+	}
+	if bytes.Contains(playlist.body, []byte("#EXT-X-STREAM-INF")) {
+		res.stage = HealthStageVariant
+		variant, err := s.fetch(ctx, uri, maxHealthPlaylistBytes)
+		if variant != nil {
+			res.statusCode = variant.statusCode
+		}
+		if err != nil {
+			res.err = err.Error()
+			return res
+		}
+		if !isHLSPlaylist(variant.contentType, variant.body) {
+			res.err = "variant is not a playlist"
+			return res
+		}
+		if uri, err = firstPlaylistURI(variant.url, variant.body); err != nil {
+			res.err = err.Error()
+			return res
+		}
+	}
+
+	res.stage = HealthStageSegment
+	segment, err := s.fetch(ctx, uri, maxHealthSegmentBytes)
+	if segment != nil {
+		res.statusCode = segment.statusCode
+	}
+	if err != nil {
+		res.err = err.Error()
+		return res
+	}
+	if len(segment.body) == 0 {
+		res.err = "empty segment"
+		return res
+	}
+	res.ok = true
+	return res
+}
+
+type healthResponse struct {
+	url         *url.URL // after redirects
+	statusCode  int
+	contentType string
+	body        []byte
+	latency     time.Duration
+}
+
+// fetch GETs src and reads up to limit bytes of the body. The response is
+// returned with the error when the server answered with a non-2xx status.
+func (s *ChannelHealthService) fetch(ctx context.Context, src string, limit int64) (*healthResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	resp.Body.Close()
+	defer resp.Body.Close()
 
-	// Accept 200 and 206 (partial content, common for streams)
-	return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent
+	r := &healthResponse{
+		url:         resp.Request.URL,
+		statusCode:  resp.StatusCode,
+		contentType: resp.Header.Get("Content-Type"),
+		latency:     time.Since(start),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return r, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+	r.body, err = io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil && len(r.body) == 0 {
+		return r, err
+	}
+	return r, nil
+}
+
+// firstPlaylistURI returns the first variant or segment URI of a playlist,
+// resolved against its URL
+func firstPlaylistURI(base *url.URL, body []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ref, err := url.Parse(line)
+		if err != nil {
+			return "", fmt.Errorf("invalid URI in playlist: %q", line)
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	return "", fmt.Errorf("playlist has no variants or segments")
 }
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"torrent-server/database"
+	"torrent-server/models"
+	"torrent-server/utils"
+)
+
+// DefaultDuplicateSimilarity is the minimum title similarity reported by FindDuplicates
+const DefaultDuplicateSimilarity = 0.85
+
+type normalizedCandidate struct {
+	models.DuplicateCandidate
+	norm string
+}
+
+// FindDuplicates compares every movie against other movies and against
+// series, pairing entries whose normalized titles are at least minSimilarity
+// alike, whose years are at most one apart and, for two movies, whose
+// runtimes (when both are known) differ by no more than 10 minutes.
+func FindDuplicates(db *database.DB, minSimilarity float64) ([]models.DuplicatePair, error) {
+	if minSimilarity <= 0 || minSimilarity > 1 {
+		minSimilarity = DefaultDuplicateSimilarity
+	}
+
+	movies, err := db.ListMovieCandidates()
+	if err != nil {
+		return nil, err
+	}
+	series, err := db.ListSeriesCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bucket by year so each title is only compared with its neighbours
+	movieYears := bucketByYear(movies)
+	seriesYears := bucketByYear(series)
+
+	pairs := []models.DuplicatePair{}
+	for year, bucket := range movieYears {
+		for i := range bucket {
+			a := &bucket[i]
+			for _, y := range []uint{year, year + 1} {
+				for j := range movieYears[y] {
+					b := &movieYears[y][j]
+					if y == year && j <= i {
+						continue
+					}
+					if pair, ok := compareCandidates(a, b, minSimilarity); ok {
+						pair.Kind = "movie"
+						pair.Survivor, pair.Duplicate = pickSurvivor(a.DuplicateCandidate, b.DuplicateCandidate)
+						pairs = append(pairs, pair)
+					}
+				}
+			}
+			for _, y := range []uint{year - 1, year, year + 1} {
+				for j := range seriesYears[y] {
+					s := &seriesYears[y][j]
+					if pair, ok := compareCandidates(a, s, minSimilarity); ok {
+						pair.Kind = "movie_series"
+						pair.Survivor, pair.Duplicate = s.DuplicateCandidate, a.DuplicateCandidate
+						pairs = append(pairs, pair)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		return pairs[i].Duplicate.ID < pairs[j].Duplicate.ID
+	})
+	return pairs, nil
+}
+
+func bucketByYear(candidates []models.DuplicateCandidate) map[uint][]normalizedCandidate {
+	buckets := make(map[uint][]normalizedCandidate)
+	for _, c := range candidates {
+		norm := utils.NormalizeTitle(c.Title)
+		if norm == "" {
+			continue
+		}
+		buckets[c.Year] = append(buckets[c.Year], normalizedCandidate{c, norm})
+	}
+	return buckets
+}
+
+func compareCandidates(a, b *normalizedCandidate, minSimilarity float64) (models.DuplicatePair, bool) {
+	var pair models.DuplicatePair
+	if a.ImdbCode == b.ImdbCode {
+		if a.Type == b.Type {
+			return pair, false
+		}
+		// A series that was also imported as a movie
+		return models.DuplicatePair{Similarity: 1, Reasons: []string{"same IMDb code"}}, true
+	}
+
+	similarity := 1.0
+	if a.norm != b.norm {
+		similarity = utils.TitleSimilarity(a.Title, b.Title)
+	}
+	if similarity < minSimilarity {
+		return pair, false
+	}
+
+	// Series runtimes are per episode, so only movies are compared
+	sameType := a.Type == b.Type
+	if sameType && a.Runtime > 0 && b.Runtime > 0 {
+		diff := int(a.Runtime) - int(b.Runtime)
+		if diff < -10 || diff > 10 {
+			return pair, false
+		}
+	}
+
+	pair.Similarity = similarity
+	if similarity == 1 {
+		pair.Reasons = append(pair.Reasons, "same normalized title")
+	} else {
+		pair.Reasons = append(pair.Reasons, fmt.Sprintf("title similarity %.2f", similarity))
+	}
+	if a.Year == b.Year {
+		pair.Reasons = append(pair.Reasons, "same year")
+	} else {
+		pair.Reasons = append(pair.Reasons, "year within 1")
+	}
+	if sameType && a.Runtime > 0 && a.Runtime == b.Runtime {
+		pair.Reasons = append(pair.Reasons, "same runtime")
+	} else if sameType && a.Runtime > 0 && b.Runtime > 0 {
+		pair.Reasons = append(pair.Reasons, "runtime within 10 min")
+	}
+	return pair, true
+}
+
+// pickSurvivor prefers the entry with more torrents, then one with IMDb
+// ratings, then the older (lower ID) entry
+func pickSurvivor(a, b models.DuplicateCandidate) (survivor, duplicate models.DuplicateCandidate) {
+	switch {
+	case a.Torrents != b.Torrents:
+		if a.Torrents > b.Torrents {
+			return a, b
+		}
+		return b, a
+	case (a.ImdbRating != nil) != (b.ImdbRating != nil):
+		if a.ImdbRating != nil {
+			return a, b
+		}
+		return b, a
+	case a.ID < b.ID:
+		return a, b
+	}
+	return b, a
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"torrent-server/models"
+)
+
+// episodeAcquisitionDays is how long after airing an episode keeps being
+// searched for before the acquisition gives up
+const episodeAcquisitionDays = 7
+
+// episodeRetryDelays spaces out the searches for an aired episode; the last
+// delay repeats until the acquisition window closes
+var episodeRetryDelays = []time.Duration{
+	1 * time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+func episodeRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > len(episodeRetryDelays) {
+		attempts = len(episodeRetryDelays)
+	}
+	return episodeRetryDelays[attempts-1]
+}
+
+// AcquireNewEpisodes searches for torrents for episodes of ongoing series
+// that aired in the last week and have none yet, retrying with backoff
+// until torrents turn up or the window closes.
+func (s *SyncService) AcquireNewEpisodes(ctx context.Context, p *JobProgress) error {
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	from := now.AddDate(0, 0, -episodeAcquisitionDays).Format("2006-01-02")
+
+	episodes, err := s.db.ListEpisodesAwaitingTorrents(from, today)
+	if err != nil {
+		return err
+	}
+	p.SetTotal(len(episodes))
+
+	seriesCache := make(map[uint]*models.Series)
+	for _, ep := range episodes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		acq, _ := s.db.GetEpisodeAcquisition(ep.ID)
+		if acq == nil {
+			acq = &models.EpisodeAcquisition{
+				EpisodeID:     ep.ID,
+				SeriesID:      ep.SeriesID,
+				SeasonNumber:  ep.SeasonNumber,
+				EpisodeNumber: ep.EpisodeNumber,
+				Status:        models.AcquisitionSearching,
+			}
+		}
+		acq.AirDate = ep.AirDate
+
+		if acq.Status == models.AcquisitionGaveUp || (acq.NextAttemptAt != nil && acq.NextAttemptAt.After(now)) {
+			p.Add(1, 0, 0)
+			continue
+		}
+
+		series, ok := seriesCache[ep.SeriesID]
+		if !ok {
+			series, _ = s.db.GetSeries(ep.SeriesID)
+			seriesCache[ep.SeriesID] = series
+		}
+		if series == nil {
+			p.Add(1, 0, 1)
+			continue
+		}
+		label := fmt.Sprintf("%s S%02dE%02d", series.Title, ep.SeasonNumber, ep.EpisodeNumber)
+		p.SetCurrent(label)
+
+		added, err := s.SyncEpisode(series, int(ep.SeasonNumber), int(ep.EpisodeNumber))
+		attemptAt := time.Now()
+		acq.Attempts++
+		acq.LastAttemptAt = &attemptAt
+		acq.LastError = ""
+		if err != nil {
+			acq.LastError = err.Error()
+		}
+
+		switch {
+		case added > 0:
+			acq.Status = models.AcquisitionAcquired
+			acq.Torrents = added
+			acq.NextAttemptAt = nil
+			log.Printf("[Episodes] Acquired %d torrents for %s after %d attempts", added, label, acq.Attempts)
+			p.Add(1, 1, 0)
+		case ep.AirDate < now.AddDate(0, 0, -episodeAcquisitionDays+1).Format("2006-01-02"):
+			// Last day of the window
+			acq.Status = models.AcquisitionGaveUp
+			acq.NextAttemptAt = nil
+			log.Printf("[Episodes] Giving up on %s after %d attempts", label, acq.Attempts)
+			p.Add(1, 0, 1)
+		default:
+			acq.Status = models.AcquisitionSearching
+			next := attemptAt.Add(episodeRetryDelay(acq.Attempts))
+			acq.NextAttemptAt = &next
+			p.Add(1, 0, 0)
+		}
+
+		if err := s.db.SaveEpisodeAcquisition(acq); err != nil {
+			log.Printf("[Episodes] Failed to save acquisition for %s: %v", label, err)
+		}
+		sleepCtx(ctx, 2*time.Second)
+	}
+	return nil
+}
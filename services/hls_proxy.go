@@ -0,0 +1,430 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxHLSResponseBytes caps what is buffered from upstream; segments are a
+// few MB, so anything larger is a raw stream rather than HLS
+const maxHLSResponseBytes = 32 << 20
+
+// hlsPlaylistTTL coalesces the playlist polling of many viewers of a channel
+// without serving a live playlist noticeably late
+const hlsPlaylistTTL = 2 * time.Second
+
+// hlsSniffBytes is how much of a response is read to tell a playlist from
+// a raw stream
+const hlsSniffBytes = 512
+
+// ErrNotHLS is returned when a channel's stream is not an HLS playlist
+var ErrNotHLS = errors.New("stream is not an HLS playlist")
+
+// ErrPrivateHost is returned for URLs on loopback, private or
+// link-local addresses, which the proxy must not reach on a client's behalf
+var ErrPrivateHost = errors.New("host is not a public address")
+
+// HLSUpstreamError is a non-2xx response from the upstream stream server
+type HLSUpstreamError struct {
+	StatusCode int
+}
+
+func (e *HLSUpstreamError) Error() string {
+	return fmt.Sprintf("upstream returned %d", e.StatusCode)
+}
+
+// HLSProxy relays IPTV HLS streams so clients can play http, geo-restricted
+// or CORS-less channels through us. Playlists are rewritten so every variant,
+// segment and key URI points back at the proxy; those URIs are signed so the
+// proxy only fetches URLs it handed out itself. Responses are cached in
+// memory for a short time and concurrent requests for the same URL share
+// one upstream fetch.
+type HLSProxy struct {
+	secret []byte
+	client *http.Client
+	ttl    time.Duration // how long segments stay cached
+	limit  int64         // cache size in bytes
+
+	mu       sync.Mutex
+	entries  map[string]*hlsEntry
+	size     int64
+	inflight map[string]*hlsFetch
+	stats    HLSProxyStats
+}
+
+type hlsEntry struct {
+	body        []byte
+	contentType string
+	playlist    bool
+	expires     time.Time
+}
+
+type hlsFetch struct {
+	done  chan struct{}
+	entry *hlsEntry
+	err   error
+}
+
+// HLSProxyStats is the admin view of the proxy's segment cache
+type HLSProxyStats struct {
+	Hits          int64  `json:"hits"`
+	Misses        int64  `json:"misses"`
+	Shared        int64  `json:"shared"` // requests that waited on another request's fetch
+	Errors        int64  `json:"errors"`
+	UpstreamBytes int64  `json:"upstream_bytes"`
+	Entries       int    `json:"entries"`
+	CacheBytes    int64  `json:"cache_bytes"`
+	CacheLimit    int64  `json:"cache_limit"`
+	SegmentTTL    string `json:"segment_ttl"`
+}
+
+// NewHLSProxy creates a proxy that keeps segments for ttl and up to limitMB
+// of responses in memory
+func NewHLSProxy(secret string, ttl time.Duration, limitMB int) *HLSProxy {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	if limitMB <= 0 {
+		limitMB = 256
+	}
+	return &HLSProxy{
+		secret:   []byte(secret),
+		client:   &http.Client{Timeout: 20 * time.Second, Transport: publicOnlyTransport()},
+		ttl:      ttl,
+		limit:    int64(limitMB) << 20,
+		entries:  make(map[string]*hlsEntry),
+		inflight: make(map[string]*hlsFetch),
+	}
+}
+
+func (p *HLSProxy) sign(channelID, src string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(channelID + "\n" + src))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// ProxyPath returns the signed /live path relaying src for a channel. The
+// key ends in the source's extension, which some players rely on.
+func (p *HLSProxy) ProxyPath(channelID, src string) string {
+	key := base64.RawURLEncoding.EncodeToString([]byte(src))
+	if u, err := url.Parse(src); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && len(ext) <= 6 {
+			key += ext
+		}
+	}
+	return "/live/" + url.PathEscape(channelID) + "/" + p.sign(channelID, src) + "/" + key
+}
+
+// Decode verifies a signed /live path segment and returns the source URL
+func (p *HLSProxy) Decode(channelID, sig, key string) (string, error) {
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		key = key[:i]
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream key")
+	}
+	src := string(raw)
+	if !hmac.Equal([]byte(p.sign(channelID, src)), []byte(sig)) {
+		return "", fmt.Errorf("invalid stream signature")
+	}
+	return src, nil
+}
+
+// HLSResponse is a relayed playlist or segment
+type HLSResponse struct {
+	Body        []byte
+	ContentType string
+	Playlist    bool
+}
+
+// Playlist fetches a channel's master or media playlist and rewrites its URIs
+// through the proxy. It returns ErrNotHLS when src is not a playlist, after
+// reading only the start of the response.
+func (p *HLSProxy) Playlist(channelID, src string) (*HLSResponse, error) {
+	resp, err := p.get(channelID, src, true)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Playlist {
+		return nil, ErrNotHLS
+	}
+	return resp, nil
+}
+
+// Get relays a proxied URL: playlists come back rewritten, anything else as
+// the upstream bytes. Segments are served from the cache while fresh.
+func (p *HLSProxy) Get(channelID, src string) (*HLSResponse, error) {
+	return p.get(channelID, src, false)
+}
+
+func (p *HLSProxy) get(channelID, src string, playlistOnly bool) (*HLSResponse, error) {
+	// Playlists are rewritten per channel, so the cache key includes it
+	key := channelID + "\n" + src
+	now := time.Now()
+
+	p.mu.Lock()
+	if e, ok := p.entries[key]; ok && now.Before(e.expires) {
+		p.stats.Hits++
+		p.mu.Unlock()
+		return e.response(), nil
+	}
+	if f, ok := p.inflight[key]; ok {
+		p.stats.Shared++
+		p.mu.Unlock()
+		<-f.done
+		if f.err != nil {
+			return nil, f.err
+		}
+		return f.entry.response(), nil
+	}
+	f := &hlsFetch{done: make(chan struct{})}
+	p.inflight[key] = f
+	p.stats.Misses++
+	p.mu.Unlock()
+
+	f.entry, f.err = p.fetch(channelID, src, playlistOnly)
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	if f.err != nil {
+		p.stats.Errors++
+	} else {
+		p.stats.UpstreamBytes += int64(len(f.entry.body))
+		p.store(key, f.entry)
+	}
+	p.mu.Unlock()
+	close(f.done)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.entry.response(), nil
+}
+
+func (e *hlsEntry) response() *HLSResponse {
+	return &HLSResponse{Body: e.body, ContentType: e.contentType, Playlist: e.playlist}
+}
+
+// fetch downloads src, rewriting it when it is a playlist. With playlistOnly
+// anything else fails with ErrNotHLS before its body is read.
+func (p *HLSProxy) fetch(channelID, src string, playlistOnly bool) (*hlsEntry, error) {
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !publicHost(req.URL.Hostname()) {
+		return nil, ErrPrivateHost
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &HLSUpstreamError{StatusCode: resp.StatusCode}
+	}
+
+	// A channel pointing at a raw MPEG-TS stream never ends, so look at the
+	// start before buffering the whole response
+	contentType := resp.Header.Get("Content-Type")
+	br := bufio.NewReaderSize(resp.Body, hlsSniffBytes)
+	head, _ := br.Peek(hlsSniffBytes)
+	playlist := isHLSPlaylist(contentType, head)
+	if playlistOnly && !playlist {
+		return nil, ErrNotHLS
+	}
+
+	body, err := io.ReadAll(io.LimitReader(br, maxHLSResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxHLSResponseBytes {
+		return nil, fmt.Errorf("response larger than %d MB", maxHLSResponseBytes>>20)
+	}
+
+	if !playlist {
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		return &hlsEntry{body: body, contentType: contentType, expires: time.Now().Add(p.ttl)}, nil
+	}
+
+	// Relative URIs resolve against the playlist's final URL, after redirects
+	rewritten := p.rewritePlaylist(channelID, resp.Request.URL, body)
+	return &hlsEntry{
+		body:        rewritten,
+		contentType: "application/vnd.apple.mpegurl",
+		playlist:    true,
+		expires:     time.Now().Add(hlsPlaylistTTL),
+	}, nil
+}
+
+// isHLSPlaylist reports whether a response is an M3U8 playlist
+func isHLSPlaylist(contentType string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "mpegurl") {
+		return true
+	}
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("#EXTM3U"))
+}
+
+// hlsURIAttrRe matches the URI attribute of tags such as EXT-X-KEY,
+// EXT-X-MEDIA and EXT-X-MAP
+var hlsURIAttrRe = regexp.MustCompile(`URI="([^"]*)"`)
+
+// rewritePlaylist points every URI line and URI attribute at the proxy.
+// URIs with other schemes (data:, skd:) or on non-public hosts are left
+// alone, so the proxy never signs a URL it would refuse to fetch.
+func (p *HLSProxy) rewritePlaylist(channelID string, base *url.URL, body []byte) []byte {
+	// Segments nearly always share a host, so resolve each host once
+	public := make(map[string]bool)
+	rewrite := func(uri string) string {
+		ref, err := url.Parse(strings.TrimSpace(uri))
+		if err != nil {
+			return uri
+		}
+		abs := base.ResolveReference(ref)
+		if abs.Scheme != "http" && abs.Scheme != "https" {
+			return uri
+		}
+		host := abs.Hostname()
+		ok, seen := public[host]
+		if !seen {
+			ok = publicHost(host)
+			public[host] = ok
+		}
+		if !ok {
+			return uri
+		}
+		return p.ProxyPath(channelID, abs.String())
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+		case strings.HasPrefix(line, "#"):
+			line = hlsURIAttrRe.ReplaceAllStringFunc(line, func(m string) string {
+				return `URI="` + rewrite(hlsURIAttrRe.FindStringSubmatch(m)[1]) + `"`
+			})
+		default:
+			line = rewrite(line)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// publicOnlyTransport is an HTTP transport that refuses to connect to
+// non-public addresses. Checking the address actually dialled also covers
+// redirects and DNS answers that change between a lookup and the connection.
+func publicOnlyTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return ErrPrivateHost
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// publicHost reports whether host resolves only to public addresses
+func publicHost(host string) bool {
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if blockedIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// blockedIP reports whether ip is loopback, private, link-local or otherwise
+// not a public unicast address
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// store caches an entry, dropping expired entries and then the ones closest
+// to expiry until the cache fits its limit. Callers hold p.mu.
+func (p *HLSProxy) store(key string, e *hlsEntry) {
+	size := int64(len(e.body))
+	if size > p.limit/4 {
+		return
+	}
+	if old, ok := p.entries[key]; ok {
+		p.size -= int64(len(old.body))
+	}
+	p.entries[key] = e
+	p.size += size
+	if p.size <= p.limit {
+		return
+	}
+
+	now := time.Now()
+	for k, old := range p.entries {
+		if now.After(old.expires) {
+			p.size -= int64(len(old.body))
+			delete(p.entries, k)
+		}
+	}
+	for p.size > p.limit {
+		var oldestKey string
+		var oldest *hlsEntry
+		for k, old := range p.entries {
+			if oldest == nil || old.expires.Before(oldest.expires) {
+				oldestKey, oldest = k, old
+			}
+		}
+		p.size -= int64(len(oldest.body))
+		delete(p.entries, oldestKey)
+	}
+}
+
+// Stats returns counters since startup and the current cache size
+func (p *HLSProxy) Stats() HLSProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Entries = len(p.entries)
+	stats.CacheBytes = p.size
+	stats.CacheLimit = p.limit
+	stats.SegmentTTL = p.ttl.String()
+	return stats
+}
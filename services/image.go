@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImagePresets are the widths the image proxy will resize to.
+// "original" serves the cached upstream bytes untouched.
+var ImagePresets = map[string]int{
+	"w92":      92,
+	"w185":     185,
+	"w300":     300,
+	"w342":     342,
+	"w500":     500,
+	"w780":     780,
+	"w1280":    1280,
+	"original": 0,
+}
+
+const maxImageBytes = 20 << 20
+
+// maxImagePixels bounds what is decoded for resizing, so a small file with
+// huge dimensions can't allocate gigabytes
+const maxImagePixels = 40_000_000
+
+// Failed downloads are retried after a while; failed resizes (formats we
+// can't decode, oversized images) won't succeed on a retry
+const (
+	imageDownloadRetry = 10 * time.Minute
+	imageResizeRetry   = 24 * time.Hour
+)
+
+// ImageService downloads third-party images on first request, keeps them
+// under dir and serves resized copies. Proxy URLs are signed so the server
+// only fetches URLs it handed out itself.
+type ImageService struct {
+	dir    string
+	secret []byte
+	client *http.Client
+
+	mu       sync.Mutex
+	inflight map[string]*imageFetch
+	failed   map[string]imageFailure // by path, until the retry time
+}
+
+type imageFailure struct {
+	err   error
+	until time.Time
+}
+
+type imageFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func NewImageService(dir, secret string) *ImageService {
+	return &ImageService{
+		dir:      dir,
+		secret:   []byte(secret),
+		client:   &http.Client{Timeout: 30 * time.Second, Transport: publicOnlyTransport()},
+		inflight: make(map[string]*imageFetch),
+		failed:   make(map[string]imageFailure),
+	}
+}
+
+func (s *ImageService) sign(src string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(src))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// ProxyPath returns the /img path for src at preset, or "" when src can't be proxied
+func (s *ImageService) ProxyPath(src, preset string) string {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return ""
+	}
+	if _, ok := ImagePresets[preset]; !ok {
+		preset = "original"
+	}
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(src))
+	return "/img/" + preset + "/" + s.sign(src) + "/" + encoded
+}
+
+// ProxyURL rewrites src to base + ProxyPath. Empty, relative or already
+// proxied URLs are returned unchanged.
+func (s *ImageService) ProxyURL(base, src, preset string) string {
+	if s == nil {
+		return src
+	}
+	p := s.ProxyPath(src, preset)
+	if p == "" || strings.HasPrefix(src, base+"/img/") {
+		return src
+	}
+	return base + p
+}
+
+// Decode verifies a signed proxy path segment and returns the source URL
+func (s *ImageService) Decode(sig, encoded string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid image key")
+	}
+	src := string(raw)
+	if !hmac.Equal([]byte(s.sign(src)), []byte(sig)) {
+		return "", fmt.Errorf("invalid image signature")
+	}
+	return src, nil
+}
+
+// CachedImage is an image file on disk ready to be served
+type CachedImage struct {
+	Path        string
+	ContentType string
+	ETag        string
+	ModTime     time.Time
+}
+
+func imageHash(src string) string {
+	sum := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *ImageService) variantPath(hash, preset string) string {
+	return filepath.Join(s.dir, preset, hash[:2], hash)
+}
+
+// Get returns src at preset, downloading and resizing it on first use
+func (s *ImageService) Get(src, preset string) (*CachedImage, error) {
+	width, ok := ImagePresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", preset)
+	}
+	hash := imageHash(src)
+
+	original := s.variantPath(hash, "original")
+	if err := s.once(original, imageDownloadRetry, func() error { return s.download(src, original) }); err != nil {
+		return nil, err
+	}
+
+	path := original
+	if width > 0 {
+		path = s.variantPath(hash, preset)
+		if err := s.once(path, imageResizeRetry, func() error { return s.resize(original, path, width) }); err != nil {
+			// Formats we can't decode (e.g. WebP) are served as-is
+			if !errors.Is(err, ErrImageFailedBefore) {
+				log.Printf("[Images] Serving original for %s: %v", src, err)
+			}
+			path = original
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedImage{
+		Path:        path,
+		ContentType: sniffImageType(path),
+		ETag:        `"` + hash[:16] + "-" + preset + `"`,
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+// ErrImageFailedBefore wraps the remembered error of a recently failed
+// download or resize, which was logged when it happened
+var ErrImageFailedBefore = errors.New("failed before")
+
+// once runs build unless path already exists, sharing the result between
+// concurrent requests for the same file. A failed build is remembered for
+// retryAfter instead of being run again on every request.
+func (s *ImageService) once(path string, retryAfter time.Duration, build func() error) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	if fail, ok := s.failed[path]; ok {
+		if time.Now().Before(fail.until) {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %w", ErrImageFailedBefore, fail.err)
+		}
+		delete(s.failed, path)
+	}
+	if f, ok := s.inflight[path]; ok {
+		s.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+	f := &imageFetch{done: make(chan struct{})}
+	s.inflight[path] = f
+	s.mu.Unlock()
+
+	f.err = build()
+	close(f.done)
+
+	s.mu.Lock()
+	delete(s.inflight, path)
+	if f.err != nil {
+		now := time.Now()
+		if len(s.failed) >= 10000 {
+			for p, fail := range s.failed {
+				if now.After(fail.until) {
+					delete(s.failed, p)
+				}
+			}
+		}
+		s.failed[path] = imageFailure{err: f.err, until: now.Add(retryAfter)}
+	}
+	s.mu.Unlock()
+	return f.err
+}
+
+func (s *ImageService) download(src, dest string) error {
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "image/") && ct != "application/octet-stream" {
+		return fmt.Errorf("upstream returned %s, not an image", ct)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxImageBytes {
+		return fmt.Errorf("image larger than %d bytes", maxImageBytes)
+	}
+	return writeFileAtomic(dest, data)
+}
+
+func (s *ImageService) resize(src, dest string, width int) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return fmt.Errorf("image is %dx%d, larger than %d pixels", cfg.Width, cfg.Height, maxImagePixels)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	// Never upscale; just reuse the original bytes
+	if img.Bounds().Dx() <= width {
+		return writeFileAtomic(dest, data)
+	}
+
+	b := img.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	resized := resizeBox(img, width, height)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, resized)
+	} else {
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dest, buf.Bytes())
+}
+
+// resizeBox downscales img by averaging every source pixel that falls into
+// each destination pixel. Good quality for the downscaling we do.
+func resizeBox(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := (y + 1) * sh / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := (x + 1) * sw / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				off := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					bl += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+func sniffImageType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	return http.DetectContentType(head[:n])
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -3,10 +3,9 @@ package services
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"torrent-server/models"
@@ -16,6 +15,7 @@ const imdbAPIBaseURL = "https://api.imdbapi.dev"
 
 type IMDBService struct {
 	client *http.Client
+	cache  *ResponseCache
 }
 
 func NewIMDBService() *IMDBService {
@@ -24,6 +24,32 @@ func NewIMDBService() *IMDBService {
 	}
 }
 
+// SetCache routes title lookups through the persistent response cache
+func (s *IMDBService) SetCache(cache *ResponseCache) {
+	s.cache = cache
+}
+
+// getJSON fetches an imdbapi.dev endpoint through the response cache and decodes it into out
+func (s *IMDBService) getJSON(endpoint, imdbID, variant, u string, out interface{}) error {
+	key := CacheKey{Provider: "imdb", Endpoint: endpoint, ImdbCode: imdbID, Variant: variant}
+	body, err := s.cache.Fetch(key, func() ([]byte, error) {
+		resp, err := s.client.Get(u)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
 // Title response from /titles/{id}
 type IMDBTitle struct {
 	ID             string          `json:"id"`
@@ -124,44 +150,10 @@ type IMDBMoney struct {
 	Currency string `json:"currency"`
 }
 
-// RichMovieData combines data from multiple IMDB endpoints
-type RichMovieData struct {
-	Title             string
-	OriginalTitle     string
-	Year              int
-	Runtime           int // minutes
-	Genres            []string
-	Plot              string
-	Rating            float64
-	VoteCount         int
-	Metacritic        int
-	ContentRating     string
-	IMDBType          string // Original IMDB type (movie, tvMovie, tvSpecial, etc.)
-	PosterURL         string
-	BackgroundURL     string
-	Directors         []string
-	Writers           []string
-	Cast              []models.Cast
-	Budget            string
-	BoxOfficeGross    string
-	YouTubeTrailerID  string
-	AllImages         []string
-}
-
 // FetchTitle gets basic title info
 func (s *IMDBService) FetchTitle(imdbID string) (*IMDBTitle, error) {
-	resp, err := s.client.Get(imdbAPIBaseURL + "/titles/" + imdbID)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
-	}
-
 	var title IMDBTitle
-	if err := json.NewDecoder(resp.Body).Decode(&title); err != nil {
+	if err := s.getJSON("title", imdbID, "", imdbAPIBaseURL+"/titles/"+imdbID, &title); err != nil {
 		return nil, err
 	}
 	return &title, nil
@@ -196,20 +188,40 @@ func (s *IMDBService) SearchTitles(query, typeFilter string) ([]IMDBTitle, error
 	return out.Titles, nil
 }
 
-// FetchCredits gets cast and crew
-func (s *IMDBService) FetchCredits(imdbID string) (*IMDBCreditsResponse, error) {
-	resp, err := s.client.Get(imdbAPIBaseURL + "/titles/" + imdbID + "/credits")
+// IMDBTitlePage is one page of the /titles listing
+type IMDBTitlePage struct {
+	Titles        []IMDBTitle `json:"titles"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+// ListTitles pages through /titles. query holds the filter/sort params,
+// e.g. "types=MOVIE&sortBy=SORT_BY_USER_RATING&sortOrder=DESC".
+func (s *IMDBService) ListTitles(query, pageToken string) (*IMDBTitlePage, error) {
+	u := imdbAPIBaseURL + "/titles?" + query
+	if pageToken != "" {
+		u += "&pageToken=" + url.QueryEscape(pageToken)
+	}
+	resp, err := s.client.Get(u)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
+		return nil, fmt.Errorf("IMDB titles returned %d", resp.StatusCode)
 	}
 
+	var page IMDBTitlePage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// FetchCredits gets cast and crew
+func (s *IMDBService) FetchCredits(imdbID string) (*IMDBCreditsResponse, error) {
 	var credits IMDBCreditsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&credits); err != nil {
+	if err := s.getJSON("credits", imdbID, "", imdbAPIBaseURL+"/titles/"+imdbID+"/credits", &credits); err != nil {
 		return nil, err
 	}
 	return &credits, nil
@@ -217,18 +229,8 @@ func (s *IMDBService) FetchCredits(imdbID string) (*IMDBCreditsResponse, error)
 
 // FetchImages gets all images for a title
 func (s *IMDBService) FetchImages(imdbID string) (*IMDBImagesResponse, error) {
-	resp, err := s.client.Get(imdbAPIBaseURL + "/titles/" + imdbID + "/images")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
-	}
-
 	var images IMDBImagesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&images); err != nil {
+	if err := s.getJSON("images", imdbID, "", imdbAPIBaseURL+"/titles/"+imdbID+"/images", &images); err != nil {
 		return nil, err
 	}
 	return &images, nil
@@ -236,18 +238,8 @@ func (s *IMDBService) FetchImages(imdbID string) (*IMDBImagesResponse, error) {
 
 // FetchVideos gets trailers and videos
 func (s *IMDBService) FetchVideos(imdbID string) (*IMDBVideosResponse, error) {
-	resp, err := s.client.Get(imdbAPIBaseURL + "/titles/" + imdbID + "/videos")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
-	}
-
 	var videos IMDBVideosResponse
-	if err := json.NewDecoder(resp.Body).Decode(&videos); err != nil {
+	if err := s.getJSON("videos", imdbID, "", imdbAPIBaseURL+"/titles/"+imdbID+"/videos", &videos); err != nil {
 		return nil, err
 	}
 	return &videos, nil
@@ -255,205 +247,13 @@ func (s *IMDBService) FetchVideos(imdbID string) (*IMDBVideosResponse, error) {
 
 // FetchBoxOffice gets budget and gross
 func (s *IMDBService) FetchBoxOffice(imdbID string) (*IMDBBoxOfficeResponse, error) {
-	resp, err := s.client.Get(imdbAPIBaseURL + "/titles/" + imdbID + "/boxOffice")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
-	}
-
 	var boxOffice IMDBBoxOfficeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&boxOffice); err != nil {
+	if err := s.getJSON("box_office", imdbID, "", imdbAPIBaseURL+"/titles/"+imdbID+"/boxOffice", &boxOffice); err != nil {
 		return nil, err
 	}
 	return &boxOffice, nil
 }
 
-// FetchRichData fetches all available data for a movie
-func (s *IMDBService) FetchRichData(imdbID string) (*RichMovieData, error) {
-	data := &RichMovieData{}
-
-	// Fetch basic title info (required)
-	title, err := s.FetchTitle(imdbID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch title: %w", err)
-	}
-
-	// Accept movies, TV movies, shorts, and TV specials (standup specials etc.)
-	// tvSpecials are standalone single-episode content, treated as movies for torrent search
-	validTypes := map[string]bool{"movie": true, "tvMovie": true, "short": true, "video": true, "tvSpecial": true}
-	if !validTypes[title.Type] {
-		return nil, fmt.Errorf("not a movie: %s is a %s", imdbID, title.Type)
-	}
-
-	data.IMDBType = title.Type
-	data.Title = title.PrimaryTitle
-	data.OriginalTitle = title.OriginalTitle
-	data.Year = title.StartYear
-	data.Runtime = title.RuntimeSeconds / 60
-	data.Genres = title.Genres
-	data.Plot = title.Plot
-	data.ContentRating = title.ContentRating
-
-	if title.Rating != nil {
-		data.Rating = title.Rating.AggregateRating
-		data.VoteCount = title.Rating.VoteCount
-	}
-
-	if title.Metacritic != nil {
-		data.Metacritic = title.Metacritic.Score
-	}
-
-	if title.PrimaryImage != nil {
-		data.PosterURL = title.PrimaryImage.URL
-	}
-
-	// Fetch credits (optional - don't fail if unavailable)
-	if credits, err := s.FetchCredits(imdbID); err == nil {
-		data.Directors = []string{}
-		data.Writers = []string{}
-		data.Cast = []models.Cast{}
-
-		for _, credit := range credits.Credits {
-			if credit.Name == nil {
-				continue
-			}
-
-			switch credit.Category {
-			case "director":
-				data.Directors = append(data.Directors, credit.Name.DisplayName)
-			case "writer":
-				data.Writers = append(data.Writers, credit.Name.DisplayName)
-			case "actor", "actress":
-				if len(data.Cast) < 10 { // Limit to top 10 actors
-					cast := models.Cast{
-						Name:     credit.Name.DisplayName,
-						ImdbCode: credit.Name.ID,
-					}
-					if len(credit.Characters) > 0 {
-						cast.CharacterName = credit.Characters[0]
-					}
-					if credit.Name.PrimaryImage != nil {
-						cast.URLSmallImage = credit.Name.PrimaryImage.URL
-					}
-					data.Cast = append(data.Cast, cast)
-				}
-			}
-		}
-	}
-
-	// Fetch images (optional)
-	if images, err := s.FetchImages(imdbID); err == nil {
-		data.AllImages = []string{}
-		var posterFound, backgroundFound bool
-
-		for _, img := range images.Images {
-			data.AllImages = append(data.AllImages, img.URL)
-
-			// Try to find a good poster (portrait)
-			if !posterFound && img.Height > img.Width && data.PosterURL == "" {
-				data.PosterURL = img.URL
-				posterFound = true
-			}
-
-			// Try to find a good background (landscape)
-			if !backgroundFound && img.Width > img.Height {
-				data.BackgroundURL = img.URL
-				backgroundFound = true
-			}
-
-			// Limit stored images
-			if len(data.AllImages) >= 20 {
-				break
-			}
-		}
-	}
-
-	// Fetch videos for trailer (optional)
-	if videos, err := s.FetchVideos(imdbID); err == nil {
-		for _, video := range videos.Videos {
-			// Look for official trailer
-			lowerName := strings.ToLower(video.Name)
-			if strings.Contains(lowerName, "trailer") {
-				// Extract YouTube ID if available (IMDB videos may have different format)
-				// The video ID from IMDB isn't a YouTube ID, but we store whatever we get
-				data.YouTubeTrailerID = video.ID
-				break
-			}
-		}
-	}
-
-	// Fetch box office (optional)
-	if boxOffice, err := s.FetchBoxOffice(imdbID); err == nil && boxOffice.BoxOffice != nil {
-		if boxOffice.BoxOffice.Budget != nil {
-			data.Budget = formatMoney(boxOffice.BoxOffice.Budget)
-		}
-		if boxOffice.BoxOffice.WorldwideGross != nil {
-			data.BoxOfficeGross = formatMoney(boxOffice.BoxOffice.WorldwideGross)
-		}
-	}
-
-	return data, nil
-}
-
-// ToMovie converts rich data to a Movie model
-func (data *RichMovieData) ToMovie(imdbCode string) *models.Movie {
-	movie := &models.Movie{
-		ImdbCode:        imdbCode,
-		Title:           data.Title,
-		TitleEnglish:    data.Title,
-		TitleLong:       fmt.Sprintf("%s (%d)", data.Title, data.Year),
-		Slug:            strings.ToLower(strings.ReplaceAll(data.Title, " ", "-")),
-		Year:            uint(data.Year),
-		Runtime:         uint(data.Runtime),
-		Genres:          data.Genres,
-		Summary:         data.Plot,
-		DescriptionFull: data.Plot,
-		Synopsis:        data.Plot,
-		Language:        "en",
-		MpaRating:       data.ContentRating,
-		Cast:            data.Cast,
-	}
-
-	// Set rating
-	if data.Rating > 0 {
-		rating := float32(data.Rating)
-		movie.Rating = rating
-		movie.ImdbRating = &rating
-	}
-
-	// Set vote count
-	if data.VoteCount > 0 {
-		movie.ImdbVotes = formatVotes(data.VoteCount)
-	}
-
-	// Set Metacritic
-	if data.Metacritic > 0 {
-		mc := data.Metacritic
-		movie.Metacritic = &mc
-	}
-
-	// Set images
-	if data.PosterURL != "" {
-		movie.SmallCoverImage = data.PosterURL
-		movie.MediumCoverImage = data.PosterURL
-		movie.LargeCoverImage = data.PosterURL
-	}
-	if data.BackgroundURL != "" {
-		movie.BackgroundImage = data.BackgroundURL
-	}
-
-	// Set trailer
-	if data.YouTubeTrailerID != "" {
-		movie.YtTrailerCode = data.YouTubeTrailerID
-	}
-
-	return movie
-}
-
 func formatMoney(money *IMDBMoney) string {
 	if money == nil {
 		return ""
@@ -529,22 +329,10 @@ func (s *IMDBService) FetchEpisodes(imdbID string) (*IMDBEpisodesResponse, error
 			url += "?pageToken=" + pageToken
 		}
 
-		resp, err := s.client.Get(url)
-		if err != nil {
-			return nil, err
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return nil, fmt.Errorf("IMDB API returned %d", resp.StatusCode)
-		}
-
 		var page IMDBEpisodesResponse
-		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
-			resp.Body.Close()
+		if err := s.getJSON("episodes", imdbID, pageToken, url, &page); err != nil {
 			return nil, err
 		}
-		resp.Body.Close()
 		allEpisodes.Episodes = append(allEpisodes.Episodes, page.Episodes...)
 		allEpisodes.TotalCount = page.TotalCount
 
@@ -563,100 +351,161 @@ func (s *IMDBService) FetchEpisodes(imdbID string) (*IMDBEpisodesResponse, error
 	return allEpisodes, nil
 }
 
-// RichSeriesData combines data from multiple IMDB endpoints for TV series
-type RichSeriesData struct {
-	Title           string
-	Year            int
-	EndYear         *int
-	Runtime         int // minutes per episode
-	Genres          []string
-	Plot            string
-	Rating          float64
-	VoteCount       int
-	ContentRating   string
-	PosterURL       string
-	BackgroundURL   string
-	TotalSeasons    int
-	Status          string // Continuing or Ended
-	Network         string
-	Seasons         []IMDBSeason
-}
-
-// FetchRichSeriesData fetches all available data for a TV series
-func (s *IMDBService) FetchRichSeriesData(imdbID string) (*RichSeriesData, error) {
-	data := &RichSeriesData{}
-
-	// Fetch basic title info (required)
+// --- MetadataProvider implementation ---
+
+func (s *IMDBService) Name() string {
+	return "imdb"
+}
+
+// Title implements MetadataProvider
+func (s *IMDBService) Title(imdbID string) (*MetadataTitle, error) {
 	title, err := s.FetchTitle(imdbID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch title: %w", err)
+		return nil, err
 	}
 
-	// Accept TV series types only
-	validTypes := map[string]bool{"tvSeries": true, "tvMiniSeries": true}
-	if !validTypes[title.Type] {
-		return nil, fmt.Errorf("not a TV series: %s is a %s", imdbID, title.Type)
+	out := &MetadataTitle{
+		Type:          title.Type,
+		Title:         title.PrimaryTitle,
+		OriginalTitle: title.OriginalTitle,
+		Year:          title.StartYear,
+		EndYear:       title.EndYear,
+		Runtime:       title.RuntimeSeconds / 60,
+		Genres:        title.Genres,
+		Plot:          title.Plot,
+		ContentRating: title.ContentRating,
 	}
-
-	data.Title = title.PrimaryTitle
-	data.Year = title.StartYear
-	data.EndYear = title.EndYear
-	data.Runtime = title.RuntimeSeconds / 60
-	data.Genres = title.Genres
-	data.Plot = title.Plot
-	data.ContentRating = title.ContentRating
-
-	// Determine status
-	if title.EndYear != nil {
-		data.Status = "Ended"
-	} else {
-		data.Status = "Continuing"
+	if title.Rating != nil {
+		out.Rating = title.Rating.AggregateRating
+		out.VoteCount = title.Rating.VoteCount
+	}
+	if title.Metacritic != nil {
+		out.Metacritic = title.Metacritic.Score
+	}
+	if title.PrimaryImage != nil {
+		out.PosterURL = title.PrimaryImage.URL
 	}
+	return out, nil
+}
 
-	if title.Rating != nil {
-		data.Rating = title.Rating.AggregateRating
-		data.VoteCount = title.Rating.VoteCount
+// Credits implements MetadataProvider
+func (s *IMDBService) Credits(imdbID string) (*MetadataCredits, error) {
+	credits, err := s.FetchCredits(imdbID)
+	if err != nil {
+		return nil, err
 	}
 
-	if title.PrimaryImage != nil {
-		data.PosterURL = title.PrimaryImage.URL
+	out := &MetadataCredits{
+		Directors: []string{},
+		Writers:   []string{},
+		Cast:      []models.Cast{},
 	}
+	for _, credit := range credits.Credits {
+		if credit.Name == nil {
+			continue
+		}
 
-	// Fetch images for background (optional)
-	if images, err := s.FetchImages(imdbID); err == nil {
-		for _, img := range images.Images {
-			// Find a landscape image for background
-			if img.Width > img.Height && data.BackgroundURL == "" {
-				data.BackgroundURL = img.URL
-				break
+		switch credit.Category {
+		case "director":
+			out.Directors = append(out.Directors, credit.Name.DisplayName)
+		case "writer":
+			out.Writers = append(out.Writers, credit.Name.DisplayName)
+		case "actor", "actress":
+			if len(out.Cast) < 10 { // Limit to top 10 actors
+				cast := models.Cast{
+					Name:     credit.Name.DisplayName,
+					ImdbCode: credit.Name.ID,
+				}
+				if len(credit.Characters) > 0 {
+					cast.CharacterName = credit.Characters[0]
+				}
+				if credit.Name.PrimaryImage != nil {
+					cast.URLSmallImage = credit.Name.PrimaryImage.URL
+				}
+				out.Cast = append(out.Cast, cast)
 			}
 		}
 	}
+	return out, nil
+}
+
+// Images implements MetadataProvider
+func (s *IMDBService) Images(imdbID string) ([]MetadataImage, error) {
+	images, err := s.FetchImages(imdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]MetadataImage, 0, len(images.Images))
+	for _, img := range images.Images {
+		out = append(out, MetadataImage{URL: img.URL, Width: img.Width, Height: img.Height})
+	}
+	return out, nil
+}
+
+// Videos implements MetadataProvider.
+// IMDB video IDs are not YouTube IDs, so Site is left as "IMDb".
+func (s *IMDBService) Videos(imdbID string) ([]MetadataVideo, error) {
+	videos, err := s.FetchVideos(imdbID)
+	if err != nil {
+		return nil, err
+	}
 
-	// Fetch episodes (optional but important)
+	out := make([]MetadataVideo, 0, len(videos.Videos))
+	for _, v := range videos.Videos {
+		out = append(out, MetadataVideo{ID: v.ID, Name: v.Name, Site: "IMDb", Type: v.ContentType})
+	}
+	return out, nil
+}
+
+// Episodes implements MetadataProvider
+func (s *IMDBService) Episodes(imdbID string) ([]MetadataEpisode, error) {
 	episodes, err := s.FetchEpisodes(imdbID)
 	if err != nil {
-		log.Printf("Failed to fetch episodes for %s: %v", imdbID, err)
-	} else if len(episodes.Episodes) > 0 {
-		// Group episodes by season
-		seasonMap := make(map[int][]IMDBEpisode)
-		for _, ep := range episodes.Episodes {
-			seasonNum := 0
-			if ep.Season != "" {
-				fmt.Sscanf(ep.Season, "%d", &seasonNum)
-			}
-			seasonMap[seasonNum] = append(seasonMap[seasonNum], ep)
-		}
+		return nil, err
+	}
 
-		// Convert to Seasons array
-		for seasonNum, eps := range seasonMap {
-			data.Seasons = append(data.Seasons, IMDBSeason{
-				Season:   seasonNum,
-				Episodes: eps,
-			})
+	out := make([]MetadataEpisode, 0, len(episodes.Episodes))
+	for _, ep := range episodes.Episodes {
+		seasonNum := 0
+		if ep.Season != "" {
+			fmt.Sscanf(ep.Season, "%d", &seasonNum)
+		}
+		me := MetadataEpisode{
+			Season:   seasonNum,
+			Episode:  ep.EpisodeNumber,
+			Title:    ep.Title,
+			Plot:     ep.Plot,
+			Runtime:  ep.RuntimeSeconds / 60,
+			ImdbCode: ep.ID,
+		}
+		if ep.ReleaseDate != nil {
+			me.AirDate = fmt.Sprintf("%04d-%02d-%02d", ep.ReleaseDate.Year, ep.ReleaseDate.Month, ep.ReleaseDate.Day)
 		}
-		data.TotalSeasons = len(seasonMap)
+		if ep.PrimaryImage != nil {
+			me.StillURL = ep.PrimaryImage.URL
+		}
+		out = append(out, me)
 	}
+	return out, nil
+}
 
-	return data, nil
+// BoxOffice implements BoxOfficeProvider
+func (s *IMDBService) BoxOffice(imdbID string) (string, string, error) {
+	boxOffice, err := s.FetchBoxOffice(imdbID)
+	if err != nil {
+		return "", "", err
+	}
+	if boxOffice.BoxOffice == nil {
+		return "", "", nil
+	}
+
+	var budget, gross string
+	if boxOffice.BoxOffice.Budget != nil {
+		budget = formatMoney(boxOffice.BoxOffice.Budget)
+	}
+	if boxOffice.BoxOffice.WorldwideGross != nil {
+		gross = formatMoney(boxOffice.BoxOffice.WorldwideGross)
+	}
+	return budget, gross, nil
 }
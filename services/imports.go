@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"torrent-server/models"
+)
+
+// ImportResult summarises a bulk movie import
+type ImportResult struct {
+	Imported   int      `json:"imported"`
+	ComingSoon int      `json:"coming_soon,omitempty"`
+	Skipped    int      `json:"skipped"`
+	Errors     []string `json:"errors"`
+}
+
+// importIMDBTitles syncs up to target movies from an imdbapi.dev /titles
+// listing, skipping ones already in the database. onSynced can adjust a
+// freshly synced movie and reports whether it counts as coming soon.
+func (s *SyncService) importIMDBTitles(ctx context.Context, p *JobProgress, tag, query string, target int, onSynced func(*models.Movie) bool) (*ImportResult, error) {
+	result := &ImportResult{}
+	p.SetTotal(target)
+	pageToken := ""
+
+	for result.Imported+result.ComingSoon+result.Skipped < target {
+		page, err := s.imdb.ListTitles(query, pageToken)
+		if err != nil {
+			log.Printf("[%s] API error: %v", tag, err)
+			break
+		}
+		if len(page.Titles) == 0 {
+			break
+		}
+
+		for _, t := range page.Titles {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			if result.Imported+result.ComingSoon+result.Skipped >= target {
+				break
+			}
+			p.SetCurrent(t.PrimaryTitle)
+			if t.ID == "" {
+				result.Skipped++
+				p.Add(1, 0, 0)
+				continue
+			}
+			if existing, _ := s.db.GetMovieByIMDB(t.ID); existing != nil {
+				result.Skipped++
+				p.Add(1, 0, 0)
+				continue
+			}
+			movie, err := s.SyncMovie(t.ID)
+			if err != nil {
+				log.Printf("[%s] Failed to sync %s (%s): %v", tag, t.PrimaryTitle, t.ID, err)
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", t.PrimaryTitle, err))
+				result.Skipped++
+				p.Add(1, 0, 1)
+				continue
+			}
+			if onSynced != nil && onSynced(movie) {
+				result.ComingSoon++
+				p.AddExtra("coming_soon", 1)
+			} else {
+				result.Imported++
+			}
+			p.Add(1, 1, 0)
+		}
+
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	return result, nil
+}
+
+// ImportIMDBTop250 imports the highest rated movies on IMDb
+func (s *SyncService) ImportIMDBTop250(ctx context.Context, p *JobProgress) (*ImportResult, error) {
+	return s.importIMDBTitles(ctx, p, "SyncTop250",
+		"types=MOVIE&sortBy=SORT_BY_USER_RATING&sortOrder=DESC&minVoteCount=25000", 250, nil)
+}
+
+// ImportLatestMovies imports recent releases from IMDb. Movies that have no
+// torrents yet are marked coming_soon.
+func (s *SyncService) ImportLatestMovies(ctx context.Context, p *JobProgress) (*ImportResult, error) {
+	query := fmt.Sprintf("types=MOVIE&sortBy=SORT_BY_RELEASE_DATE&sortOrder=DESC&startYear=%d&minVoteCount=1000", time.Now().Year()-1)
+	return s.importIMDBTitles(ctx, p, "SyncLatest", query, 50, func(movie *models.Movie) bool {
+		if len(movie.Torrents) > 0 {
+			return false
+		}
+		movie.Status = "coming_soon"
+		s.db.UpdateMovie(movie)
+		return true
+	})
+}
+
+// ImportYTSFeatured imports YTS's featured movies that aren't in the database yet
+func (s *SyncService) ImportYTSFeatured(ctx context.Context, p *JobProgress, ytsBaseURL string) (*ImportResult, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", ytsBaseURL+"/list_movies.json?sort_by=featured&limit=20", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("YTS API unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var ytsResp struct {
+		Data struct {
+			Movies []struct {
+				IMDBCode string `json:"imdb_code"`
+				Title    string `json:"title"`
+			} `json:"movies"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ytsResp); err != nil {
+		return nil, fmt.Errorf("failed to parse YTS response: %w", err)
+	}
+
+	result := &ImportResult{}
+	p.SetTotal(len(ytsResp.Data.Movies))
+	for _, m := range ytsResp.Data.Movies {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		p.SetCurrent(m.Title)
+		if m.IMDBCode == "" {
+			result.Skipped++
+			p.Add(1, 0, 0)
+			continue
+		}
+		if existing, _ := s.db.GetMovieByIMDB(m.IMDBCode); existing != nil {
+			result.Skipped++
+			p.Add(1, 0, 0)
+			continue
+		}
+		if _, err := s.SyncMovie(m.IMDBCode); err != nil {
+			log.Printf("[SyncFeatured] Failed to sync %s (%s): %v", m.Title, m.IMDBCode, err)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", m.Title, err))
+			p.Add(1, 0, 1)
+			continue
+		}
+		result.Imported++
+		p.Add(1, 1, 0)
+	}
+	return result, nil
+}
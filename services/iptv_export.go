@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"torrent-server/models"
+)
+
+// m3uAttrEscaper keeps attribute values from closing their quotes or
+// breaking the #EXTINF line
+var m3uAttrEscaper = strings.NewReplacer(`"`, "'", "\r", " ", "\n", " ")
+
+// WriteM3U writes channels as an extended M3U playlist. categoryNames maps
+// category IDs to the names used for group-title; epgURL, when set, is
+// advertised as the playlist's guide.
+func WriteM3U(w io.Writer, channels []models.Channel, categoryNames map[string]string, epgURL string) error {
+	bw := bufio.NewWriter(w)
+	if epgURL != "" {
+		fmt.Fprintf(bw, "#EXTM3U x-tvg-url=\"%s\"\n", m3uAttrEscaper.Replace(epgURL))
+	} else {
+		bw.WriteString("#EXTM3U\n")
+	}
+
+	for _, ch := range channels {
+		groups := make([]string, 0, len(ch.Categories))
+		for _, id := range ch.Categories {
+			if name, ok := categoryNames[id]; ok {
+				groups = append(groups, name)
+			} else {
+				groups = append(groups, id)
+			}
+		}
+		if len(groups) == 0 {
+			groups = append(groups, "Undefined")
+		}
+
+		fmt.Fprintf(bw, "#EXTINF:-1 tvg-id=\"%s\" tvg-name=\"%s\" tvg-logo=\"%s\" tvg-country=\"%s\" tvg-language=\"%s\" group-title=\"%s\",%s\n",
+			m3uAttrEscaper.Replace(ch.ID),
+			m3uAttrEscaper.Replace(ch.Name),
+			m3uAttrEscaper.Replace(ch.Logo),
+			m3uAttrEscaper.Replace(ch.Country),
+			m3uAttrEscaper.Replace(strings.Join(ch.Languages, ";")),
+			m3uAttrEscaper.Replace(strings.Join(groups, ";")),
+			strings.NewReplacer("\r", " ", "\n", " ").Replace(ch.Name))
+		fmt.Fprintf(bw, "%s\n", ch.StreamURL)
+	}
+	return bw.Flush()
+}
+
+// xmltvTimeLayout is the XMLTV date format
+const xmltvTimeLayout = "20060102150405 -0700"
+
+// epgTimeLayout is how programme times are stored in channel_epg, in UTC
+const epgTimeLayout = "2006-01-02 15:04:05"
+
+type xmltvDoc struct {
+	XMLName       xml.Name         `xml:"tv"`
+	GeneratorName string           `xml:"generator-info-name,attr"`
+	Channels      []xmltvChannel   `xml:"channel"`
+	Programmes    []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID          string     `xml:"id,attr"`
+	DisplayName string     `xml:"display-name"`
+	Icon        *xmltvIcon `xml:"icon,omitempty"`
+	URL         string     `xml:"url,omitempty"`
+}
+
+type xmltvIcon struct {
+	Src string `xml:"src,attr"`
+}
+
+type xmltvProgramme struct {
+	Start   string `xml:"start,attr"`
+	Stop    string `xml:"stop,attr"`
+	Channel string `xml:"channel,attr"`
+	Title   string `xml:"title"`
+	Desc    string `xml:"desc,omitempty"`
+}
+
+// WriteXMLTV writes channels and their programmes as an XMLTV guide. Channel
+// IDs match the tvg-id of WriteM3U; programmes with unparseable times are
+// skipped.
+func WriteXMLTV(w io.Writer, channels []models.Channel, programmes []models.ChannelEPG) error {
+	doc := xmltvDoc{
+		GeneratorName: "torrent-server",
+		Channels:      make([]xmltvChannel, 0, len(channels)),
+		Programmes:    make([]xmltvProgramme, 0, len(programmes)),
+	}
+	for _, ch := range channels {
+		c := xmltvChannel{ID: ch.ID, DisplayName: ch.Name, URL: ch.Website}
+		if ch.Logo != "" {
+			c.Icon = &xmltvIcon{Src: ch.Logo}
+		}
+		doc.Channels = append(doc.Channels, c)
+	}
+	for _, p := range programmes {
+		start, err := time.ParseInLocation(epgTimeLayout, p.StartTime, time.UTC)
+		if err != nil {
+			continue
+		}
+		stop, err := time.ParseInLocation(epgTimeLayout, p.EndTime, time.UTC)
+		if err != nil {
+			continue
+		}
+		doc.Programmes = append(doc.Programmes, xmltvProgramme{
+			Start:   start.Format(xmltvTimeLayout),
+			Stop:    stop.Format(xmltvTimeLayout),
+			Channel: p.ChannelID,
+			Title:   p.Title,
+			Desc:    p.Description,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header+`<!DOCTYPE tv SYSTEM "xmltv.dtd">`+"\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
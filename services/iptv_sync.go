@@ -2,11 +2,14 @@ package services
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -15,8 +18,6 @@ import (
 	"torrent-server/models"
 )
 
-// Default M3U source — configurable at runtime via admin settings
-var IPTVM3UURL = "https://iptv-org.github.io/iptv/index.m3u"
 var IPTVAPIBaseURL = "https://iptv-org.github.io/api"
 
 type IPTVSyncService struct {
@@ -29,14 +30,28 @@ type IPTVSyncService struct {
 type IPTVSyncStatus struct {
 	Running    bool   `json:"running"`
 	Phase      string `json:"phase"`
+	Source     string `json:"source,omitempty"` // source being synced
 	Progress   int    `json:"progress"`
 	Total      int    `json:"total"`
 	LastSync   string `json:"last_sync,omitempty"`
 	LastError  string `json:"last_error,omitempty"`
 	Channels   int    `json:"channels"`
+	Duplicates int    `json:"duplicates"` // channels kept by a higher-priority source
+	Removed    int    `json:"removed"`    // channels dropped from their playlist
 	Countries  int    `json:"countries"`
 	Categories int    `json:"categories"`
-	M3UURL     string `json:"m3u_url"`
+	M3UURL     string `json:"m3u_url,omitempty"`
+}
+
+// IPTVSourceUpdate changes an IPTV source; nil fields are kept
+type IPTVSourceUpdate struct {
+	Name     *string `json:"name"`
+	URL      *string `json:"url"`
+	Enabled  *bool   `json:"enabled"`
+	Priority *int    `json:"priority"`
+	Schedule *string `json:"schedule"`
+	Country  *string `json:"country"`
+	Category *string `json:"category"`
 }
 
 func NewIPTVSyncService(db *database.DB) *IPTVSyncService {
@@ -49,75 +64,292 @@ func NewIPTVSyncService(db *database.DB) *IPTVSyncService {
 func (s *IPTVSyncService) GetStatus() IPTVSyncStatus {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	st := s.status
-	st.M3UURL = IPTVM3UURL
-	return st
+	return s.status
+}
+
+// Sources returns every IPTV source by priority
+func (s *IPTVSyncService) Sources() ([]models.IPTVSource, error) {
+	return s.db.ListIPTVSources()
+}
+
+// CreateSource validates and stores a new IPTV source
+func (s *IPTVSyncService) CreateSource(src *models.IPTVSource) error {
+	src.ID = 0
+	if err := validateIPTVSource(src); err != nil {
+		return err
+	}
+	if err := s.db.SaveIPTVSource(src); err != nil {
+		return fmt.Errorf("failed to save source: %w", err)
+	}
+	log.Printf("[IPTV Sync] Added source %q (%s)", src.Name, src.URL)
+	return nil
+}
+
+// UpdateSource changes an IPTV source and stores it
+func (s *IPTVSyncService) UpdateSource(id uint, u IPTVSourceUpdate) (*models.IPTVSource, error) {
+	src, err := s.db.GetIPTVSource(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Name != nil {
+		src.Name = *u.Name
+	}
+	if u.URL != nil {
+		src.URL = *u.URL
+	}
+	if u.Enabled != nil {
+		src.Enabled = *u.Enabled
+	}
+	if u.Priority != nil {
+		src.Priority = *u.Priority
+	}
+	if u.Schedule != nil {
+		src.Schedule = *u.Schedule
+	}
+	if u.Country != nil {
+		src.Country = *u.Country
+	}
+	if u.Category != nil {
+		src.Category = *u.Category
+	}
+	if err := validateIPTVSource(src); err != nil {
+		return nil, err
+	}
+	if err := s.db.SaveIPTVSource(src); err != nil {
+		return nil, fmt.Errorf("failed to save source: %w", err)
+	}
+	return src, nil
 }
 
-// SyncFromM3U fetches an M3U URL and imports all channels
+// DeleteSource removes a source and its channels
+func (s *IPTVSyncService) DeleteSource(id uint) error {
+	if _, err := s.db.GetIPTVSource(id); err != nil {
+		return err
+	}
+	return s.db.DeleteIPTVSource(id)
+}
+
+func validateIPTVSource(src *models.IPTVSource) error {
+	src.Name = strings.TrimSpace(src.Name)
+	src.URL = strings.TrimSpace(src.URL)
+	src.Country = strings.ToUpper(strings.TrimSpace(src.Country))
+	src.Category = strings.TrimSpace(src.Category)
+	src.Schedule = strings.TrimSpace(src.Schedule)
+	if src.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !strings.HasPrefix(src.URL, "http://") && !strings.HasPrefix(src.URL, "https://") && !filepath.IsAbs(strings.TrimPrefix(src.URL, "file://")) {
+		return fmt.Errorf("url must be an http(s) URL or an absolute file path")
+	}
+	if src.Schedule != "" {
+		if _, err := ParseSchedule(src.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncFromM3U imports a playlist URL in the background. The URL becomes a
+// source of its own if no source has it yet; an empty URL syncs every
+// enabled source.
 func (s *IPTVSyncService) SyncFromM3U(m3uURL string) error {
-	s.mu.Lock()
-	if s.status.Running {
-		s.mu.Unlock()
-		return fmt.Errorf("sync already in progress")
+	if m3uURL == "" {
+		return s.startSync(nil)
 	}
-	s.status = IPTVSyncStatus{Running: true, Phase: "starting"}
-	s.mu.Unlock()
+	src, err := s.db.GetIPTVSourceByURL(m3uURL)
+	if err != nil {
+		src = &models.IPTVSource{Name: m3uURL, URL: m3uURL, Enabled: true, Priority: 100}
+		if err := s.CreateSource(src); err != nil {
+			return err
+		}
+	}
+	return s.startSync([]models.IPTVSource{*src})
+}
 
-	if m3uURL != "" {
-		IPTVM3UURL = m3uURL
+// SyncSource imports one source in the background, even if it is disabled
+func (s *IPTVSyncService) SyncSource(id uint) error {
+	src, err := s.db.GetIPTVSource(id)
+	if err != nil {
+		return err
 	}
+	return s.startSync([]models.IPTVSource{*src})
+}
 
-	go func() {
-		err := s.doM3USync(IPTVM3UURL)
-		s.mu.Lock()
-		s.status.Running = false
-		s.status.LastSync = time.Now().Format(time.RFC3339)
-		if err != nil {
-			s.status.LastError = err.Error()
-			s.status.Phase = "error: " + err.Error()
-		} else {
-			s.status.Phase = "completed"
-			s.status.LastError = ""
+// startSync imports the given sources, or every enabled source when nil, in
+// the background
+func (s *IPTVSyncService) startSync(sources []models.IPTVSource) error {
+	if sources == nil {
+		var err error
+		if sources, err = s.enabledSources(); err != nil {
+			return err
 		}
-		s.mu.Unlock()
+	}
+	if err := s.begin(); err != nil {
+		return err
+	}
+	go func() {
+		s.finish(s.syncSources(context.Background(), sources))
 	}()
+	return nil
+}
+
+// Sync imports every enabled source whose schedule is due and waits for it.
+// A source is due when its schedule has fired since its last sync.
+func (s *IPTVSyncService) Sync(ctx context.Context) error {
+	enabled, err := s.enabledSources()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var due []models.IPTVSource
+	for _, src := range enabled {
+		sched, err := ParseSchedule(src.Schedule)
+		if src.Schedule == "" || err != nil {
+			continue
+		}
+		if src.LastSyncAt == nil || !sched.Next(*src.LastSyncAt).After(now) {
+			due = append(due, src)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	if err := s.begin(); err != nil {
+		return err
+	}
+	err = s.syncSources(ctx, due)
+	s.finish(err)
+	return err
+}
+
+func (s *IPTVSyncService) enabledSources() ([]models.IPTVSource, error) {
+	sources, err := s.db.ListIPTVSources()
+	if err != nil {
+		return nil, err
+	}
+	var enabled []models.IPTVSource
+	for _, src := range sources {
+		if src.Enabled {
+			enabled = append(enabled, src)
+		}
+	}
+	return enabled, nil
+}
 
+func (s *IPTVSyncService) begin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Running {
+		return fmt.Errorf("sync already in progress")
+	}
+	s.status = IPTVSyncStatus{Running: true, Phase: "starting"}
 	return nil
 }
 
-func (s *IPTVSyncService) doM3USync(m3uURL string) error {
-	// 1. Fetch reference data from iptv-org API (countries/categories with names & flags)
+func (s *IPTVSyncService) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.LastSync = time.Now().Format(time.RFC3339)
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.Phase = "error: " + err.Error()
+	} else {
+		s.status.Phase = "completed"
+		s.status.LastError = ""
+	}
+}
+
+// syncSources imports each source in turn. A failing source is recorded on
+// the source and doesn't stop the others.
+func (s *IPTVSyncService) syncSources(ctx context.Context, sources []models.IPTVSource) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("no IPTV sources enabled")
+	}
+
+	// Reference data from iptv-org API (countries/categories with names & flags)
 	s.setPhase("fetching reference data", 0, 0)
 	s.syncCountriesFromAPI()
 	s.syncCategoriesFromAPI()
 
-	// 2. Fetch and parse M3U
-	s.setPhase("downloading M3U", 0, 0)
-	log.Printf("[IPTV Sync] Fetching M3U from %s", m3uURL)
+	var failed []string
+	for _, src := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.status.Source = src.Name
+		s.status.M3UURL = src.URL
+		s.mu.Unlock()
 
-	resp, err := s.client.Get(m3uURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch M3U: %w", err)
+		err := s.syncSource(&src)
+		lastError := ""
+		if err != nil {
+			log.Printf("[IPTV Sync] Source %q failed: %v", src.Name, err)
+			lastError = err.Error()
+			failed = append(failed, src.Name)
+		}
+		s.db.UpdateIPTVSourceSync(src.ID, time.Now(), lastError)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d sources failed: %s", len(failed), len(sources), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// openPlaylist opens a source's playlist, from the web or from disk
+func (s *IPTVSyncService) openPlaylist(location string) (io.ReadCloser, error) {
+	if path, ok := strings.CutPrefix(location, "file://"); ok || filepath.IsAbs(location) {
+		if !ok {
+			path = location
+		}
+		return os.Open(path)
 	}
-	defer resp.Body.Close()
 
+	resp, err := s.client.Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch M3U: %w", err)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("M3U fetch returned HTTP %d", resp.StatusCode)
+		resp.Body.Close()
+		return nil, fmt.Errorf("M3U fetch returned HTTP %d", resp.StatusCode)
 	}
+	return resp.Body, nil
+}
 
-	channels, err := parseM3U(resp.Body)
+// syncSource imports one source's playlist. Channels another source with a
+// lower priority number already has, by ID or stream URL, are left to it.
+// Channels the source had before but no longer lists are removed; other
+// sources' channels are never touched.
+func (s *IPTVSyncService) syncSource(src *models.IPTVSource) error {
+	s.setPhase("downloading M3U", 0, 0)
+	log.Printf("[IPTV Sync] Fetching M3U for %q from %s", src.Name, src.URL)
+
+	body, err := s.openPlaylist(src.URL)
+	if err != nil {
+		return err
+	}
+	channels, err := parseM3U(body)
+	body.Close()
 	if err != nil {
 		return fmt.Errorf("failed to parse M3U: %w", err)
 	}
+	if len(channels) == 0 {
+		// Likely a broken download; keep the channels we have
+		return fmt.Errorf("playlist has no channels")
+	}
+	log.Printf("[IPTV Sync] Parsed %d channels from %q", len(channels), src.Name)
 
-	log.Printf("[IPTV Sync] Parsed %d channels from M3U", len(channels))
+	owners, err := s.ownerPriorities(src.ID)
+	if err != nil {
+		return err
+	}
 
-	// 3. Store channels (skip blocklisted)
+	started := time.Now()
 	blocklist := s.db.GetBlocklistedIDs()
 	total := len(channels)
-	stored := 0
-	skipped := 0
+	stored, skipped, duplicates := 0, 0, 0
 	countrySeen := make(map[string]bool)
 	categorySeen := make(map[string]bool)
 
@@ -129,7 +361,18 @@ func (s *IPTVSyncService) doM3USync(m3uURL string) error {
 		if i%500 == 0 {
 			s.setPhase("storing channels", i, total)
 		}
+		if owners.outranks(ch, src.Priority) {
+			duplicates++
+			continue
+		}
 
+		ch.SourceID = src.ID
+		if src.Country != "" {
+			ch.Country = src.Country
+		}
+		if src.Category != "" {
+			ch.Categories = []string{src.Category}
+		}
 		if err := s.db.UpsertChannel(&ch); err == nil {
 			stored++
 		}
@@ -143,6 +386,11 @@ func (s *IPTVSyncService) doM3USync(m3uURL string) error {
 		}
 	}
 
+	removed, err := s.db.DeleteStaleSourceChannels(src.ID, started)
+	if err != nil {
+		log.Printf("[IPTV Sync] Failed to remove dropped channels of %q: %v", src.Name, err)
+	}
+
 	// Auto-create any countries/categories not in reference data
 	for code := range countrySeen {
 		s.db.UpsertChannelCountry(&models.ChannelCountry{
@@ -158,17 +406,70 @@ func (s *IPTVSyncService) doM3USync(m3uURL string) error {
 	}
 
 	s.mu.Lock()
-	s.status.Channels = stored
+	s.status.Channels += stored
+	s.status.Duplicates += duplicates
+	s.status.Removed += int(removed)
 	s.status.Countries = len(countrySeen)
 	s.status.Categories = len(categorySeen)
 	s.mu.Unlock()
 
-	log.Printf("[IPTV Sync] Stored %d channels, %d countries, %d categories (skipped %d blocklisted)",
-		stored, len(countrySeen), len(categorySeen), skipped)
+	log.Printf("[IPTV Sync] %q: stored %d channels, %d countries, %d categories (skipped %d blocklisted, %d kept by other sources, removed %d)",
+		src.Name, stored, len(countrySeen), len(categorySeen), skipped, duplicates, removed)
 
 	return nil
 }
 
+// channelOwners maps stored channel IDs and stream URLs to the priority of
+// the enabled source that has them
+type channelOwners struct {
+	byID     map[string]int
+	byStream map[string]int
+}
+
+// ownerPriorities collects the channels of every enabled source other than
+// sourceID
+func (s *IPTVSyncService) ownerPriorities(sourceID uint) (*channelOwners, error) {
+	sources, err := s.db.ListIPTVSources()
+	if err != nil {
+		return nil, err
+	}
+	priorities := make(map[uint]int)
+	for _, src := range sources {
+		if src.Enabled && src.ID != sourceID {
+			priorities[src.ID] = src.Priority
+		}
+	}
+
+	stored, err := s.db.ListChannelOwners()
+	if err != nil {
+		return nil, err
+	}
+	owners := &channelOwners{byID: make(map[string]int), byStream: make(map[string]int)}
+	for _, o := range stored {
+		p, ok := priorities[o.SourceID]
+		if !ok {
+			continue
+		}
+		owners.byID[o.ID] = p
+		if o.StreamURL != "" {
+			owners.byStream[o.StreamURL] = p
+		}
+	}
+	return owners, nil
+}
+
+// outranks reports whether a source with a lower priority number than
+// priority already has the channel. Ties go to the source syncing now.
+func (o *channelOwners) outranks(ch models.Channel, priority int) bool {
+	if p, ok := o.byID[ch.ID]; ok && p < priority {
+		return true
+	}
+	if p, ok := o.byStream[ch.StreamURL]; ok && p < priority {
+		return true
+	}
+	return false
+}
+
 func (s *IPTVSyncService) setPhase(phase string, progress, total int) {
 	s.mu.Lock()
 	s.status.Phase = phase
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-server/database"
+	"torrent-server/models"
+)
+
+var localVideoExts = map[string]bool{
+	".mp4": true, ".mkv": true, ".avi": true, ".mov": true, ".wmv": true,
+	".webm": true, ".m4v": true, ".mpg": true, ".mpeg": true, ".ts": true,
+}
+
+var (
+	episodePattern    = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])s(\d{1,2})\s?e(\d{1,3})|(?:^|[^0-9])(\d{1,2})x(\d{2,3})(?:[^0-9]|$)`)
+	yearPattern       = regexp.MustCompile(`(?:19|20)\d{2}`)
+	releaseTagPattern = regexp.MustCompile(`(?i)(?:^|[\s\[(])(2160p|1080p|720p|480p|4k|uhd|bluray|blu-ray|brrip|bdrip|webrip|web-dl|webdl|hdtv|dvdrip|hdrip|x264|x265|h264|h265|hevc|remux|proper|repack|extended|unrated|imax)(?:[\s\])]|$)`)
+	seasonDirPattern  = regexp.MustCompile(`(?i)^(season|series|staffel|saison|s)\s*\d+$|^specials$`)
+)
+
+// LibraryService scans local media folders and attaches the files it can
+// identify to movies and episodes
+type LibraryService struct {
+	db   *database.DB
+	sync *SyncService
+}
+
+func NewLibraryService(db *database.DB, sync *SyncService) *LibraryService {
+	return &LibraryService{db: db, sync: sync}
+}
+
+// mediaName is what a file or folder name says about its content
+type mediaName struct {
+	Title   string
+	Year    int
+	Season  int
+	Episode int
+	Quality string
+}
+
+// parseMediaName reads release-style names such as
+// "The.Matrix.1999.1080p.BluRay.x264", "Heat (1995)" or "Show.Name.S02E05.720p"
+func parseMediaName(name string) mediaName {
+	var m mediaName
+	clean := strings.NewReplacer(".", " ", "_", " ").Replace(name)
+	cut := len(clean)
+
+	if loc := episodePattern.FindStringSubmatchIndex(clean); loc != nil {
+		if loc[2] >= 0 {
+			m.Season, _ = strconv.Atoi(clean[loc[2]:loc[3]])
+			m.Episode, _ = strconv.Atoi(clean[loc[4]:loc[5]])
+		} else {
+			m.Season, _ = strconv.Atoi(clean[loc[6]:loc[7]])
+			m.Episode, _ = strconv.Atoi(clean[loc[8]:loc[9]])
+		}
+		cut = loc[0]
+	}
+	if loc := releaseTagPattern.FindStringIndex(clean); loc != nil && loc[0] < cut {
+		cut = loc[0]
+	}
+	if i := strings.IndexAny(clean, "[{"); i >= 0 && i < cut {
+		cut = i
+	}
+	m.Quality = localQuality(clean)
+
+	// The last year before the release tags ends the title, so
+	// "2001 A Space Odyssey 1968" keeps its leading number
+	title := clean[:cut]
+	years := yearPattern.FindAllStringIndex(title, -1)
+	for i := len(years) - 1; i >= 0; i-- {
+		start, end := years[i][0], years[i][1]
+		if start == 0 || isDigit(title[start-1]) || (end < len(title) && isDigit(title[end])) {
+			continue
+		}
+		m.Year, _ = strconv.Atoi(title[start:end])
+		title = title[:start]
+		break
+	}
+
+	m.Title = strings.Trim(strings.Join(strings.Fields(title), " "), " -([{")
+	return m
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func localQuality(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "2160p") || strings.Contains(name, "4k") || strings.Contains(name, "uhd"):
+		return "2160p"
+	case strings.Contains(name, "1080p"):
+		return "1080p"
+	case strings.Contains(name, "720p"):
+		return "720p"
+	case strings.Contains(name, "480p"):
+		return "480p"
+	}
+	return ""
+}
+
+// showDir returns the series folder of an episode file, skipping
+// "Season N" style folders
+func showDir(path, root string) string {
+	dir := filepath.Dir(path)
+	for dir != root && seasonDirPattern.MatchString(filepath.Base(dir)) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// findNFOImdbID looks for an IMDb ID in the given NFO files
+func findNFOImdbID(paths ...string) string {
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if id := imdbIDPattern.FindString(string(data)); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+type localVideo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// walkVideos lists the video files under root, skipping hidden folders,
+// NAS metadata folders and samples
+func walkVideos(root string) ([]localVideo, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	var videos []localVideo
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subfolders are skipped rather than failing the scan
+			if path != root {
+				log.Printf("[Library] Skipping %s: %v", path, err)
+				return fs.SkipDir
+			}
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "@")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !localVideoExts[strings.ToLower(filepath.Ext(name))] || strings.Contains(strings.ToLower(name), "sample") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		videos = append(videos, localVideo{path: path, size: fi.Size(), modTime: fi.ModTime()})
+		return nil
+	})
+	return videos, err
+}
+
+// ScanAll rescans every enabled library folder
+func (l *LibraryService) ScanAll(ctx context.Context, p *JobProgress) error {
+	folders, err := l.db.ListLibraryFolders()
+	if err != nil {
+		return err
+	}
+
+	type pendingScan struct {
+		folder *models.LibraryFolder
+		videos []localVideo
+	}
+	var scans []pendingScan
+	total := 0
+	for i := range folders {
+		folder := &folders[i]
+		if !folder.Enabled {
+			continue
+		}
+		videos, err := walkVideos(folder.Path)
+		if err != nil {
+			// Keep the known files: an unmounted share shouldn't empty the library
+			log.Printf("[Library] Failed to scan %s: %v", folder.Path, err)
+			folder.LastError = err.Error()
+			l.db.SaveLibraryFolder(folder)
+			continue
+		}
+		scans = append(scans, pendingScan{folder, videos})
+		total += len(videos)
+	}
+
+	p.SetTotal(total)
+	for _, scan := range scans {
+		if err := l.scanFolder(ctx, scan.folder, scan.videos, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *LibraryService) scanFolder(ctx context.Context, folder *models.LibraryFolder, videos []localVideo, p *JobProgress) error {
+	known, err := l.db.GetFolderLocalFiles(folder.ID)
+	if err != nil {
+		return err
+	}
+
+	added, matched := 0, 0
+	seen := make(map[string]bool, len(videos))
+	for _, v := range videos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		seen[v.path] = true
+		f := known[v.path]
+		p.SetCurrent(filepath.Base(v.path))
+
+		// Unchanged files keep their match, or their match error so a failed
+		// search isn't repeated every scan. Mtimes are compared in seconds
+		// since Postgres only keeps microseconds.
+		if f != nil && f.SizeBytes == v.size && f.ModTime.Unix() == v.modTime.Unix() &&
+			(f.MatchedBy != "" || f.MatchError != "") {
+			p.Add(1, 0, 0)
+			continue
+		}
+		if f == nil {
+			f = &models.LocalFile{FolderID: folder.ID, Path: v.path}
+			added++
+		}
+		f.FileName = filepath.Base(v.path)
+		f.SizeBytes = v.size
+		f.ModTime = v.modTime
+
+		if err := l.match(f, folder); err != nil {
+			f.MovieID, f.EpisodeID = nil, nil
+			f.MatchedBy = ""
+			f.MatchError = err.Error()
+			p.Add(1, 0, 1)
+		} else {
+			f.MatchError = ""
+			matched++
+			p.Add(1, 1, 0)
+		}
+		if err := l.db.SaveLocalFile(f); err != nil {
+			log.Printf("[Library] Failed to save %s: %v", v.path, err)
+		}
+	}
+
+	var removed []uint
+	for path, f := range known {
+		if !seen[path] {
+			removed = append(removed, f.ID)
+		}
+	}
+	if err := l.db.DeleteLocalFiles(removed); err != nil {
+		log.Printf("[Library] Failed to remove missing files: %v", err)
+	}
+
+	now := time.Now()
+	folder.FileCount = len(videos)
+	folder.MatchedCount = l.db.CountMatchedLocalFiles(folder.ID)
+	folder.LastScanAt = &now
+	folder.LastError = ""
+	l.db.SaveLibraryFolder(folder)
+
+	log.Printf("[Library] Scanned %s: %d files, %d new, %d removed, %d matched",
+		folder.Path, len(videos), added, len(removed), matched)
+	return nil
+}
+
+// match identifies a file from its NFO, an IMDb ID in its path, or an IMDb
+// search on the parsed title and year, then links it to the movie or episode,
+// syncing the title into the library when it is missing
+func (l *LibraryService) match(f *models.LocalFile, folder *models.LibraryFolder) error {
+	base := strings.TrimSuffix(f.FileName, filepath.Ext(f.FileName))
+	name := parseMediaName(base)
+	f.Quality = name.Quality
+
+	isEpisode := folder.Kind == models.LibraryKindSeries ||
+		(folder.Kind != models.LibraryKindMovies && name.Episode > 0)
+	dir := filepath.Dir(f.Path)
+
+	var imdbCode, matchedBy string
+	if f.MatchedBy == "manual" && f.ImdbCode != "" {
+		imdbCode, matchedBy = f.ImdbCode, "manual"
+	}
+
+	if isEpisode {
+		show := showDir(f.Path, folder.Path)
+		if name.Title == "" {
+			name.Title = parseMediaName(filepath.Base(show)).Title
+		}
+		f.ParsedTitle, f.ParsedYear = name.Title, name.Year
+		f.SeasonNumber, f.EpisodeNumber = name.Season, name.Episode
+		if f.EpisodeNumber == 0 {
+			return fmt.Errorf("no season/episode number in file name")
+		}
+		if imdbCode == "" {
+			if imdbCode = findNFOImdbID(filepath.Join(show, "tvshow.nfo"), filepath.Join(dir, "tvshow.nfo")); imdbCode != "" {
+				matchedBy = "nfo"
+			} else if imdbCode = imdbIDPattern.FindString(show); imdbCode != "" {
+				matchedBy = "filename"
+			}
+		}
+	} else {
+		// Release files are often named "cd1.avi" or lack a year; the
+		// movie's folder usually has both
+		if name.Title == "" || name.Year == 0 {
+			if dirName := parseMediaName(filepath.Base(dir)); dirName.Title != "" && (name.Title == "" || dirName.Year != 0) {
+				name.Title, name.Year = dirName.Title, dirName.Year
+			}
+		}
+		f.ParsedTitle, f.ParsedYear = name.Title, name.Year
+		f.SeasonNumber, f.EpisodeNumber = 0, 0
+		if imdbCode == "" {
+			if imdbCode = findNFOImdbID(filepath.Join(dir, base+".nfo"), filepath.Join(dir, "movie.nfo")); imdbCode != "" {
+				matchedBy = "nfo"
+			} else if imdbCode = imdbIDPattern.FindString(f.Path[len(folder.Path):]); imdbCode != "" {
+				matchedBy = "filename"
+			}
+		}
+	}
+
+	if imdbCode == "" {
+		entry := ListEntry{Title: f.ParsedTitle, Year: f.ParsedYear, Kind: "movie"}
+		if isEpisode {
+			entry.Kind = "series"
+		}
+		if err := l.sync.resolveListEntry(&entry); err != nil {
+			return err
+		}
+		imdbCode, matchedBy = entry.ImdbCode, "search"
+	}
+	f.ImdbCode = imdbCode
+	f.MatchedBy = matchedBy
+
+	if isEpisode {
+		return l.linkEpisode(f)
+	}
+	return l.linkMovie(f)
+}
+
+func (l *LibraryService) linkMovie(f *models.LocalFile) error {
+	movie, _ := l.db.GetMovieByIMDB(f.ImdbCode)
+	if movie == nil {
+		var err error
+		if movie, err = l.sync.SyncMovie(f.ImdbCode); err != nil {
+			return fmt.Errorf("sync %s failed: %w", f.ImdbCode, err)
+		}
+	}
+	f.MovieID, f.EpisodeID = &movie.ID, nil
+	return nil
+}
+
+func (l *LibraryService) linkEpisode(f *models.LocalFile) error {
+	series, _ := l.db.GetSeriesByIMDB(f.ImdbCode)
+	if series == nil {
+		var err error
+		if series, err = l.sync.SyncSeries(f.ImdbCode); err != nil {
+			return fmt.Errorf("sync %s failed: %w", f.ImdbCode, err)
+		}
+	}
+	ep, err := l.db.GetEpisodeByNumber(series.ID, f.SeasonNumber, f.EpisodeNumber)
+	if err != nil {
+		return fmt.Errorf("%s has no S%02dE%02d", series.Title, f.SeasonNumber, f.EpisodeNumber)
+	}
+	f.EpisodeID, f.MovieID = &ep.ID, nil
+	return nil
+}
+
+// MatchFile manually links a local file to an IMDb title. Season and
+// episode override the numbers parsed from the file name. Manual matches
+// survive rescans.
+func (l *LibraryService) MatchFile(id uint, imdbCode string, season, episode int) (*models.LocalFile, error) {
+	f, err := l.db.GetLocalFile(id)
+	if err != nil {
+		return nil, err
+	}
+	if !imdbIDPattern.MatchString(imdbCode) {
+		return nil, fmt.Errorf("invalid IMDb ID %q", imdbCode)
+	}
+
+	f.ImdbCode, f.MatchedBy = imdbCode, "manual"
+	if episode > 0 {
+		f.SeasonNumber, f.EpisodeNumber = season, episode
+		err = l.linkEpisode(f)
+	} else {
+		f.SeasonNumber, f.EpisodeNumber = 0, 0
+		err = l.linkMovie(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.MatchError = ""
+	if err := l.db.SaveLocalFile(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Supported list export formats
+const (
+	ListFormatIMDb       = "imdb"
+	ListFormatLetterboxd = "letterboxd"
+	ListFormatTrakt      = "trakt"
+)
+
+var imdbIDPattern = regexp.MustCompile(`tt\d{7,}`)
+
+// ListEntry is one row of an imported list
+type ListEntry struct {
+	Row      int    `json:"row"`
+	Title    string `json:"title"`
+	Year     int    `json:"year,omitempty"`
+	ImdbCode string `json:"imdb_code,omitempty"`
+	// "movie", "series", "" when the export doesn't say, or the
+	// export's own type for entries that can't be imported
+	Kind string `json:"kind,omitempty"`
+}
+
+// UnmatchedListRow is an entry the importer could not add, with the reason
+type UnmatchedListRow struct {
+	ListEntry
+	Reason string `json:"reason"`
+}
+
+// ListImportReport summarises a list import
+type ListImportReport struct {
+	Format    string             `json:"format"`
+	Total     int                `json:"total"`
+	Added     int                `json:"added"`
+	Synced    int                `json:"synced"`
+	Series    int                `json:"series_synced,omitempty"`
+	Unmatched []UnmatchedListRow `json:"unmatched"`
+}
+
+// DetectListFormat guesses the export format from its contents
+func DetectListFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return ListFormatTrakt
+	}
+	firstLine := string(trimmed)
+	if i := bytes.IndexByte(trimmed, '\n'); i >= 0 {
+		firstLine = string(trimmed[:i])
+	}
+	firstLine = strings.ToLower(firstLine)
+	if strings.Contains(firstLine, "letterboxd") || strings.Contains(firstLine, "letterboxd uri") {
+		return ListFormatLetterboxd
+	}
+	if strings.Contains(firstLine, "const") {
+		return ListFormatIMDb
+	}
+	if strings.Contains(firstLine, "name") && strings.Contains(firstLine, "year") {
+		return ListFormatLetterboxd
+	}
+	return ""
+}
+
+// ParseListExport reads an IMDb CSV, Letterboxd CSV or Trakt JSON export.
+// An empty format is detected from the data.
+func ParseListExport(format string, data []byte) ([]ListEntry, string, error) {
+	if format == "" {
+		format = DetectListFormat(data)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var entries []ListEntry
+	var err error
+	switch format {
+	case ListFormatIMDb:
+		entries, err = parseIMDbListCSV(data)
+	case ListFormatLetterboxd:
+		entries, err = parseLetterboxdCSV(data)
+	case ListFormatTrakt:
+		entries, err = parseTraktJSON(data)
+	default:
+		return nil, format, fmt.Errorf("unrecognised list format")
+	}
+	return entries, format, err
+}
+
+// readCSV returns the header index and rows of a CSV table
+func readCSV(r io.Reader) (map[string]int, [][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("empty file")
+	}
+	header := make(map[string]int)
+	for i, h := range records[0] {
+		header[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	return header, records[1:], nil
+}
+
+func csvField(row []string, header map[string]int, names ...string) string {
+	for _, name := range names {
+		if i, ok := header[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+	}
+	return ""
+}
+
+// parseIMDbListCSV reads an IMDb list/watchlist/ratings export
+// (Position,Const,Created,Modified,Description,Title,URL,Title Type,...)
+func parseIMDbListCSV(data []byte) ([]ListEntry, error) {
+	header, rows, err := readCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := header["const"]; !ok {
+		return nil, fmt.Errorf("missing Const column")
+	}
+
+	var entries []ListEntry
+	for i, row := range rows {
+		e := ListEntry{
+			Row:      i + 2,
+			Title:    csvField(row, header, "title"),
+			ImdbCode: imdbIDPattern.FindString(csvField(row, header, "const")),
+		}
+		e.Year, _ = strconv.Atoi(csvField(row, header, "year"))
+		switch strings.ToLower(strings.ReplaceAll(csvField(row, header, "title type"), " ", "")) {
+		case "tvseries", "tvminiseries":
+			e.Kind = "series"
+		case "":
+		default:
+			e.Kind = "movie"
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// parseLetterboxdCSV reads a Letterboxd watchlist/watched/ratings export
+// (Date,Name,Year,Letterboxd URI) or list export, whose table follows a
+// short metadata block.
+func parseLetterboxdCSV(data []byte) ([]ListEntry, error) {
+	// List exports start with "Letterboxd list export vN" and a metadata
+	// table; the entries table is the last blank-line separated block.
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if strings.HasPrefix(strings.ToLower(text), "letterboxd list export") {
+		blocks := strings.Split(text, "\n\n")
+		text = blocks[len(blocks)-1]
+	}
+
+	header, rows, err := readCSV(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := header["name"]; !ok {
+		return nil, fmt.Errorf("missing Name column")
+	}
+
+	var entries []ListEntry
+	for i, row := range rows {
+		e := ListEntry{
+			Row:   i + 2,
+			Title: csvField(row, header, "name"),
+			Kind:  "movie",
+		}
+		if e.Title == "" {
+			continue
+		}
+		e.Year, _ = strconv.Atoi(csvField(row, header, "year"))
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+type traktItem struct {
+	Type  string      `json:"type"`
+	Movie *traktTitle `json:"movie"`
+	Show  *traktTitle `json:"show"`
+}
+
+type traktTitle struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+	IDs   struct {
+		IMDb string `json:"imdb"`
+	} `json:"ids"`
+}
+
+// parseTraktJSON reads a Trakt list/watchlist/history export: either an
+// array of items or an object with an "items" array.
+func parseTraktJSON(data []byte) ([]ListEntry, error) {
+	var items []traktItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		var wrapped struct {
+			Items []traktItem `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, fmt.Errorf("invalid Trakt JSON: %w", err)
+		}
+		items = wrapped.Items
+	}
+
+	var entries []ListEntry
+	for i, item := range items {
+		e := ListEntry{Row: i + 1}
+		var t *traktTitle
+		switch {
+		case item.Movie != nil:
+			t, e.Kind = item.Movie, "movie"
+		case item.Show != nil:
+			t, e.Kind = item.Show, "series"
+		default:
+			// Episodes, seasons and people have no list equivalent
+			e.Kind = item.Type
+			entries = append(entries, e)
+			continue
+		}
+		e.Title, e.Year, e.ImdbCode = t.Title, t.Year, t.IDs.IMDb
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// resolveListEntry fills in the IMDb ID and kind of an entry that only has
+// a title and year by searching IMDb.
+func (s *SyncService) resolveListEntry(e *ListEntry) error {
+	if e.ImdbCode != "" {
+		return nil
+	}
+	if e.Title == "" {
+		return fmt.Errorf("no title or IMDb ID")
+	}
+
+	typeFilter := ""
+	if e.Kind == "movie" {
+		typeFilter = "movie"
+	} else if e.Kind == "series" {
+		typeFilter = "tvSeries"
+	}
+	titles, err := s.imdb.SearchTitles(e.Title, typeFilter)
+	if err != nil {
+		return fmt.Errorf("IMDb search failed: %w", err)
+	}
+
+	for _, t := range titles {
+		if !strings.EqualFold(t.PrimaryTitle, e.Title) && !strings.EqualFold(t.OriginalTitle, e.Title) {
+			continue
+		}
+		// Release years often differ by one between sites
+		if e.Year != 0 && t.StartYear != 0 && (t.StartYear < e.Year-1 || t.StartYear > e.Year+1) {
+			continue
+		}
+		e.ImdbCode = t.ID
+		if e.Kind == "" {
+			if t.Type == "tvSeries" || t.Type == "tvMiniSeries" {
+				e.Kind = "series"
+			} else {
+				e.Kind = "movie"
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("no IMDb title matches %q (%d)", e.Title, e.Year)
+}
+
+// ErrListImportPending is returned while another list import is queued or
+// running
+var ErrListImportPending = errors.New("a list import is already in progress")
+
+// ListImport is a parsed list file waiting for the list_import job
+type ListImport struct {
+	ListID  uint
+	Format  string
+	Replace bool // clear the list's movies before adding the entries
+	Entries []ListEntry
+}
+
+// listImports holds the import waiting for the list_import job and the last
+// report of every list imported since startup
+type listImports struct {
+	mu      sync.Mutex
+	pending *ListImport
+	running bool
+	reports map[uint]*ListImportReport
+}
+
+// QueueListImport hands an import to the next run of the list_import job.
+// Only one import is queued or running at a time.
+func (s *SyncService) QueueListImport(imp *ListImport) error {
+	s.listImports.mu.Lock()
+	defer s.listImports.mu.Unlock()
+	if s.listImports.pending != nil || s.listImports.running {
+		return ErrListImportPending
+	}
+	s.listImports.pending = imp
+	return nil
+}
+
+// DropListImport forgets a queued import whose job could not be started
+func (s *SyncService) DropListImport(imp *ListImport) {
+	s.listImports.mu.Lock()
+	if s.listImports.pending == imp {
+		s.listImports.pending = nil
+	}
+	s.listImports.mu.Unlock()
+}
+
+// ListImportReport returns the report of the last import into a list, nil
+// if none finished since startup
+func (s *SyncService) ListImportReport(listID uint) *ListImportReport {
+	s.listImports.mu.Lock()
+	defer s.listImports.mu.Unlock()
+	return s.listImports.reports[listID]
+}
+
+// RunListImport is the list_import job: it imports the queued list file, if
+// any.
+func (s *SyncService) RunListImport(ctx context.Context, p *JobProgress) error {
+	s.listImports.mu.Lock()
+	imp := s.listImports.pending
+	s.listImports.pending = nil
+	s.listImports.running = imp != nil
+	s.listImports.mu.Unlock()
+	if imp == nil {
+		return nil
+	}
+	defer func() {
+		s.listImports.mu.Lock()
+		s.listImports.running = false
+		s.listImports.mu.Unlock()
+	}()
+
+	report, err := s.ImportListEntries(ctx, imp, p)
+	report.Format = imp.Format
+	s.listImports.mu.Lock()
+	if s.listImports.reports == nil {
+		s.listImports.reports = make(map[uint]*ListImportReport)
+	}
+	s.listImports.reports[imp.ListID] = report
+	s.listImports.mu.Unlock()
+	return err
+}
+
+// ImportListEntries resolves entries to IMDb IDs, syncs anything missing
+// from the library and adds the movies to the curated list in file order,
+// after the movies already pinned. Series are synced into the library but
+// curated lists only hold movies. A replacing import only clears the list
+// once every entry has been resolved, and leaves it alone when the import is
+// cancelled or nothing matched.
+func (s *SyncService) ImportListEntries(ctx context.Context, imp *ListImport, p *JobProgress) (*ListImportReport, error) {
+	report := &ListImportReport{Total: len(imp.Entries), Unmatched: []UnmatchedListRow{}}
+	unmatched := func(e ListEntry, reason string) {
+		report.Unmatched = append(report.Unmatched, UnmatchedListRow{ListEntry: e, Reason: reason})
+		p.Add(1, 0, 1)
+	}
+	p.SetTotal(len(imp.Entries))
+
+	type resolvedEntry struct {
+		entry   ListEntry
+		movieID uint
+	}
+	var resolved []resolvedEntry
+	for _, e := range imp.Entries {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		p.SetCurrent(e.Title)
+		if e.Kind != "" && e.Kind != "movie" && e.Kind != "series" {
+			unmatched(e, "unsupported entry type "+e.Kind)
+			continue
+		}
+		if err := s.resolveListEntry(&e); err != nil {
+			unmatched(e, err.Error())
+			continue
+		}
+
+		if e.Kind == "series" {
+			if existing, _ := s.db.GetSeriesByIMDB(e.ImdbCode); existing == nil {
+				if _, err := s.SyncSeries(e.ImdbCode); err != nil {
+					unmatched(e, "series sync failed: "+err.Error())
+					continue
+				}
+				report.Series++
+			}
+			unmatched(e, "series can't be added to a movie list")
+			continue
+		}
+
+		movie, _ := s.db.GetMovieByIMDB(e.ImdbCode)
+		if movie == nil {
+			var err error
+			movie, err = s.SyncMovie(e.ImdbCode)
+			if err != nil {
+				log.Printf("[ListImport] Failed to sync %s (%s): %v", e.Title, e.ImdbCode, err)
+				unmatched(e, "sync failed: "+err.Error())
+				continue
+			}
+			report.Synced++
+		}
+		resolved = append(resolved, resolvedEntry{entry: e, movieID: movie.ID})
+		p.Add(1, 0, 0)
+	}
+	p.SetCurrent("")
+
+	startOrder := 0
+	switch {
+	case imp.Replace && len(resolved) == 0:
+		log.Printf("[ListImport] List %d: nothing matched, keeping its current movies", imp.ListID)
+		return report, nil
+	case imp.Replace:
+		if err := s.db.ClearCuratedListMovies(imp.ListID); err != nil {
+			return report, fmt.Errorf("failed to clear list: %w", err)
+		}
+	default:
+		startOrder = s.db.MaxCuratedListOrder(imp.ListID)
+	}
+
+	for i, r := range resolved {
+		if err := s.db.AddMovieToCuratedList(imp.ListID, r.movieID, startOrder+i+1); err != nil {
+			report.Unmatched = append(report.Unmatched, UnmatchedListRow{ListEntry: r.entry, Reason: "failed to add to list: " + err.Error()})
+			p.Add(0, 0, 1)
+			continue
+		}
+		report.Added++
+		p.Add(0, 1, 0)
+	}
+
+	log.Printf("[ListImport] List %d: %d/%d added, %d synced, %d unmatched",
+		imp.ListID, report.Added, report.Total, report.Synced, len(report.Unmatched))
+	return report, nil
+}
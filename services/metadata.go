@@ -0,0 +1,698 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"torrent-server/models"
+)
+
+// MetadataProvider is the interface for title metadata sources (IMDB, TMDB, ...).
+// All lookups are keyed by IMDB ID so providers are interchangeable.
+type MetadataProvider interface {
+	Name() string
+	Title(imdbID string) (*MetadataTitle, error)
+	Credits(imdbID string) (*MetadataCredits, error)
+	Images(imdbID string) ([]MetadataImage, error)
+	Videos(imdbID string) ([]MetadataVideo, error)
+	Episodes(imdbID string) ([]MetadataEpisode, error)
+}
+
+// BoxOfficeProvider is implemented by metadata providers that know budget and gross.
+type BoxOfficeProvider interface {
+	BoxOffice(imdbID string) (budget, gross string, err error)
+}
+
+// LocalizedProvider is implemented by metadata providers that can return
+// translated titles, summaries and posters for a locale (e.g. "sq", "tr").
+type LocalizedProvider interface {
+	Localized(imdbID, locale string) (*MetadataLocalized, error)
+	LocalizedEpisodes(imdbID, locale string) ([]MetadataEpisode, error)
+}
+
+// MetadataLocalized is a translated view of a title
+type MetadataLocalized struct {
+	Title     string
+	Plot      string
+	Tagline   string
+	PosterURL string // empty unless the provider has a poster for the language
+}
+
+// MetadataTitle is the provider-neutral title record
+type MetadataTitle struct {
+	Type          string // IMDB-style type: movie, tvMovie, tvSeries, tvMiniSeries, ...
+	Title         string
+	OriginalTitle string
+	Year          int
+	EndYear       *int
+	Runtime       int // minutes
+	Genres        []string
+	Plot          string
+	Tagline       string
+	Rating        float64
+	VoteCount     int
+	Metacritic    int
+	ContentRating string
+	PosterURL     string
+	BackgroundURL string
+}
+
+// MetadataCredits holds the people attached to a title
+type MetadataCredits struct {
+	Directors []string
+	Writers   []string
+	Cast      []models.Cast
+}
+
+type MetadataImage struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+type MetadataVideo struct {
+	ID   string
+	Name string
+	Site string // "YouTube" when ID is a YouTube key
+	Type string // Trailer, Teaser, Clip, ...
+}
+
+type MetadataEpisode struct {
+	Season   int
+	Episode  int
+	Title    string
+	Plot     string
+	Runtime  int // minutes
+	AirDate  string
+	StillURL string
+	ImdbCode string
+}
+
+type MetadataSeason struct {
+	Season   int
+	Episodes []MetadataEpisode
+}
+
+// RichMovieData combines data from multiple metadata endpoints
+type RichMovieData struct {
+	Title            string
+	OriginalTitle    string
+	Year             int
+	Runtime          int // minutes
+	Genres           []string
+	Plot             string
+	Tagline          string
+	Rating           float64
+	VoteCount        int
+	Metacritic       int
+	ContentRating    string
+	IMDBType         string // Original IMDB type (movie, tvMovie, tvSpecial, etc.)
+	PosterURL        string
+	BackgroundURL    string
+	Directors        []string
+	Writers          []string
+	Cast             []models.Cast
+	Budget           string
+	BoxOfficeGross   string
+	YouTubeTrailerID string
+	AllImages        []string
+
+	// Sources maps each filled field to the provider that supplied it
+	Sources map[string]string
+}
+
+// RichSeriesData combines data from multiple metadata endpoints for TV series
+type RichSeriesData struct {
+	Title         string
+	Year          int
+	EndYear       *int
+	Runtime       int // minutes per episode
+	Genres        []string
+	Plot          string
+	Tagline       string
+	Rating        float64
+	VoteCount     int
+	Metacritic    int
+	ContentRating string
+	PosterURL     string
+	BackgroundURL string
+	TotalSeasons  int
+	Status        string // Continuing or Ended
+	Network       string
+	Seasons       []MetadataSeason
+	Directors     []string
+	Writers       []string
+	Cast          []models.Cast
+
+	// Sources maps each filled field to the provider that supplied it
+	Sources map[string]string
+}
+
+// MetadataService queries metadata providers in a configurable fallback order.
+// The first provider that answers supplies each field; later providers only
+// fill what earlier ones left empty.
+type MetadataService struct {
+	mu        sync.RWMutex
+	all       []MetadataProvider
+	providers []MetadataProvider
+	cache     *ResponseCache
+}
+
+func NewMetadataService(providers ...MetadataProvider) *MetadataService {
+	return &MetadataService{
+		all:       providers,
+		providers: providers,
+	}
+}
+
+// SetOrder sets the fallback order by provider name. Providers that are not
+// listed are disabled; unknown names are ignored.
+func (s *MetadataService) SetOrder(names []string) {
+	var ordered []MetadataProvider
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		for _, p := range s.all {
+			if p.Name() == name {
+				ordered = append(ordered, p)
+				seen[name] = true
+				break
+			}
+		}
+	}
+	if len(ordered) == 0 {
+		ordered = s.all
+	}
+
+	s.mu.Lock()
+	s.providers = ordered
+	s.mu.Unlock()
+}
+
+// SetCache attaches a persistent response cache to every provider that supports one
+func (s *MetadataService) SetCache(cache *ResponseCache) {
+	s.cache = cache
+	for _, p := range s.all {
+		if c, ok := p.(interface{ SetCache(*ResponseCache) }); ok {
+			c.SetCache(cache)
+		}
+	}
+}
+
+// Cache returns the attached response cache, or nil
+func (s *MetadataService) Cache() *ResponseCache {
+	return s.cache
+}
+
+// Order returns the names of the active providers in fallback order
+func (s *MetadataService) Order() []string {
+	var names []string
+	for _, p := range s.active() {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// Available returns the names of all registered providers
+func (s *MetadataService) Available() []string {
+	var names []string
+	for _, p := range s.all {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// active returns the enabled providers, skipping ones that report no credentials
+func (s *MetadataService) active() []MetadataProvider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []MetadataProvider
+	for _, p := range s.providers {
+		if c, ok := p.(interface{ IsConfigured() bool }); ok && !c.IsConfigured() {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// fetchTitle returns the first title any provider can supply, plus the
+// providers that were not consulted yet (used to backfill empty fields).
+func (s *MetadataService) fetchTitle(imdbID string) (*MetadataTitle, string, []MetadataProvider, error) {
+	providers := s.active()
+	if len(providers) == 0 {
+		return nil, "", nil, fmt.Errorf("no metadata providers configured")
+	}
+
+	var errs []string
+	for i, p := range providers {
+		title, err := p.Title(imdbID)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		return title, p.Name(), providers[i+1:], nil
+	}
+	return nil, "", nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// backfillTitle fills empty fields of title from the remaining providers.
+// Only asks the next provider when something worth having is missing.
+func backfillTitle(imdbID string, title *MetadataTitle, sources map[string]string, rest []MetadataProvider) {
+	for _, p := range rest {
+		if title.Plot != "" && title.PosterURL != "" && title.Runtime > 0 && len(title.Genres) > 0 && title.Rating > 0 {
+			return
+		}
+		other, err := p.Title(imdbID)
+		if err != nil {
+			continue
+		}
+		name := p.Name()
+		if title.Plot == "" && other.Plot != "" {
+			title.Plot = other.Plot
+			sources["plot"] = name
+		}
+		if title.Tagline == "" && other.Tagline != "" {
+			title.Tagline = other.Tagline
+			sources["tagline"] = name
+		}
+		if title.PosterURL == "" && other.PosterURL != "" {
+			title.PosterURL = other.PosterURL
+			sources["poster"] = name
+		}
+		if title.BackgroundURL == "" && other.BackgroundURL != "" {
+			title.BackgroundURL = other.BackgroundURL
+			sources["background"] = name
+		}
+		if title.Runtime == 0 && other.Runtime > 0 {
+			title.Runtime = other.Runtime
+			sources["runtime"] = name
+		}
+		if len(title.Genres) == 0 && len(other.Genres) > 0 {
+			title.Genres = other.Genres
+			sources["genres"] = name
+		}
+		if title.Rating == 0 && other.Rating > 0 {
+			title.Rating = other.Rating
+			title.VoteCount = other.VoteCount
+			sources["rating"] = name
+		}
+		if title.ContentRating == "" && other.ContentRating != "" {
+			title.ContentRating = other.ContentRating
+			sources["content_rating"] = name
+		}
+		if title.Year == 0 && other.Year > 0 {
+			title.Year = other.Year
+			sources["year"] = name
+		}
+	}
+}
+
+// titleSources records the provider for every non-empty field of title
+func titleSources(title *MetadataTitle, provider string) map[string]string {
+	sources := map[string]string{"title": provider}
+	if title.Year > 0 {
+		sources["year"] = provider
+	}
+	if title.Plot != "" {
+		sources["plot"] = provider
+	}
+	if title.Tagline != "" {
+		sources["tagline"] = provider
+	}
+	if title.PosterURL != "" {
+		sources["poster"] = provider
+	}
+	if title.BackgroundURL != "" {
+		sources["background"] = provider
+	}
+	if title.Runtime > 0 {
+		sources["runtime"] = provider
+	}
+	if len(title.Genres) > 0 {
+		sources["genres"] = provider
+	}
+	if title.Rating > 0 {
+		sources["rating"] = provider
+	}
+	if title.Metacritic > 0 {
+		sources["metacritic"] = provider
+	}
+	if title.ContentRating != "" {
+		sources["content_rating"] = provider
+	}
+	return sources
+}
+
+// FetchRichData fetches all available data for a movie
+func (s *MetadataService) FetchRichData(imdbID string) (*RichMovieData, error) {
+	// Fetch basic title info (required)
+	title, provider, rest, err := s.fetchTitle(imdbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch title: %w", err)
+	}
+
+	// Accept movies, TV movies, shorts, and TV specials (standup specials etc.)
+	// tvSpecials are standalone single-episode content, treated as movies for torrent search
+	validTypes := map[string]bool{"movie": true, "tvMovie": true, "short": true, "video": true, "tvSpecial": true}
+	if !validTypes[title.Type] {
+		return nil, fmt.Errorf("not a movie: %s is a %s", imdbID, title.Type)
+	}
+
+	sources := titleSources(title, provider)
+	backfillTitle(imdbID, title, sources, rest)
+
+	data := &RichMovieData{
+		IMDBType:      title.Type,
+		Title:         title.Title,
+		OriginalTitle: title.OriginalTitle,
+		Year:          title.Year,
+		Runtime:       title.Runtime,
+		Genres:        title.Genres,
+		Plot:          title.Plot,
+		Tagline:       title.Tagline,
+		Rating:        title.Rating,
+		VoteCount:     title.VoteCount,
+		Metacritic:    title.Metacritic,
+		ContentRating: title.ContentRating,
+		PosterURL:     title.PosterURL,
+		BackgroundURL: title.BackgroundURL,
+		Sources:       sources,
+	}
+
+	providers := s.active()
+
+	// Credits (optional - first provider with people wins)
+	for _, p := range providers {
+		credits, err := p.Credits(imdbID)
+		if err != nil || (len(credits.Cast) == 0 && len(credits.Directors) == 0) {
+			continue
+		}
+		data.Directors = credits.Directors
+		data.Writers = credits.Writers
+		data.Cast = credits.Cast
+		sources["credits"] = p.Name()
+		break
+	}
+
+	// Images (optional)
+	for _, p := range providers {
+		images, err := p.Images(imdbID)
+		if err != nil || len(images) == 0 {
+			continue
+		}
+		data.AllImages = []string{}
+		for _, img := range images {
+			data.AllImages = append(data.AllImages, img.URL)
+
+			// Try to find a good poster (portrait)
+			if data.PosterURL == "" && img.Height > img.Width {
+				data.PosterURL = img.URL
+				sources["poster"] = p.Name()
+			}
+
+			// Try to find a good background (landscape)
+			if data.BackgroundURL == "" && img.Width > img.Height {
+				data.BackgroundURL = img.URL
+				sources["background"] = p.Name()
+			}
+
+			// Limit stored images
+			if len(data.AllImages) >= 20 {
+				break
+			}
+		}
+		sources["images"] = p.Name()
+		break
+	}
+
+	// Trailer (optional) - a YouTube key from any provider beats a provider-specific video ID
+	var fallbackTrailer, fallbackSource string
+	for _, p := range providers {
+		videos, err := p.Videos(imdbID)
+		if err != nil {
+			continue
+		}
+		id, youtube := pickTrailer(videos)
+		if id == "" {
+			continue
+		}
+		if youtube {
+			data.YouTubeTrailerID = id
+			sources["trailer"] = p.Name()
+			break
+		}
+		if fallbackTrailer == "" {
+			fallbackTrailer, fallbackSource = id, p.Name()
+		}
+	}
+	if data.YouTubeTrailerID == "" && fallbackTrailer != "" {
+		data.YouTubeTrailerID = fallbackTrailer
+		sources["trailer"] = fallbackSource
+	}
+
+	// Box office (optional)
+	for _, p := range providers {
+		bp, ok := p.(BoxOfficeProvider)
+		if !ok {
+			continue
+		}
+		budget, gross, err := bp.BoxOffice(imdbID)
+		if err != nil || (budget == "" && gross == "") {
+			continue
+		}
+		data.Budget = budget
+		data.BoxOfficeGross = gross
+		sources["box_office"] = p.Name()
+		break
+	}
+
+	return data, nil
+}
+
+// pickTrailer returns the best trailer ID from videos and whether it is a YouTube key
+func pickTrailer(videos []MetadataVideo) (string, bool) {
+	var fallback string
+	for _, v := range videos {
+		isTrailer := v.Type == "Trailer" || strings.Contains(strings.ToLower(v.Name), "trailer")
+		if !isTrailer {
+			continue
+		}
+		if strings.EqualFold(v.Site, "YouTube") {
+			return v.ID, true
+		}
+		if fallback == "" {
+			fallback = v.ID
+		}
+	}
+	return fallback, false
+}
+
+// FetchRichSeriesData fetches all available data for a TV series
+func (s *MetadataService) FetchRichSeriesData(imdbID string) (*RichSeriesData, error) {
+	// Fetch basic title info (required)
+	title, provider, rest, err := s.fetchTitle(imdbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch title: %w", err)
+	}
+
+	// Accept TV series types only
+	validTypes := map[string]bool{"tvSeries": true, "tvMiniSeries": true}
+	if !validTypes[title.Type] {
+		return nil, fmt.Errorf("not a TV series: %s is a %s", imdbID, title.Type)
+	}
+
+	sources := titleSources(title, provider)
+	backfillTitle(imdbID, title, sources, rest)
+
+	data := &RichSeriesData{
+		Title:         title.Title,
+		Year:          title.Year,
+		EndYear:       title.EndYear,
+		Runtime:       title.Runtime,
+		Genres:        title.Genres,
+		Plot:          title.Plot,
+		Tagline:       title.Tagline,
+		Rating:        title.Rating,
+		VoteCount:     title.VoteCount,
+		Metacritic:    title.Metacritic,
+		ContentRating: title.ContentRating,
+		PosterURL:     title.PosterURL,
+		BackgroundURL: title.BackgroundURL,
+		Sources:       sources,
+	}
+
+	// Determine status
+	if title.EndYear != nil {
+		data.Status = "Ended"
+	} else {
+		data.Status = "Continuing"
+	}
+
+	providers := s.active()
+
+	// Credits (optional - first provider with people wins)
+	for _, p := range providers {
+		credits, err := p.Credits(imdbID)
+		if err != nil || (len(credits.Cast) == 0 && len(credits.Directors) == 0) {
+			continue
+		}
+		data.Directors = credits.Directors
+		data.Writers = credits.Writers
+		data.Cast = credits.Cast
+		sources["credits"] = p.Name()
+		break
+	}
+
+	// Images for background (optional)
+	if data.BackgroundURL == "" {
+		for _, p := range providers {
+			images, err := p.Images(imdbID)
+			if err != nil {
+				continue
+			}
+			for _, img := range images {
+				// Find a landscape image for background
+				if img.Width > img.Height {
+					data.BackgroundURL = img.URL
+					sources["background"] = p.Name()
+					break
+				}
+			}
+			if data.BackgroundURL != "" {
+				break
+			}
+		}
+	}
+
+	// Episodes (optional but important) - first provider with any episodes wins
+	for _, p := range providers {
+		episodes, err := p.Episodes(imdbID)
+		if err != nil {
+			log.Printf("Failed to fetch episodes for %s from %s: %v", imdbID, p.Name(), err)
+			continue
+		}
+		if len(episodes) == 0 {
+			continue
+		}
+
+		// Group episodes by season
+		seasonMap := make(map[int][]MetadataEpisode)
+		for _, ep := range episodes {
+			seasonMap[ep.Season] = append(seasonMap[ep.Season], ep)
+		}
+
+		// Convert to Seasons array
+		for seasonNum, eps := range seasonMap {
+			data.Seasons = append(data.Seasons, MetadataSeason{
+				Season:   seasonNum,
+				Episodes: eps,
+			})
+		}
+		data.TotalSeasons = len(seasonMap)
+		sources["episodes"] = p.Name()
+		break
+	}
+
+	return data, nil
+}
+
+// ToMovie converts rich data to a Movie model. Ratings are left out: the
+// provider that supplied them may not be IMDb, so applyMovieRatings sets
+// them under the ratings precedence.
+func (data *RichMovieData) ToMovie(imdbCode string) *models.Movie {
+	movie := &models.Movie{
+		ImdbCode:        imdbCode,
+		Title:           data.Title,
+		TitleEnglish:    data.Title,
+		TitleLong:       fmt.Sprintf("%s (%d)", data.Title, data.Year),
+		Slug:            strings.ToLower(strings.ReplaceAll(data.Title, " ", "-")),
+		Year:            uint(data.Year),
+		Runtime:         uint(data.Runtime),
+		Genres:          data.Genres,
+		Summary:         data.Plot,
+		DescriptionFull: data.Plot,
+		Synopsis:        data.Plot,
+		Tagline:         data.Tagline,
+		Language:        "en",
+		MpaRating:       data.ContentRating,
+		Cast:            data.Cast,
+	}
+
+	// Set images
+	if data.PosterURL != "" {
+		movie.SmallCoverImage = data.PosterURL
+		movie.MediumCoverImage = data.PosterURL
+		movie.LargeCoverImage = data.PosterURL
+	}
+	if data.BackgroundURL != "" {
+		movie.BackgroundImage = data.BackgroundURL
+	}
+
+	// Set trailer
+	if data.YouTubeTrailerID != "" {
+		movie.YtTrailerCode = data.YouTubeTrailerID
+	}
+
+	return movie
+}
+
+// SupportsTranslations reports whether any active provider can fetch
+// translated metadata
+func (s *MetadataService) SupportsTranslations() bool {
+	for _, p := range s.active() {
+		if _, ok := p.(LocalizedProvider); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchLocalized returns translated metadata for locale from the first
+// provider that supports translations, plus that provider's name.
+func (s *MetadataService) FetchLocalized(imdbID, locale string) (*MetadataLocalized, string, error) {
+	var errs []string
+	for _, p := range s.active() {
+		lp, ok := p.(LocalizedProvider)
+		if !ok {
+			continue
+		}
+		loc, err := lp.Localized(imdbID, locale)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		return loc, p.Name(), nil
+	}
+	if len(errs) == 0 {
+		return nil, "", fmt.Errorf("no metadata provider supports translations")
+	}
+	return nil, "", fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// FetchLocalizedEpisodes is FetchLocalized for every episode of a series
+func (s *MetadataService) FetchLocalizedEpisodes(imdbID, locale string) ([]MetadataEpisode, string, error) {
+	var errs []string
+	for _, p := range s.active() {
+		lp, ok := p.(LocalizedProvider)
+		if !ok {
+			continue
+		}
+		episodes, err := lp.LocalizedEpisodes(imdbID, locale)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		return episodes, p.Name(), nil
+	}
+	if len(errs) == 0 {
+		return nil, "", fmt.Errorf("no metadata provider supports translations")
+	}
+	return nil, "", fmt.Errorf("%s", strings.Join(errs, "; "))
+}
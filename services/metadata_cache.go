@@ -0,0 +1,265 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-server/database"
+	"torrent-server/models"
+)
+
+// Default freshness per endpoint. Titles and episode lists change the most
+// (ratings, new episodes); credits, images and box office rarely move.
+var defaultCacheTTLs = map[string]time.Duration{
+	"title":      24 * time.Hour,
+	"episodes":   12 * time.Hour,
+	"season":     12 * time.Hour,
+	"credits":    7 * 24 * time.Hour,
+	"images":     7 * 24 * time.Hour,
+	"videos":     7 * 24 * time.Hour,
+	"box_office": 7 * 24 * time.Hour,
+	"find":       30 * 24 * time.Hour,
+}
+
+const (
+	defaultCacheTTL      = 24 * time.Hour
+	defaultCacheStaleFor = 7 * 24 * time.Hour
+)
+
+// CacheKey identifies one upstream response
+type CacheKey struct {
+	Provider string
+	Endpoint string
+	ImdbCode string
+	Variant  string // page token, season number, ... when one endpoint has several responses
+}
+
+func (k CacheKey) String() string {
+	key := k.Provider + "|" + k.Endpoint + "|" + k.ImdbCode
+	if k.Variant != "" {
+		key += "|" + k.Variant
+	}
+	return key
+}
+
+// ResponseCache stores upstream metadata response bodies in the database.
+//
+// Within an endpoint's TTL, cached bodies are served without touching upstream.
+// For staleFor after that, the stale body is served immediately and refreshed
+// in the background (stale-while-revalidate). Past that window the fetch is
+// synchronous, but if upstream fails any cached body is still returned.
+type ResponseCache struct {
+	db *database.DB
+
+	mu         sync.Mutex
+	ttls       map[string]time.Duration
+	staleFor   time.Duration
+	counters   map[string]*cacheCounters // by provider/endpoint
+	refreshing map[string]bool
+}
+
+type cacheCounters struct {
+	Hits         int64 `json:"hits"`
+	StaleHits    int64 `json:"stale_hits"`
+	Misses       int64 `json:"misses"`
+	Errors       int64 `json:"errors"`
+	StaleOnError int64 `json:"stale_on_error"`
+}
+
+// CacheStats is the admin view of cache effectiveness
+type CacheStats struct {
+	cacheCounters
+	HitRate   float64                   `json:"hit_rate"`
+	Upstream  int64                     `json:"upstream_calls"`
+	Entries   map[string]int            `json:"entries"`
+	Endpoints map[string]*cacheCounters `json:"endpoints"`
+	TTLs      map[string]string         `json:"ttls"`
+	StaleFor  string                    `json:"stale_for"`
+}
+
+func NewResponseCache(db *database.DB) *ResponseCache {
+	ttls := make(map[string]time.Duration, len(defaultCacheTTLs))
+	for k, v := range defaultCacheTTLs {
+		ttls[k] = v
+	}
+	return &ResponseCache{
+		db:         db,
+		ttls:       ttls,
+		staleFor:   defaultCacheStaleFor,
+		counters:   make(map[string]*cacheCounters),
+		refreshing: make(map[string]bool),
+	}
+}
+
+// SetTTLs overrides TTLs from a spec like "title=12h,episodes=6h,stale=72h".
+// The special "stale" key sets the stale-while-revalidate window.
+func (c *ResponseCache) SetTTLs(spec string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid cache TTL %q, expected endpoint=duration", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid cache TTL for %s: %w", name, err)
+		}
+		name = strings.TrimSpace(name)
+		if name == "stale" {
+			c.staleFor = d
+		} else {
+			c.ttls[name] = d
+		}
+	}
+	return nil
+}
+
+func (c *ResponseCache) ttl(endpoint string) time.Duration {
+	if d, ok := c.ttls[endpoint]; ok {
+		return d
+	}
+	return defaultCacheTTL
+}
+
+func (c *ResponseCache) count(key CacheKey, f func(*cacheCounters)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := key.Provider + "/" + key.Endpoint
+	ctr, ok := c.counters[name]
+	if !ok {
+		ctr = &cacheCounters{}
+		c.counters[name] = ctr
+	}
+	f(ctr)
+}
+
+// Fetch returns the body for key, calling fetch only when the cache can't answer.
+// A nil cache always calls fetch.
+func (c *ResponseCache) Fetch(key CacheKey, fetch func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return fetch()
+	}
+
+	k := key.String()
+	entry, _ := c.db.GetMetadataCacheEntry(k)
+	if entry != nil {
+		c.mu.Lock()
+		ttl, staleFor := c.ttl(key.Endpoint), c.staleFor
+		c.mu.Unlock()
+
+		age := time.Since(entry.FetchedAt)
+		if age < ttl {
+			c.count(key, func(ctr *cacheCounters) { ctr.Hits++ })
+			return []byte(entry.Body), nil
+		}
+		if age < ttl+staleFor {
+			c.count(key, func(ctr *cacheCounters) { ctr.StaleHits++ })
+			c.revalidate(key, fetch)
+			return []byte(entry.Body), nil
+		}
+	}
+
+	c.count(key, func(ctr *cacheCounters) { ctr.Misses++ })
+	body, err := fetch()
+	if err != nil {
+		c.count(key, func(ctr *cacheCounters) { ctr.Errors++ })
+		if entry != nil {
+			c.count(key, func(ctr *cacheCounters) { ctr.StaleOnError++ })
+			log.Printf("[MetadataCache] %s upstream failed, serving cached copy from %s: %v", k, entry.FetchedAt.Format(time.RFC3339), err)
+			return []byte(entry.Body), nil
+		}
+		return nil, err
+	}
+	c.store(key, body)
+	return body, nil
+}
+
+// revalidate refreshes key in the background, at most once at a time per key
+func (c *ResponseCache) revalidate(key CacheKey, fetch func() ([]byte, error)) {
+	k := key.String()
+	c.mu.Lock()
+	if c.refreshing[k] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[k] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, k)
+			c.mu.Unlock()
+		}()
+
+		body, err := fetch()
+		if err != nil {
+			c.count(key, func(ctr *cacheCounters) { ctr.Errors++ })
+			return
+		}
+		c.store(key, body)
+	}()
+}
+
+func (c *ResponseCache) store(key CacheKey, body []byte) {
+	entry := &models.MetadataCacheEntry{
+		Key:       key.String(),
+		Provider:  key.Provider,
+		Endpoint:  key.Endpoint,
+		ImdbCode:  key.ImdbCode,
+		Body:      string(body),
+		FetchedAt: time.Now(),
+	}
+	if err := c.db.SaveMetadataCacheEntry(entry); err != nil {
+		log.Printf("[MetadataCache] Failed to store %s: %v", entry.Key, err)
+	}
+}
+
+// Stats returns hit/miss counters since startup and the current entry counts
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	stats := CacheStats{
+		Endpoints: make(map[string]*cacheCounters, len(c.counters)),
+		TTLs:      make(map[string]string, len(c.ttls)),
+		StaleFor:  c.staleFor.String(),
+	}
+	for name, ctr := range c.counters {
+		cp := *ctr
+		stats.Endpoints[name] = &cp
+		stats.Hits += ctr.Hits
+		stats.StaleHits += ctr.StaleHits
+		stats.Misses += ctr.Misses
+		stats.Errors += ctr.Errors
+		stats.StaleOnError += ctr.StaleOnError
+	}
+	for name, d := range c.ttls {
+		stats.TTLs[name] = d.String()
+	}
+	c.mu.Unlock()
+
+	stats.Upstream = stats.Misses + stats.StaleHits
+	if total := stats.Hits + stats.StaleHits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.StaleHits) / float64(total)
+	}
+
+	entries, err := c.db.CountMetadataCacheEntries()
+	if err != nil {
+		entries = map[string]int{}
+	}
+	stats.Entries = entries
+	return stats
+}
+
+// Purge deletes cached responses; empty arguments match everything
+func (c *ResponseCache) Purge(provider, endpoint, imdbCode string) (int64, error) {
+	return c.db.PurgeMetadataCache(provider, endpoint, imdbCode)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// movieHashChunk is how much of each end of the file the OpenSubtitles
+// hash reads
+const movieHashChunk = 64 * 1024
+
+// movieHashTimeout bounds how long computing a hash waits for torrent pieces
+const movieHashTimeout = 60 * time.Second
+
+// MovieHash identifies a video file for exact subtitle matching
+type MovieHash struct {
+	Hash string `json:"moviehash"`
+	Size int64  `json:"moviebytesize"`
+}
+
+// contextReader is implemented by torrent readers, whose reads block until
+// the pieces are downloaded
+type contextReader interface {
+	ReadContext(ctx context.Context, b []byte) (int, error)
+}
+
+// ComputeMovieHash computes the OpenSubtitles hash of a file: its size plus
+// the little-endian uint64 sums of its first and last 64KB.
+func ComputeMovieHash(ctx context.Context, r io.ReadSeeker, size int64) (string, error) {
+	if size < movieHashChunk*2 {
+		return "", fmt.Errorf("file too small to hash (%d bytes)", size)
+	}
+
+	hash := uint64(size)
+	buf := make([]byte, movieHashChunk)
+	for _, offset := range []int64{0, size - movieHashChunk} {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return "", err
+		}
+		if err := readFullContext(ctx, r, buf); err != nil {
+			return "", fmt.Errorf("failed to read file at %d: %w", offset, err)
+		}
+		for i := 0; i < movieHashChunk; i += 8 {
+			hash += binary.LittleEndian.Uint64(buf[i:])
+		}
+	}
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+func readFullContext(ctx context.Context, r io.Reader, buf []byte) error {
+	cr, ok := r.(contextReader)
+	if !ok {
+		_, err := io.ReadFull(r, buf)
+		return err
+	}
+	for read := 0; read < len(buf); {
+		n, err := cr.ReadContext(ctx, buf[read:])
+		read += n
+		if err != nil && (read < len(buf) || err != io.EOF) {
+			return err
+		}
+	}
+	return nil
+}
+
+// movieHashCache remembers computed hashes by torrent and file index; a
+// torrent's file contents never change
+type movieHashCache struct {
+	mu     sync.Mutex
+	hashes map[string]MovieHash
+}
+
+// TorrentMovieHash computes the OpenSubtitles hash of a torrent file through
+// the torrent client, downloading only the two chunks it needs. A negative
+// fileIndex selects the largest video file.
+func (s *SubtitleService) TorrentMovieHash(ts *TorrentService, infoHash string, fileIndex int) (*MovieHash, error) {
+	t, ok := ts.GetTorrent(infoHash)
+	if !ok {
+		return nil, fmt.Errorf("torrent not found")
+	}
+	if fileIndex < 0 {
+		fileIndex, _ = ts.FindLargestVideoFile(t)
+	}
+
+	key := fmt.Sprintf("%s/%d", infoHash, fileIndex)
+	s.movieHashes.mu.Lock()
+	cached, ok := s.movieHashes.hashes[key]
+	s.movieHashes.mu.Unlock()
+	if ok {
+		return &cached, nil
+	}
+
+	// GetFileReader would queue the whole file; only the two chunks are needed
+	reader, size, err := ts.GetRangeReader(t, fileIndex, movieHashChunk)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), movieHashTimeout)
+	defer cancel()
+	hash, err := ComputeMovieHash(ctx, reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	mh := MovieHash{Hash: hash, Size: size}
+	s.movieHashes.mu.Lock()
+	if s.movieHashes.hashes == nil {
+		s.movieHashes.hashes = make(map[string]MovieHash)
+	}
+	s.movieHashes.hashes[key] = mh
+	s.movieHashes.mu.Unlock()
+	return &mh, nil
+}
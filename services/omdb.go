@@ -3,10 +3,12 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"torrent-server/models"
 )
@@ -14,6 +16,8 @@ import (
 type OMDBService struct {
 	apiKey  string
 	baseURL string
+	client  *http.Client
+	cache   *ResponseCache
 }
 
 type OMDBResponse struct {
@@ -51,13 +55,82 @@ func NewOMDBService(apiKey string) *OMDBService {
 	return &OMDBService{
 		apiKey:  apiKey,
 		baseURL: "https://www.omdbapi.com/",
+		client:  &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
+func (s *OMDBService) Name() string {
+	return "omdb"
+}
+
 func (s *OMDBService) IsConfigured() bool {
 	return s.apiKey != ""
 }
 
+// SetCache routes ratings lookups through the persistent response cache
+func (s *OMDBService) SetCache(cache *ResponseCache) {
+	s.cache = cache
+}
+
+// FetchRatings gets IMDb, Rotten Tomatoes and Metacritic ratings for any
+// title type (movie or series). Missing ratings are left nil.
+func (s *OMDBService) FetchRatings(imdbCode string) (*RatingValues, error) {
+	if !s.IsConfigured() {
+		return nil, fmt.Errorf("OMDB API key not configured")
+	}
+
+	params := url.Values{}
+	params.Set("apikey", s.apiKey)
+	params.Set("i", imdbCode)
+
+	key := CacheKey{Provider: "omdb", Endpoint: "ratings", ImdbCode: imdbCode}
+	body, err := s.cache.Fetch(key, func() ([]byte, error) {
+		resp, err := s.client.Get(s.baseURL + "?" + params.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch from OMDB: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OMDB API returned %d", resp.StatusCode)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		// Don't cache "not found" or quota errors
+		var check OMDBResponse
+		if err := json.Unmarshal(body, &check); err != nil {
+			return nil, fmt.Errorf("failed to decode OMDB response: %w", err)
+		}
+		if check.Response == "False" {
+			return nil, fmt.Errorf("OMDB error: %s", check.Error)
+		}
+		return body, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var omdb OMDBResponse
+	if err := json.Unmarshal(body, &omdb); err != nil {
+		return nil, fmt.Errorf("failed to decode OMDB response: %w", err)
+	}
+
+	// convertToMovie already knows how to parse every rating format
+	m := s.convertToMovie(omdb)
+	if m.ImdbVotes == "N/A" {
+		m.ImdbVotes = ""
+	}
+	return &RatingValues{
+		ImdbRating:     m.ImdbRating,
+		ImdbVotes:      m.ImdbVotes,
+		RottenTomatoes: m.RottenTomatoes,
+		Metacritic:     m.Metacritic,
+	}, nil
+}
+
 // GetContentType returns the type of content (movie, series, episode)
 func (s *OMDBService) GetContentType(imdbCode string) (string, error) {
 	if !s.IsConfigured() {
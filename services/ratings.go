@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rating fields that can be sourced independently
+const (
+	RatingFieldIMDb           = "imdb_rating"
+	RatingFieldRottenTomatoes = "rotten_tomatoes"
+	RatingFieldMetacritic     = "metacritic"
+)
+
+// RatingValues is one source's view of a title's ratings. Nil means the
+// source has no value for that field.
+type RatingValues struct {
+	ImdbRating     *float32
+	ImdbVotes      string
+	RottenTomatoes *int
+	Metacritic     *int
+}
+
+// RatingsPrecedence maps each rating field to the sources to take it from,
+// in order. Sources not listed for a field are never used for it.
+type RatingsPrecedence map[string][]string
+
+// DefaultRatingsPrecedence prefers the metadata providers' own numbers and
+// falls back to OMDB. Rotten Tomatoes is only available from OMDB. TMDB's
+// vote average is not an IMDb rating, so it is only used when configured.
+func DefaultRatingsPrecedence() RatingsPrecedence {
+	return RatingsPrecedence{
+		RatingFieldIMDb:           {"imdb", "omdb"},
+		RatingFieldRottenTomatoes: {"omdb"},
+		RatingFieldMetacritic:     {"imdb", "omdb"},
+	}
+}
+
+// ParseRatingsPrecedence overrides the defaults from a spec like
+// "imdb_rating=omdb,imdb;metacritic=omdb". Fields not in the spec keep
+// their default order.
+func ParseRatingsPrecedence(spec string) (RatingsPrecedence, error) {
+	p := DefaultRatingsPrecedence()
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ratings precedence %q, expected field=source,source", part)
+		}
+		field = strings.TrimSpace(field)
+		if _, known := p[field]; !known {
+			return nil, fmt.Errorf("unknown rating field %q", field)
+		}
+		var order []string
+		for _, src := range strings.Split(value, ",") {
+			if src = strings.ToLower(strings.TrimSpace(src)); src != "" {
+				order = append(order, src)
+			}
+		}
+		p[field] = order
+	}
+	return p, nil
+}
+
+// String formats the precedence in the same form ParseRatingsPrecedence reads
+func (p RatingsPrecedence) String() string {
+	var parts []string
+	for _, field := range []string{RatingFieldIMDb, RatingFieldRottenTomatoes, RatingFieldMetacritic} {
+		parts = append(parts, field+"="+strings.Join(p[field], ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Merge picks each field from the first source in precedence order that has
+// it. It returns the merged values and which source supplied each field.
+func (p RatingsPrecedence) Merge(candidates map[string]*RatingValues) (*RatingValues, map[string]string) {
+	out := &RatingValues{}
+	sources := make(map[string]string)
+
+	for _, src := range p[RatingFieldIMDb] {
+		if c := candidates[src]; c != nil && c.ImdbRating != nil && *c.ImdbRating > 0 {
+			out.ImdbRating = c.ImdbRating
+			out.ImdbVotes = c.ImdbVotes
+			sources[RatingFieldIMDb] = src
+			break
+		}
+	}
+	for _, src := range p[RatingFieldRottenTomatoes] {
+		if c := candidates[src]; c != nil && c.RottenTomatoes != nil {
+			out.RottenTomatoes = c.RottenTomatoes
+			sources[RatingFieldRottenTomatoes] = src
+			break
+		}
+	}
+	for _, src := range p[RatingFieldMetacritic] {
+		if c := candidates[src]; c != nil && c.Metacritic != nil && *c.Metacritic > 0 {
+			out.Metacritic = c.Metacritic
+			sources[RatingFieldMetacritic] = src
+			break
+		}
+	}
+	return out, sources
+}
+
+// ratingsStale reports whether ratings stamped at updatedAt (RFC3339) are
+// older than maxAge. Missing or unparseable stamps count as stale.
+func ratingsStale(updatedAt string, maxAge time.Duration) bool {
+	if updatedAt == "" {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return true
+	}
+	return time.Since(t) >= maxAge
+}
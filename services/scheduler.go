@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"torrent-server/database"
+	"torrent-server/models"
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobRunning     = errors.New("job is already running")
+	ErrJobRunNotFound = errors.New("job run not found")
+	ErrJobNotRunning  = errors.New("job run is not running")
+)
+
+// JobFunc does the work of a job. It should return promptly once ctx is
+// cancelled and report counts through p.
+type JobFunc func(ctx context.Context, p *JobProgress) error
+
+// Job describes a named unit of background work
+type Job struct {
+	Name        string
+	Description string
+	// Cron expression or shorthand, see ParseSchedule. Empty means manual only.
+	Schedule string
+	// Paused jobs only run when triggered by hand
+	Paused bool
+	Run    JobFunc
+}
+
+// JobCounts is a snapshot of a run's progress
+type JobCounts struct {
+	Total     int    `json:"total"`
+	Processed int    `json:"processed"`
+	Added     int    `json:"added"`
+	Failed    int    `json:"failed"`
+	Current   string `json:"current,omitempty"`
+	// Job-specific counts, such as how many imports were coming soon
+	Extra map[string]int `json:"extra,omitempty"`
+}
+
+// JobProgress is how a running job reports what it has done. A nil
+// *JobProgress discards updates, so job bodies can also be called directly.
+type JobProgress struct {
+	mu     sync.Mutex
+	counts JobCounts
+}
+
+func (p *JobProgress) SetTotal(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.counts.Total = n
+	p.mu.Unlock()
+}
+
+// SetCurrent records the item being worked on
+func (p *JobProgress) SetCurrent(item string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.counts.Current = item
+	p.mu.Unlock()
+}
+
+// Add increments the processed, added and failed counters
+func (p *JobProgress) Add(processed, added, failed int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.counts.Processed += processed
+	p.counts.Added += added
+	p.counts.Failed += failed
+	p.mu.Unlock()
+}
+
+// AddExtra increments a job-specific counter
+func (p *JobProgress) AddExtra(name string, n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if p.counts.Extra == nil {
+		p.counts.Extra = make(map[string]int)
+	}
+	p.counts.Extra[name] += n
+	p.mu.Unlock()
+}
+
+func (p *JobProgress) Counts() JobCounts {
+	if p == nil {
+		return JobCounts{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := p.counts
+	if p.counts.Extra != nil {
+		counts.Extra = make(map[string]int, len(p.counts.Extra))
+		for k, v := range p.counts.Extra {
+			counts.Extra[k] = v
+		}
+	}
+	return counts
+}
+
+type scheduledJob struct {
+	Job
+	schedule *Schedule
+	next     time.Time
+	run      *models.JobRun
+	progress *JobProgress
+	cancel   context.CancelFunc
+}
+
+// JobInfo is the admin view of a registered job
+type JobInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Schedule    string         `json:"schedule"`
+	Paused      bool           `json:"paused"`
+	Running     bool           `json:"running"`
+	NextRun     *time.Time     `json:"next_run,omitempty"`
+	Current     *models.JobRun `json:"current,omitempty"`
+	Progress    *JobCounts     `json:"progress,omitempty"`
+	LastRun     *models.JobRun `json:"last_run,omitempty"`
+}
+
+// Scheduler runs registered jobs on their schedules, never more than one
+// run of a job at a time, and records every run in job_runs.
+type Scheduler struct {
+	db     *database.DB
+	mu     sync.Mutex
+	jobs   map[string]*scheduledJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(db *database.DB) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:     db,
+		jobs:   make(map[string]*scheduledJob),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Register adds a job. Schedule and pause overrides saved by an admin take
+// precedence over the defaults in job.
+func (s *Scheduler) Register(job Job) error {
+	if settings, err := s.db.GetJobSettings(); err == nil {
+		if saved, ok := settings[job.Name]; ok {
+			job.Schedule = saved.Schedule
+			job.Paused = saved.Paused
+		}
+	}
+
+	sj := &scheduledJob{Job: job}
+	if job.Schedule != "" {
+		sched, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		sj.schedule = sched
+		sj.next = sched.Next(time.Now())
+	}
+
+	s.mu.Lock()
+	s.jobs[job.Name] = sj
+	s.mu.Unlock()
+	return nil
+}
+
+// Start begins running jobs on their schedules
+func (s *Scheduler) Start() {
+	if n, err := s.db.MarkInterruptedJobRuns(); err == nil && n > 0 {
+		log.Printf("[Scheduler] Marked %d unfinished runs as interrupted", n)
+	}
+
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runDue(now)
+			}
+		}
+	}()
+}
+
+// Stop cancels running jobs and waits briefly for them to finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+	}
+}
+
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.schedule == nil || j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+		j.next = j.schedule.Next(now)
+		if j.Paused {
+			continue
+		}
+		if j.run != nil {
+			log.Printf("[Scheduler] Skipping %s: previous run still in progress", j.Name)
+			continue
+		}
+		s.startLocked(j, "schedule")
+	}
+}
+
+// Trigger starts a job now, regardless of its schedule or pause state
+func (s *Scheduler) Trigger(name string) (*models.JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	if j.run != nil {
+		return nil, ErrJobRunning
+	}
+	return s.startLocked(j, "manual"), nil
+}
+
+// Cancel stops a running job by run ID. The job sees its context cancelled
+// and the run is recorded as cancelled once it returns.
+func (s *Scheduler) Cancel(runID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.run != nil && j.run.ID == runID {
+			j.cancel()
+			return nil
+		}
+	}
+	if _, err := s.db.GetJobRun(runID); err != nil {
+		return ErrJobRunNotFound
+	}
+	return ErrJobNotRunning
+}
+
+// CancelJob stops the current run of a job by name
+func (s *Scheduler) CancelJob(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.run == nil {
+		return ErrJobNotRunning
+	}
+	j.cancel()
+	return nil
+}
+
+// JobRunStatus is a run with its live progress while it is still going
+type JobRunStatus struct {
+	Run      models.JobRun `json:"run"`
+	Running  bool          `json:"running"`
+	Progress JobCounts     `json:"progress"`
+}
+
+// RunStatus returns a run by ID, with live counts if it is in progress
+func (s *Scheduler) RunStatus(runID uint) (*JobRunStatus, error) {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if j.run != nil && j.run.ID == runID {
+			status := &JobRunStatus{Run: *j.run, Running: true, Progress: j.progress.Counts()}
+			s.mu.Unlock()
+			return status, nil
+		}
+	}
+	s.mu.Unlock()
+
+	run, err := s.db.GetJobRun(runID)
+	if err != nil {
+		return nil, ErrJobRunNotFound
+	}
+	return &JobRunStatus{
+		Run: *run,
+		Progress: JobCounts{
+			Total:     run.Total,
+			Processed: run.Processed,
+			Added:     run.Added,
+			Failed:    run.Failed,
+			Extra:     run.Extra,
+		},
+	}, nil
+}
+
+func (s *Scheduler) startLocked(j *scheduledJob, trigger string) *models.JobRun {
+	run := &models.JobRun{
+		Job:       j.Name,
+		Trigger:   trigger,
+		Status:    models.JobStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := s.db.CreateJobRun(run); err != nil {
+		log.Printf("[Scheduler] Failed to record run of %s: %v", j.Name, err)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	j.run = run
+	j.progress = &JobProgress{}
+	j.cancel = cancel
+
+	snapshot := *run
+	s.wg.Add(1)
+	go s.execute(ctx, j, run, j.progress)
+	return &snapshot
+}
+
+func (s *Scheduler) execute(ctx context.Context, j *scheduledJob, run *models.JobRun, p *JobProgress) {
+	defer s.wg.Done()
+	log.Printf("[Scheduler] Starting %s (%s)", j.Name, run.Trigger)
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return j.Run(ctx, p)
+	}()
+
+	// Readers copy j.run under the lock, so finish it under the lock too
+	s.mu.Lock()
+	finished := time.Now()
+	counts := p.Counts()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Total = counts.Total
+	run.Processed = counts.Processed
+	run.Added = counts.Added
+	run.Failed = counts.Failed
+	run.Extra = counts.Extra
+	switch {
+	case err != nil && s.ctx.Err() != nil:
+		run.Status = models.JobStatusInterrupted
+		run.Error = err.Error()
+	case err != nil && ctx.Err() != nil:
+		run.Status = models.JobStatusCancelled
+	case err != nil:
+		run.Status = models.JobStatusFailed
+		run.Error = err.Error()
+	default:
+		run.Status = models.JobStatusSuccess
+	}
+	if err := s.db.SaveJobRun(run); err != nil {
+		log.Printf("[Scheduler] Failed to record result of %s: %v", j.Name, err)
+	}
+	j.run = nil
+	j.progress = nil
+	j.cancel()
+	j.cancel = nil
+	s.mu.Unlock()
+
+	s.db.PruneJobRuns(j.Name)
+	log.Printf("[Scheduler] Finished %s: %s in %s (%d processed, %d added, %d failed)",
+		j.Name, run.Status, time.Duration(run.DurationMs)*time.Millisecond, run.Processed, run.Added, run.Failed)
+}
+
+// SetPaused pauses or resumes a job's schedule and persists the choice
+func (s *Scheduler) SetPaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	j.Paused = paused
+	return s.saveLocked(j)
+}
+
+// SetSchedule changes a job's schedule and persists it. Empty means manual only.
+func (s *Scheduler) SetSchedule(name, expr string) error {
+	var sched *Schedule
+	if expr != "" {
+		var err error
+		if sched, err = ParseSchedule(expr); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	j.Schedule = expr
+	j.schedule = sched
+	j.next = time.Time{}
+	if sched != nil {
+		j.next = sched.Next(time.Now())
+	}
+	return s.saveLocked(j)
+}
+
+func (s *Scheduler) saveLocked(j *scheduledJob) error {
+	return s.db.SaveJobSetting(&models.JobSetting{
+		Name:      j.Name,
+		Schedule:  j.Schedule,
+		Paused:    j.Paused,
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (s *Scheduler) infoLocked(j *scheduledJob) JobInfo {
+	info := JobInfo{
+		Name:        j.Name,
+		Description: j.Description,
+		Schedule:    j.Schedule,
+		Paused:      j.Paused,
+		Running:     j.run != nil,
+	}
+	if !j.next.IsZero() && !j.Paused {
+		next := j.next
+		info.NextRun = &next
+	}
+	if j.run != nil {
+		current := *j.run
+		counts := j.progress.Counts()
+		info.Current = &current
+		info.Progress = &counts
+	}
+	return info
+}
+
+// Jobs lists registered jobs with their state and last run
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		infos = append(infos, s.infoLocked(j))
+	}
+	s.mu.Unlock()
+
+	for i := range infos {
+		infos[i].LastRun = s.db.GetLastJobRun(infos[i].Name)
+	}
+	sort.Slice(infos, func(i, k int) bool { return infos[i].Name < infos[k].Name })
+	return infos
+}
+
+// Job returns one job's state
+func (s *Scheduler) Job(name string) (JobInfo, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return JobInfo{}, ErrJobNotFound
+	}
+	info := s.infoLocked(j)
+	s.mu.Unlock()
+
+	info.LastRun = s.db.GetLastJobRun(name)
+	return info, nil
+}
+
+// History returns the most recent runs of a job
+func (s *Scheduler) History(name string, limit int) ([]models.JobRun, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return s.db.ListJobRuns(name, limit)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MinSecretLength is the shortest configured signing secret accepted
+const MinSecretLength = 16
+
+// LoadSecret returns the signing secret stored at path, creating a random
+// one the first time so signed URLs stay valid across restarts
+func LoadSecret(path string) (string, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		if secret := strings.TrimSpace(string(data)); len(secret) >= MinSecretLength {
+			return secret, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to store secret: %w", err)
+	}
+	return secret, nil
+}
@@ -4,17 +4,19 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-	"unicode/utf8"
 
 	"github.com/anacrolix/torrent"
 
@@ -22,222 +24,170 @@ import (
 	"torrent-server/models"
 )
 
-const (
-	subdlAPIURL          = "https://api.subdl.com/api/v1/subtitles"
-	opensubtitlesRestURL = "https://rest.opensubtitles.org/search"
-)
-
 type SubtitleService struct {
 	client       *http.Client
-	subdlKey     string
 	db           *database.DB
 	subtitlesDir string
+	providers    *subtitleProviders
+	movieHashes  movieHashCache
 }
 
 func NewSubtitleService() *SubtitleService {
-	apiKey := os.Getenv("SUBDL_API_KEY")
-	if apiKey == "" {
-		apiKey = "4bHkwDgMS95KS34bCXOo7y1LgkAKkK6P"
+	s := &SubtitleService{
+		client:    &http.Client{Timeout: 15 * time.Second},
+		providers: newSubtitleProviders(),
 	}
-	return &SubtitleService{
-		client:   &http.Client{Timeout: 15 * time.Second},
-		subdlKey: apiKey,
+	for _, p := range defaultSubtitleProviders() {
+		s.RegisterProvider(p.provider, p.settings)
 	}
+	return s
 }
 
 func NewSubtitleServiceWithDB(db *database.DB, subtitlesDir string) *SubtitleService {
 	s := NewSubtitleService()
 	s.db = db
 	s.subtitlesDir = subtitlesDir
+	s.loadProviderSettings()
 	return s
 }
 
 // SyncEpisodeSubtitles downloads and stores subtitles for a specific episode.
+// An empty languages list syncs every enabled language.
 func (s *SubtitleService) SyncEpisodeSubtitles(imdbCode string, languages string, season, episode int) (int, error) {
-	if s.db == nil {
-		return 0, fmt.Errorf("no database configured")
-	}
-
-	log.Printf("[SubtitleSync] Syncing subtitles for %s S%02dE%02d (languages: %s)", imdbCode, season, episode, languages)
-	imdb := strings.TrimPrefix(imdbCode, "tt")
-
-	stored := 0
-	storedLangs := make(map[string]int)
+	return s.syncStoredSubtitles(context.Background(), imdbCode, s.syncLanguageSettings(languages), season, episode)
+}
 
-	for _, lang := range strings.Split(languages, ",") {
-		lang = strings.TrimSpace(lang)
-		if lang == "" || storedLangs[lang] >= 2 {
-			continue
-		}
-		subdlResult, err := s.searchSubDLEpisode(imdb, lang, season, episode)
-		if err != nil {
-			log.Printf("[SubtitleSync] SubDL error for %s S%02dE%02d %s: %v", imdbCode, season, episode, lang, err)
-			continue
-		}
-		if len(subdlResult.Subtitles) == 0 {
-			continue
-		}
-		count := s.syncDownloadEpisodeSubtitles(imdbCode, lang, subdlResult.Subtitles, 2-storedLangs[lang], season, episode)
-		storedLangs[lang] += count
-		stored += count
-		time.Sleep(300 * time.Millisecond)
-	}
+// SyncSubtitles downloads and stores subtitles for a given IMDB code.
+// Downloads immediately after each search to avoid URL token expiration.
+// An empty languages list syncs every enabled language.
+func (s *SubtitleService) SyncSubtitles(imdbCode string, languages string) (int, error) {
+	return s.syncStoredSubtitles(context.Background(), imdbCode, s.syncLanguageSettings(languages), 0, 0)
+}
 
-	log.Printf("[SubtitleSync] Stored %d subtitles for %s S%02dE%02d", stored, imdbCode, season, episode)
-	return stored, nil
+// FillMissingSubtitles tops up every enabled language of a movie (season and
+// episode 0) or episode to its maximum number of subtitles
+func (s *SubtitleService) FillMissingSubtitles(ctx context.Context, imdbCode string, season, episode int) (int, error) {
+	return s.syncStoredSubtitles(ctx, imdbCode, s.syncLanguageSettings(""), season, episode)
 }
 
-// syncDownloadEpisodeSubtitles downloads and stores episode subtitles.
-func (s *SubtitleService) syncDownloadEpisodeSubtitles(imdbCode, lang string, subs []Subtitle, limit, season, episode int) int {
-	if limit <= 0 {
-		return 0
+// syncStoredSubtitles asks each sync provider, in priority order, for every
+// language and stores up to the provider's per-language limit, until the
+// language has its maximum number of subtitles. Releases already stored are
+// skipped before downloading, and requests are paced by each provider's
+// rate limit.
+func (s *SubtitleService) syncStoredSubtitles(ctx context.Context, imdbCode string, languages []models.SubtitleLanguageSetting, season, episode int) (int, error) {
+	if s.db == nil {
+		return 0, fmt.Errorf("no database configured")
 	}
-	stored := 0
-	for i, sub := range subs {
-		if i >= limit {
-			break
-		}
-		vtt, err := s.DownloadSubtitle(sub.DownloadURL)
-		if err != nil {
-			log.Printf("[SubtitleSync] Failed to download %s subtitle for %s S%02dE%02d: %v", lang, imdbCode, season, episode, err)
-			continue
-		}
-
-		source := "subdl"
-		if strings.Contains(sub.DownloadURL, "opensubtitles.org") {
-			source = "opensubtitles"
-		}
 
-		storedSub := &models.StoredSubtitle{
-			ImdbCode:        imdbCode,
-			Language:        sub.Language,
-			LanguageName:    sub.LanguageName,
-			ReleaseName:     sub.ReleaseName,
-			HearingImpaired: sub.HearingImpaired,
-			Source:          source,
-			SeasonNumber:    season,
-			EpisodeNumber:   episode,
-		}
-		if err := s.db.CreateSubtitle(storedSub); err != nil {
-			continue
-		}
-		if storedSub.ID == 0 {
-			continue
-		}
-
-		if vttPath, err := s.writeSubtitleFile(imdbCode, storedSub.ID, vtt); err != nil {
-			log.Printf("[SubtitleSync] Failed to write VTT file: %v", err)
-		} else {
-			s.db.UpdateSubtitlePath(storedSub.ID, vttPath)
-		}
-		stored++
-		time.Sleep(500 * time.Millisecond)
+	label := imdbCode
+	if season > 0 && episode > 0 {
+		label = fmt.Sprintf("%s S%02dE%02d", imdbCode, season, episode)
 	}
-	return stored
-}
-
-// SearchByIMDBEpisode searches subtitles for a specific episode.
-func (s *SubtitleService) SearchByIMDBEpisode(imdbID string, languages string, season, episode int) (*SubtitleSearchResult, error) {
-	imdb := strings.TrimPrefix(imdbID, "tt")
-	log.Printf("[SubtitleService] Searching subtitles for IMDB: %s S%02dE%02d, languages: %s", imdb, season, episode, languages)
-
-	var allSubtitles []Subtitle
-
-	// OpenSubtitles with season/episode
-	if languages != "" {
-		for _, lang := range strings.Split(languages, ",") {
-			lang = strings.TrimSpace(lang)
-			osLang := iso2ToOSLang(lang)
-			if osLang == "" {
-				continue
-			}
-			osResult, err := s.searchOpenSubtitlesRESTEpisode(imdb, osLang, season, episode)
-			if err == nil {
-				allSubtitles = append(allSubtitles, osResult.Subtitles...)
-			}
-			time.Sleep(200 * time.Millisecond)
-		}
+	codes := make([]string, len(languages))
+	for i, l := range languages {
+		codes[i] = l.Code
 	}
+	log.Printf("[SubtitleSync] Syncing subtitles for %s (languages: %s)", label, strings.Join(codes, ","))
 
-	// SubDL with season/episode
-	subdlResult, err := s.searchSubDLEpisode(imdb, languages, season, episode)
-	if err == nil {
-		allSubtitles = append(allSubtitles, subdlResult.Subtitles...)
+	providers := s.activeProviders(true)
+	if len(providers) == 0 {
+		return 0, fmt.Errorf("no subtitle providers enabled for sync")
 	}
 
-	if len(allSubtitles) == 0 {
-		return &SubtitleSearchResult{Subtitles: []Subtitle{}, TotalCount: 0}, nil
+	existing, err := s.db.ListSubtitleReleases(imdbCode, season, episode)
+	if err != nil {
+		return 0, err
 	}
-	return &SubtitleSearchResult{Subtitles: allSubtitles, TotalCount: len(allSubtitles)}, nil
-}
-
-// SyncSubtitles downloads and stores subtitles for a given IMDB code.
-// Downloads immediately after each search to avoid URL token expiration.
-func (s *SubtitleService) SyncSubtitles(imdbCode string, languages string) (int, error) {
-	if s.db == nil {
-		return 0, fmt.Errorf("no database configured")
+	storedCount := make(map[string]int)
+	storedReleases := make(map[string]bool)
+	for _, sub := range existing {
+		storedCount[sub.Language]++
+		storedReleases[sub.Language+"|"+sub.ReleaseName] = true
 	}
 
-	log.Printf("[SubtitleSync] Syncing subtitles for %s (languages: %s)", imdbCode, languages)
-	imdb := strings.TrimPrefix(imdbCode, "tt")
-
 	stored := 0
-	storedLangs := make(map[string]int)
+	for _, lang := range languages {
+		for _, entry := range providers {
+			quota := lang.MaxSubtitles - storedCount[lang.Code]
+			if quota <= 0 {
+				break
+			}
+			limit := entry.settings.MovieLimit
+			if season > 0 && episode > 0 {
+				limit = entry.settings.EpisodeLimit
+			}
+			if limit <= 0 {
+				continue
+			}
 
-	// SubDL for sync downloads (OpenSubtitles blocks server IPs for downloads)
-	// OpenSubtitles still used for live search — app downloads directly via Tauri
-	for _, lang := range strings.Split(languages, ",") {
-		lang = strings.TrimSpace(lang)
-		if lang == "" || storedLangs[lang] >= 3 {
-			continue
-		}
-		subdlResult, err := s.searchSubDL(imdb, lang)
-		if err != nil {
-			log.Printf("[SubtitleSync] SubDL error for %s: %v", lang, err)
-			continue
-		}
-		if len(subdlResult.Subtitles) == 0 {
-			log.Printf("[SubtitleSync] No SubDL results for %s", lang)
-			continue
+			if err := s.waitForProvider(ctx, entry); err != nil {
+				return stored, err
+			}
+			subs, err := s.searchProvider(entry, SubtitleQuery{
+				ImdbID:    "tt" + strings.TrimPrefix(imdbCode, "tt"),
+				Languages: []string{lang.Code},
+				Season:    season,
+				Episode:   episode,
+			})
+			if err != nil {
+				log.Printf("[SubtitleSync] %s error for %s %s: %v", entry.provider.Name(), label, lang.Code, err)
+				continue
+			}
+
+			var fresh []Subtitle
+			for _, sub := range applyHearingImpaired(subs, lang.HearingImpaired) {
+				if !storedReleases[sub.Language+"|"+sub.ReleaseName] {
+					fresh = append(fresh, sub)
+				}
+			}
+			if len(fresh) == 0 {
+				log.Printf("[SubtitleSync] No new %s results for %s %s", entry.provider.Name(), label, lang.Code)
+				continue
+			}
+
+			added, err := s.syncDownloadSubtitles(ctx, entry, imdbCode, lang.Code, fresh, min(limit, quota), season, episode)
+			for _, sub := range added {
+				storedCount[lang.Code]++
+				storedReleases[sub.Language+"|"+sub.ReleaseName] = true
+			}
+			stored += len(added)
+			if err != nil {
+				return stored, err
+			}
 		}
-		count := s.syncDownloadSubtitles(imdbCode, lang, subdlResult.Subtitles, 3-storedLangs[lang])
-		storedLangs[lang] += count
-		stored += count
-		time.Sleep(300 * time.Millisecond)
 	}
 
-	log.Printf("[SubtitleSync] Stored %d subtitles for %s", stored, imdbCode)
+	log.Printf("[SubtitleSync] Stored %d subtitles for %s", stored, label)
 	return stored, nil
 }
 
-// syncDownloadSubtitles downloads and stores up to `limit` subtitles from the list.
-func (s *SubtitleService) syncDownloadSubtitles(imdbCode, lang string, subs []Subtitle, limit int) int {
-	if limit <= 0 {
-		return 0
-	}
-	stored := 0
-	for i, sub := range subs {
-		if i >= limit {
+// syncDownloadSubtitles downloads and stores up to `limit` subtitles from the
+// list and returns the ones stored
+func (s *SubtitleService) syncDownloadSubtitles(ctx context.Context, entry *subtitleProviderEntry, imdbCode, lang string, subs []Subtitle, limit, season, episode int) ([]*models.StoredSubtitle, error) {
+	var stored []*models.StoredSubtitle
+	for _, sub := range subs {
+		if len(stored) >= limit {
 			break
 		}
-		vtt, err := s.DownloadSubtitle(sub.DownloadURL)
+		if err := s.waitForProvider(ctx, entry); err != nil {
+			return stored, err
+		}
+		source, charset, err := s.downloadFromProvider(entry, sub)
 		if err != nil {
 			log.Printf("[SubtitleSync] Failed to download %s subtitle for %s: %v", lang, imdbCode, err)
 			continue
 		}
 
-		source := "subdl"
-		if strings.Contains(sub.DownloadURL, "opensubtitles.org") {
-			source = "opensubtitles"
-		}
-
 		storedSub := &models.StoredSubtitle{
 			ImdbCode:        imdbCode,
 			Language:        sub.Language,
 			LanguageName:    sub.LanguageName,
 			ReleaseName:     sub.ReleaseName,
 			HearingImpaired: sub.HearingImpaired,
-			Source:          source,
+			Source:          entry.provider.Name(),
+			SeasonNumber:    season,
+			EpisodeNumber:   episode,
 		}
 		if err := s.db.CreateSubtitle(storedSub); err != nil {
 			log.Printf("[SubtitleSync] Failed to store subtitle: %v", err)
@@ -248,15 +198,15 @@ func (s *SubtitleService) syncDownloadSubtitles(imdbCode, lang string, subs []Su
 			continue
 		}
 
-		if vttPath, err := s.writeSubtitleFile(imdbCode, storedSub.ID, vtt); err != nil {
-			log.Printf("[SubtitleSync] Failed to write VTT file: %v", err)
-		} else {
-			s.db.UpdateSubtitlePath(storedSub.ID, vttPath)
+		if err := s.storeSubtitleFiles(storedSub, source, charset); errors.Is(err, errDuplicateSubtitle) {
+			log.Printf("[SubtitleSync] Skipped %s %s: %v", lang, sub.ReleaseName, err)
+			continue
+		} else if err != nil {
+			log.Printf("[SubtitleSync] Failed to write subtitle files: %v", err)
 		}
-		stored++
-		time.Sleep(500 * time.Millisecond)
+		stored = append(stored, storedSub)
 	}
-	return stored
+	return stored, nil
 }
 
 // ExtractSubtitlesFromTorrent reads subtitle files (.srt, .ass, etc.) from a loaded torrent
@@ -302,8 +252,6 @@ func (s *SubtitleService) ExtractSubtitlesFromTorrent(t *torrent.Torrent, ts *To
 		// Detect language from filename (e.g., "Movie.eng.srt", "Movie.English.srt")
 		lang, langName := detectLanguageFromFilename(name)
 
-		vtt := convertToVTT(toUTF8(string(data)))
-
 		storedSub := &models.StoredSubtitle{
 			ImdbCode:     imdbCode,
 			Language:     lang,
@@ -315,12 +263,18 @@ func (s *SubtitleService) ExtractSubtitlesFromTorrent(t *torrent.Torrent, ts *To
 			log.Printf("[SubtitleExtract] Failed to store %s: %v", name, err)
 			continue
 		}
+		if storedSub.ID == 0 {
+			log.Printf("[SubtitleExtract] Skipped duplicate: %s", name)
+			continue
+		}
 
-		// Write VTT to disk and update path
-		if vttPath, err := s.writeSubtitleFile(imdbCode, storedSub.ID, vtt); err != nil {
-			log.Printf("[SubtitleExtract] Failed to write VTT file: %v", err)
-		} else {
-			s.db.UpdateSubtitlePath(storedSub.ID, vttPath)
+		// Write VTT and the original to disk and update paths
+		source, charset := decodeSubtitleText(data, lang)
+		if err := s.storeSubtitleFiles(storedSub, source, charset); errors.Is(err, errDuplicateSubtitle) {
+			log.Printf("[SubtitleExtract] Skipped %s: %v", name, err)
+			continue
+		} else if err != nil {
+			log.Printf("[SubtitleExtract] Failed to write subtitle files: %v", err)
 		}
 		extracted++
 		log.Printf("[SubtitleExtract] Stored subtitle: %s (lang=%s)", name, lang)
@@ -334,6 +288,9 @@ func (s *SubtitleService) writeSubtitleFile(imdbCode string, id uint, vtt string
 	if s.subtitlesDir == "" {
 		return "", fmt.Errorf("subtitles directory not configured")
 	}
+	if !imdbCodeRe.MatchString(imdbCode) {
+		return "", fmt.Errorf("invalid imdb code %q", imdbCode)
+	}
 	dir := filepath.Join(s.subtitlesDir, imdbCode)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create subtitle dir: %w", err)
@@ -345,8 +302,48 @@ func (s *SubtitleService) writeSubtitleFile(imdbCode string, id uint, vtt string
 	return vttPath, nil
 }
 
+// storeSubtitleFiles converts a decoded subtitle to VTT and writes it to
+// disk. SRT and ASS originals are kept next to it as {id}.srt or {id}.ass so
+// they can be served unchanged. charset is the encoding the source was
+// decoded from. The subtitle is then scored, and errDuplicateSubtitle is
+// returned if it was removed as a copy of a stored subtitle.
+func (s *SubtitleService) storeSubtitleFiles(sub *models.StoredSubtitle, source, charset string) error {
+	vtt := convertToVTT(source)
+	vttPath, err := s.writeSubtitleFile(sub.ImdbCode, sub.ID, vtt)
+	if err != nil {
+		return err
+	}
+	s.db.UpdateSubtitlePath(sub.ID, vttPath)
+	s.db.UpdateSubtitleCharset(sub.ID, charset)
+	sub.VTTPath = vttPath
+	sub.Charset = charset
+
+	if format := DetectSubtitleFormat(source); format != SubtitleFormatVTT {
+		origPath := strings.TrimSuffix(vttPath, ".vtt") + "." + format
+		if err := os.WriteFile(origPath, []byte(source), 0644); err != nil {
+			return fmt.Errorf("failed to write original subtitle: %w", err)
+		}
+		s.db.UpdateSubtitleOriginal(sub.ID, origPath, format)
+		sub.OriginalPath = origPath
+		sub.OriginalFormat = format
+	}
+
+	s.rateStored(sub, vtt)
+	return s.collapseDuplicate(sub)
+}
+
 // detectLanguageFromFilename tries to detect subtitle language from the filename.
 func detectLanguageFromFilename(filename string) (string, string) {
+	if code, name, ok := languageFromFilename(filename); ok {
+		return code, name
+	}
+	// Default to English (most common in torrents)
+	return "en", "English"
+}
+
+// languageFromFilename looks for a language tag in a subtitle filename
+// ("Movie.eng.srt", "Movie.English.srt")
+func languageFromFilename(filename string) (code, langName string, ok bool) {
 	name := strings.ToLower(filename)
 	// Remove extension
 	name = strings.TrimSuffix(name, filepath.Ext(name))
@@ -382,12 +379,10 @@ func detectLanguageFromFilename(filename string) (string, string) {
 	// Check parts from the end (language tag is usually last before extension)
 	for i := len(parts) - 1; i >= 0; i-- {
 		if match, ok := langMap[parts[i]]; ok {
-			return match[0], match[1]
+			return match[0], match[1], true
 		}
 	}
-
-	// Default to English (most common in torrents)
-	return "en", "English"
+	return "", "", false
 }
 
 // Subtitle represents a single subtitle result
@@ -401,6 +396,8 @@ type Subtitle struct {
 	DownloadCount    int64   `json:"download_count"`
 	HearingImpaired  bool    `json:"hearing_impaired"`
 	FPS              float64 `json:"fps,omitempty"`
+	HashMatch        bool    `json:"hash_match,omitempty"` // matched the file's moviehash, so timed for that release
+	Score            *int    `json:"score,omitempty"`      // quality score of stored subtitles
 }
 
 // SubtitleSearchResult wraps subtitle search results
@@ -409,85 +406,63 @@ type SubtitleSearchResult struct {
 	TotalCount int        `json:"total_count"`
 }
 
-// SearchByIMDB searches subtitles by IMDB ID, queries OpenSubtitles first, SubDL as fallback
+// SearchByIMDB searches subtitles by IMDB ID across the enabled providers in priority order
 func (s *SubtitleService) SearchByIMDB(imdbID string, languages string) (*SubtitleSearchResult, error) {
-	imdb := strings.TrimPrefix(imdbID, "tt")
-	log.Printf("[SubtitleService] Searching subtitles for IMDB: %s, languages: %s", imdb, languages)
-
-	var allSubtitles []Subtitle
-
-	// Query OpenSubtitles first (better quality matches)
-	if languages != "" {
-		for _, lang := range strings.Split(languages, ",") {
-			lang = strings.TrimSpace(lang)
-			osLang := iso2ToOSLang(lang)
-			if osLang == "" {
-				continue
-			}
-			osResult, err := s.searchOpenSubtitlesREST(imdb, osLang)
-			if err != nil {
-				log.Printf("[SubtitleService] OpenSubtitles error for %s: %v", lang, err)
-				continue
-			}
-			allSubtitles = append(allSubtitles, osResult.Subtitles...)
-			if len(osResult.Subtitles) > 0 {
-				log.Printf("[SubtitleService] OpenSubtitles found %d %s subtitles", len(osResult.Subtitles), lang)
-			}
-			time.Sleep(200 * time.Millisecond) // rate limit
-		}
-	} else {
-		osResult, err := s.searchOpenSubtitlesREST(imdb, "")
-		if err != nil {
-			log.Printf("[SubtitleService] OpenSubtitles error: %v", err)
-		} else {
-			allSubtitles = append(allSubtitles, osResult.Subtitles...)
-			log.Printf("[SubtitleService] OpenSubtitles found %d subtitles", len(osResult.Subtitles))
-		}
-	}
-
-	// Query SubDL as fallback (fills gaps for languages OpenSubtitles missed)
-	subdlResult, err := s.searchSubDL(imdb, languages)
-	if err != nil {
-		log.Printf("[SubtitleService] SubDL error: %v", err)
-	} else {
-		allSubtitles = append(allSubtitles, subdlResult.Subtitles...)
-		log.Printf("[SubtitleService] SubDL found %d subtitles", len(subdlResult.Subtitles))
-	}
-
-	if len(allSubtitles) == 0 {
-		return &SubtitleSearchResult{Subtitles: []Subtitle{}, TotalCount: 0}, nil
-	}
+	return s.SearchByIMDBEpisode(imdbID, languages, 0, 0)
+}
 
-	return &SubtitleSearchResult{Subtitles: allSubtitles, TotalCount: len(allSubtitles)}, nil
+// SearchByIMDBEpisode searches subtitles for a specific episode.
+func (s *SubtitleService) SearchByIMDBEpisode(imdbID string, languages string, season, episode int) (*SubtitleSearchResult, error) {
+	log.Printf("[SubtitleService] Searching subtitles for IMDB: %s S%02dE%02d, languages: %s", imdbID, season, episode, languages)
+	return s.Search(SubtitleQuery{
+		ImdbID:    "tt" + strings.TrimPrefix(imdbID, "tt"),
+		Languages: SplitLanguages(languages),
+		Season:    season,
+		Episode:   episode,
+	}), nil
 }
 
 // SearchByFilename searches subtitles by release filename
 func (s *SubtitleService) SearchByFilename(filename string, languages string) (*SubtitleSearchResult, error) {
 	log.Printf("[SubtitleService] Searching subtitles by filename: %s", filename)
+	return s.Search(SubtitleQuery{FileName: filename, Languages: SplitLanguages(languages)}), nil
+}
 
-	encodedFilename := url.QueryEscape(filename)
-	apiURL := fmt.Sprintf("%s?api_key=%s&file_name=%s", subdlAPIURL, s.subdlKey, encodedFilename)
-	if languages != "" {
-		apiURL += "&languages=" + url.QueryEscape(strings.ToUpper(languages))
-	}
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
+// Search collects results from every enabled provider that supports the
+// query. Results matching the query's moviehash come first.
+func (s *SubtitleService) Search(q SubtitleQuery) *SubtitleSearchResult {
+	all := []Subtitle{}
+	for _, entry := range s.activeProviders(false) {
+		subs, err := s.searchProvider(entry, q)
+		if err != nil {
+			if err != errSubtitleQueryUnsupported {
+				log.Printf("[SubtitleService] %s error: %v", entry.provider.Name(), err)
+			}
+			continue
+		}
+		log.Printf("[SubtitleService] %s found %d subtitles", entry.provider.Name(), len(subs))
+		all = append(all, subs...)
 	}
-	req.Header.Set("User-Agent", "OmniusServer v1.0")
+	RankHashMatches(all)
+	return &SubtitleSearchResult{Subtitles: all, TotalCount: len(all)}
+}
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subtitles by filename: %w", err)
-	}
-	defer resp.Body.Close()
+// RankHashMatches moves moviehash matches to the front, keeping the order otherwise
+func RankHashMatches(subs []Subtitle) {
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].HashMatch && !subs[j].HashMatch
+	})
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("SubDL API error: %d", resp.StatusCode)
+// SplitLanguages splits a comma-separated language list, dropping blanks
+func SplitLanguages(languages string) []string {
+	var langs []string
+	for _, lang := range strings.Split(languages, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
 	}
-
-	return s.parseSubDLResponse(resp.Body)
+	return langs
 }
 
 // DownloadSubtitle downloads a subtitle file, decompresses if needed, converts to VTT
@@ -500,32 +475,50 @@ func (s *SubtitleService) DownloadSubtitle(downloadURL string) (string, error) {
 	}
 
 	log.Printf("[SubtitleService] Downloaded %d bytes", len(data))
+	content, _, err := decodeSubtitle(data, "")
+	if err != nil {
+		return "", err
+	}
+	vtt := convertToVTT(content)
+	log.Printf("[SubtitleService] Converted to VTT (%d chars)", len(vtt))
+	return vtt, nil
+}
 
-	// Detect format and decompress
+// downloadSubtitleSource downloads a subtitle file and returns it decompressed
+// and decoded, in its original format, with the charset it was in
+func (s *SubtitleService) downloadSubtitleSource(downloadURL, lang string) (string, string, error) {
+	data, err := s.downloadWithHTTP(downloadURL)
+	if err != nil {
+		return "", "", err
+	}
+	return decodeSubtitle(data, lang)
+}
+
+// decodeSubtitle decompresses a downloaded subtitle file if needed and
+// decodes it to UTF-8, guided by the subtitle's language. It also returns
+// the charset the file was in.
+func decodeSubtitle(data []byte, lang string) (string, string, error) {
 	var content string
+	var err error
 	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
 		// Gzip
 		content, err = decompressGzip(data)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 	} else if len(data) >= 2 && data[0] == 0x50 && data[1] == 0x4B {
 		// ZIP
 		content, err = extractSubtitleFromZip(data)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 	} else {
 		content = string(data)
 	}
 
 	// Ensure UTF-8 encoding
-	content = toUTF8(content)
-
-	// Convert to VTT
-	vtt := convertToVTT(content)
-	log.Printf("[SubtitleService] Converted to VTT (%d chars)", len(vtt))
-	return vtt, nil
+	text, charset := decodeSubtitleText([]byte(content), lang)
+	return text, charset, nil
 }
 
 // downloadWithHTTP downloads a file using Go's HTTP client
@@ -549,187 +542,8 @@ func (s *SubtitleService) downloadWithHTTP(downloadURL string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
-func (s *SubtitleService) searchSubDLEpisode(imdbID string, languages string, season, episode int) (*SubtitleSearchResult, error) {
-	apiURL := fmt.Sprintf("%s?api_key=%s&imdb_id=tt%s&season_number=%d&episode_number=%d", subdlAPIURL, s.subdlKey, imdbID, season, episode)
-	if languages != "" {
-		apiURL += "&languages=" + url.QueryEscape(strings.ToUpper(languages))
-	}
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "OmniusServer v1.0")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("SubDL API error: %d", resp.StatusCode)
-	}
-
-	return s.parseSubDLResponse(resp.Body)
-}
-
-func (s *SubtitleService) searchSubDL(imdbID string, languages string) (*SubtitleSearchResult, error) {
-	apiURL := fmt.Sprintf("%s?api_key=%s&imdb_id=tt%s", subdlAPIURL, s.subdlKey, imdbID)
-	if languages != "" {
-		apiURL += "&languages=" + url.QueryEscape(strings.ToUpper(languages))
-	}
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "OmniusServer v1.0")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("SubDL API error: %d", resp.StatusCode)
-	}
-
-	return s.parseSubDLResponse(resp.Body)
-}
-
-func (s *SubtitleService) parseSubDLResponse(body io.Reader) (*SubtitleSearchResult, error) {
-	var data subDLResponse
-	if err := json.NewDecoder(body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to parse SubDL response: %w", err)
-	}
-
-	if !data.Status {
-		return &SubtitleSearchResult{Subtitles: []Subtitle{}, TotalCount: 0}, nil
-	}
-
-	subtitles := make([]Subtitle, 0, len(data.Subtitles))
-	for _, sub := range data.Subtitles {
-		langCode := subdlLangToISO2(strings.ToLower(sub.Language))
-		langName := sub.Lang
-		if langName == "" {
-			langName = sub.Language
-		}
-		subtitles = append(subtitles, Subtitle{
-			ID:              sub.URL,
-			Language:        langCode,
-			LanguageName:    langName,
-			DownloadURL:     "https://dl.subdl.com" + sub.URL,
-			ReleaseName:     sub.ReleaseName,
-			Uploader:        sub.Author,
-			DownloadCount:   0,
-			HearingImpaired: sub.HI,
-		})
-	}
-
-	log.Printf("[SubtitleService] SubDL found %d subtitles", len(subtitles))
-	return &SubtitleSearchResult{Subtitles: subtitles, TotalCount: len(subtitles)}, nil
-}
-
-func (s *SubtitleService) searchOpenSubtitlesRESTEpisode(imdbID string, osLang string, season, episode int) (*SubtitleSearchResult, error) {
-	apiURL := fmt.Sprintf("%s/imdbid-%s/season-%d/episode-%d", opensubtitlesRestURL, imdbID, season, episode)
-	if osLang != "" {
-		apiURL += "/sublanguageid-" + osLang
-	}
-	return s.doOpenSubtitlesSearch(apiURL)
-}
-
-func (s *SubtitleService) searchOpenSubtitlesREST(imdbID string, osLang string) (*SubtitleSearchResult, error) {
-	apiURL := fmt.Sprintf("%s/imdbid-%s", opensubtitlesRestURL, imdbID)
-	if osLang != "" {
-		apiURL += "/sublanguageid-" + osLang
-	}
-	return s.doOpenSubtitlesSearch(apiURL)
-}
-
-func (s *SubtitleService) doOpenSubtitlesSearch(apiURL string) (*SubtitleSearchResult, error) {
-	log.Printf("[SubtitleService] Fetching: %s", apiURL)
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "OmniusServer v1.0")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[SubtitleService] OpenSubtitles API error: %d", resp.StatusCode)
-		return &SubtitleSearchResult{Subtitles: []Subtitle{}, TotalCount: 0}, nil
-	}
-
-	var data []openSubtitlesResult
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to parse subtitle response: %w", err)
-	}
-
-	subtitles := make([]Subtitle, 0, len(data))
-	for _, sub := range data {
-		var fps float64
-		fmt.Sscanf(sub.MovieFPS, "%f", &fps)
-		var dlCount int64
-		fmt.Sscanf(sub.SubDownloadsCnt, "%d", &dlCount)
-
-		// Normalize 3-letter to 2-letter language code
-		langCode := osLangToISO2(sub.SubLanguageID)
-
-		subtitles = append(subtitles, Subtitle{
-			ID:              sub.IDSubtitleFile,
-			Language:        langCode,
-			LanguageName:    sub.LanguageName,
-			DownloadURL:     sub.SubDownloadLink,
-			ReleaseName:     sub.MovieReleaseName,
-			Uploader:        sub.UserNickName,
-			DownloadCount:   dlCount,
-			HearingImpaired: sub.SubHearingImpaired == "1",
-			FPS:             fps,
-		})
-	}
-
-	log.Printf("[SubtitleService] Found %d subtitles from OpenSubtitles", len(subtitles))
-	return &SubtitleSearchResult{Subtitles: subtitles, TotalCount: len(subtitles)}, nil
-}
-
 // --- Encoding detection ---
 
-// toUTF8 converts text to UTF-8 if it's not already valid UTF-8.
-// Handles Windows-1252/Latin-1 encoded subtitles (common for European languages).
-func toUTF8(s string) string {
-	if utf8.ValidString(s) {
-		return s
-	}
-	// Check for UTF-8 BOM and strip it
-	if len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF {
-		s = s[3:]
-		if utf8.ValidString(s) {
-			return s
-		}
-	}
-	// Assume Windows-1252 (superset of Latin-1, most common for European subs)
-	var buf strings.Builder
-	buf.Grow(len(s) * 2)
-	for i := 0; i < len(s); i++ {
-		b := s[i]
-		if b < 0x80 {
-			buf.WriteByte(b)
-		} else {
-			// Windows-1252 bytes 0x80-0xFF map to specific Unicode code points
-			buf.WriteRune(windows1252ToRune(b))
-		}
-	}
-	return buf.String()
-}
-
 // windows1252ToRune converts a Windows-1252 byte (0x80-0xFF) to its Unicode rune.
 func windows1252ToRune(b byte) rune {
 	// 0x80-0x9F range has special mappings in Windows-1252 (differs from Latin-1)
@@ -773,10 +587,12 @@ func srtToVTT(srt string) string {
 	return vtt.String()
 }
 
+// assToVTT converts an ASS/SSA script to VTT. Italic, bold and underline from
+// the cue's style and override tags are kept, and cues aligned to the top of
+// the screen are placed there. Drawings and comments are skipped.
 func assToVTT(ass string) string {
-	var vtt strings.Builder
-	vtt.WriteString("WEBVTT\n\n")
-	cueNumber := 1
+	styles := parseASSStyles(ass)
+	var cues []SubtitleCue
 
 	for _, line := range strings.Split(ass, "\n") {
 		if !strings.HasPrefix(line, "Dialogue:") {
@@ -787,45 +603,148 @@ func assToVTT(ass string) string {
 			continue
 		}
 
-		start := convertASSTime(parts[1])
-		end := convertASSTime(parts[2])
-		if start == "" || end == "" {
+		start, ok1 := parseCueTime(parts[1])
+		end, ok2 := parseCueTime(parts[2])
+		if !ok1 || !ok2 {
 			continue
 		}
 
-		text := parts[9]
-		text = strings.ReplaceAll(text, "\\N", "\n")
-		text = strings.ReplaceAll(text, "\\n", "\n")
-		// Strip style tags
-		for _, tag := range []string{"{\\i1}", "{\\i0}", "{\\b1}", "{\\b0}"} {
-			text = strings.ReplaceAll(text, tag, "")
+		style := styles[strings.TrimPrefix(strings.TrimSpace(parts[3]), "*")]
+		text, top, ok := assTextToVTT(strings.TrimRight(parts[9], "\r"), style.top)
+		if !ok || text == "" {
+			continue
+		}
+		for _, tag := range []struct {
+			on   bool
+			name string
+		}{{style.underline, "u"}, {style.bold, "b"}, {style.italic, "i"}} {
+			if tag.on {
+				text = "<" + tag.name + ">" + text + "</" + tag.name + ">"
+			}
 		}
 
-		fmt.Fprintf(&vtt, "%d\n%s --> %s\n%s\n\n", cueNumber, start, end, strings.TrimSpace(text))
-		cueNumber++
+		cue := SubtitleCue{Index: len(cues) + 1, StartMs: start, EndMs: end, Text: text}
+		if top {
+			cue.Settings = "line:0"
+		}
+		cues = append(cues, cue)
 	}
-	return vtt.String()
+	return FormatVTT(cues)
 }
 
-func convertASSTime(t string) string {
-	t = strings.TrimSpace(t)
-	parts := strings.Split(t, ":")
-	if len(parts) != 3 {
-		return ""
+// assStyle is the part of an ASS style that VTT can show
+type assStyle struct {
+	bold, italic, underline, top bool
+}
+
+// parseASSStyles reads the styles of an ASS/SSA script by name
+func parseASSStyles(ass string) map[string]assStyle {
+	styles := make(map[string]assStyle)
+	var columns map[string]int
+	inStyles := false
+
+	for _, line := range strings.Split(ass, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inStyles = strings.Contains(strings.ToLower(line), "styles")
+			continue
+		}
+		if !inStyles {
+			continue
+		}
+		if format, ok := strings.CutPrefix(line, "Format:"); ok {
+			columns = make(map[string]int)
+			for i, name := range strings.Split(format, ",") {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+		fields, ok := strings.CutPrefix(line, "Style:")
+		if !ok || columns == nil {
+			continue
+		}
+
+		values := strings.Split(fields, ",")
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(values) {
+				return strings.TrimSpace(values[i])
+			}
+			return ""
+		}
+		flag := func(column string) bool {
+			v := get(column)
+			return v != "" && v != "0"
+		}
+		alignment, _ := strconv.Atoi(get("alignment"))
+		styles[get("name")] = assStyle{
+			bold:      flag("bold"),
+			italic:    flag("italic"),
+			underline: flag("underline"),
+			// Numpad layout; ASS 7-9 are the top row
+			top: alignment >= 7,
+		}
 	}
-	var hours, minutes int
-	fmt.Sscanf(parts[0], "%d", &hours)
-	fmt.Sscanf(parts[1], "%d", &minutes)
+	return styles
+}
 
-	secParts := strings.Split(parts[2], ".")
-	if len(secParts) != 2 {
-		return ""
+// assOverrideRe matches the override tags VTT can show: \i1, \b0, \u1, \an8,
+// the legacy SSA \a6 and drawing mode \p1
+var assOverrideRe = regexp.MustCompile(`\\(an|[ibupa])(\d+)`)
+
+// assTextToVTT converts the text of an ASS dialogue line to VTT cue text. It
+// reports whether the cue is aligned to the top, starting from the style's
+// alignment, and ok=false for drawings.
+func assTextToVTT(text string, top bool) (string, bool, bool) {
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `\N`, "\n", `\n`, "\n", `\h`, "\u00a0")
+	var b strings.Builder
+	open := map[string]bool{}
+
+	for text != "" {
+		startBlock := strings.Index(text, "{")
+		if startBlock < 0 {
+			b.WriteString(escape.Replace(text))
+			break
+		}
+		endBlock := strings.Index(text[startBlock:], "}")
+		if endBlock < 0 {
+			b.WriteString(escape.Replace(text))
+			break
+		}
+		b.WriteString(escape.Replace(text[:startBlock]))
+		block := text[startBlock+1 : startBlock+endBlock]
+		text = text[startBlock+endBlock+1:]
+
+		for _, m := range assOverrideRe.FindAllStringSubmatch(block, -1) {
+			n, _ := strconv.Atoi(m[2])
+			switch m[1] {
+			case "i", "b", "u":
+				if on := n != 0; on != open[m[1]] {
+					open[m[1]] = on
+					if on {
+						b.WriteString("<" + m[1] + ">")
+					} else {
+						b.WriteString("</" + m[1] + ">")
+					}
+				}
+			case "p":
+				if n > 0 {
+					return "", false, false
+				}
+			case "an":
+				top = n >= 7
+			case "a":
+				// SSA alignment: 5-7 are the top row
+				top = n >= 5 && n <= 7
+			}
+		}
 	}
-	var seconds, centiseconds int
-	fmt.Sscanf(secParts[0], "%d", &seconds)
-	fmt.Sscanf(secParts[1], "%d", &centiseconds)
 
-	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, seconds, centiseconds*10)
+	for _, tag := range []string{"u", "b", "i"} {
+		if open[tag] {
+			b.WriteString("</" + tag + ">")
+		}
+	}
+	return strings.TrimSpace(b.String()), top, true
 }
 
 // --- Decompression helpers ---
@@ -875,100 +794,6 @@ func extractSubtitleFromZip(data []byte) (string, error) {
 	return "", fmt.Errorf("no subtitle file found in ZIP archive")
 }
 
-// --- API response types ---
-
-type subDLResponse struct {
-	Status    bool            `json:"status"`
-	Subtitles []subDLSubtitle `json:"subtitles"`
-}
-
-type subDLSubtitle struct {
-	ReleaseName string `json:"release_name"`
-	Lang        string `json:"lang"`
-	Language    string `json:"language"`
-	URL         string `json:"url"`
-	HI          bool   `json:"hi"`
-	Author      string `json:"author"`
-}
-
-type openSubtitlesResult struct {
-	IDSubtitleFile     string `json:"IDSubtitleFile"`
-	SubLanguageID      string `json:"SubLanguageID"`
-	LanguageName       string `json:"LanguageName"`
-	SubDownloadLink    string `json:"SubDownloadLink"`
-	MovieReleaseName   string `json:"MovieReleaseName"`
-	UserNickName       string `json:"UserNickName"`
-	SubDownloadsCnt    string `json:"SubDownloadsCnt"`
-	SubHearingImpaired string `json:"SubHearingImpaired"`
-	MovieFPS           string `json:"MovieFPS"`
-}
-
-// osLangToISO2 converts OpenSubtitles 3-letter language codes to ISO 639-1 2-letter codes
-func osLangToISO2(code string) string {
-	m := map[string]string{
-		"eng": "en", "spa": "es", "fre": "fr", "ger": "de", "ita": "it",
-		"por": "pt", "rus": "ru", "chi": "zh", "jpn": "ja", "kor": "ko",
-		"ara": "ar", "dut": "nl", "pol": "pl", "tur": "tr", "swe": "sv",
-		"nor": "no", "dan": "da", "fin": "fi", "gre": "el", "heb": "he",
-		"hin": "hi", "tha": "th", "vie": "vi", "ind": "id", "cze": "cs",
-		"hun": "hu", "rum": "ro", "bul": "bg", "ukr": "uk", "hrv": "hr",
-		"srp": "sr", "slo": "sk", "slv": "sl", "alb": "sq", "per": "fa",
-		"may": "ms", "est": "et", "lav": "lv", "lit": "lt", "cat": "ca",
-		"bos": "bs", "mac": "mk", "ice": "is", "geo": "ka", "arm": "hy",
-	}
-	if iso2, ok := m[code]; ok {
-		return iso2
-	}
-	return code
-}
-
-// iso2ToOSLang converts ISO 639-1 2-letter codes to OpenSubtitles 3-letter codes
-func iso2ToOSLang(code string) string {
-	m := map[string]string{
-		"en": "eng", "es": "spa", "fr": "fre", "de": "ger", "it": "ita",
-		"pt": "por", "ru": "rus", "zh": "chi", "ja": "jpn", "ko": "kor",
-		"ar": "ara", "nl": "dut", "pl": "pol", "tr": "tur", "sv": "swe",
-		"no": "nor", "da": "dan", "fi": "fin", "el": "gre", "he": "heb",
-		"hi": "hin", "th": "tha", "vi": "vie", "id": "ind", "cs": "cze",
-		"hu": "hun", "ro": "rum", "bg": "bul", "uk": "ukr", "hr": "hrv",
-		"sr": "srp", "sk": "slo", "sl": "slv", "sq": "alb", "fa": "per",
-		"ms": "may", "et": "est", "lv": "lav", "lt": "lit", "ca": "cat",
-		"bs": "bos", "mk": "mac", "is": "ice", "ka": "geo", "hy": "arm",
-	}
-	if os3, ok := m[code]; ok {
-		return os3
-	}
-	return ""
-}
-
-// subdlLangToISO2 converts SubDL full language names to ISO 639-1 2-letter codes
-func subdlLangToISO2(lang string) string {
-	m := map[string]string{
-		"english": "en", "spanish": "es", "french": "fr", "german": "de",
-		"italian": "it", "portuguese": "pt", "russian": "ru", "chinese": "zh",
-		"japanese": "ja", "korean": "ko", "arabic": "ar", "dutch": "nl",
-		"polish": "pl", "turkish": "tr", "swedish": "sv", "norwegian": "no",
-		"danish": "da", "finnish": "fi", "greek": "el", "hebrew": "he",
-		"hindi": "hi", "thai": "th", "vietnamese": "vi", "indonesian": "id",
-		"czech": "cs", "hungarian": "hu", "romanian": "ro", "bulgarian": "bg",
-		"ukrainian": "uk", "croatian": "hr", "serbian": "sr", "slovak": "sk",
-		"slovenian": "sl", "albanian": "sq", "persian": "fa", "farsi": "fa",
-		"malay": "ms", "estonian": "et", "latvian": "lv", "lithuanian": "lt",
-		"catalan": "ca", "bosnian": "bs", "macedonian": "mk", "icelandic": "is",
-		"georgian": "ka", "armenian": "hy", "bengali": "bn", "urdu": "ur",
-		"tagalog": "tl", "filipino": "tl", "swahili": "sw",
-		"big 5 code": "zh", "brazillian portuguese": "pt",
-	}
-	if code, ok := m[lang]; ok {
-		return code
-	}
-	// If already a 2-letter code, return as-is
-	if len(lang) == 2 {
-		return lang
-	}
-	return lang
-}
-
 // GetSubtitleLanguages returns the static list of supported subtitle languages
 func GetSubtitleLanguages() []SubtitleLanguage {
 	return []SubtitleLanguage{
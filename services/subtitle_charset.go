@@ -0,0 +1,351 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	unicodeenc "golang.org/x/text/encoding/unicode"
+
+	"torrent-server/models"
+)
+
+// Charset names recorded on stored subtitles
+const (
+	charsetUTF8    = "utf-8"
+	charsetUTF16LE = "utf-16le"
+	charsetUTF16BE = "utf-16be"
+)
+
+// legacyCharsets are the single-byte code pages subtitles are commonly
+// written in, in the order they are tried when the language is unknown
+var legacyCharsets = []struct {
+	name    string
+	charmap *charmap.Charmap
+}{
+	{"windows-1252", charmap.Windows1252}, // Western European
+	{"windows-1250", charmap.Windows1250}, // Central European, Albanian
+	{"windows-1251", charmap.Windows1251}, // Cyrillic
+	{"windows-1253", charmap.Windows1253}, // Greek
+	{"windows-1254", charmap.Windows1254}, // Turkish
+	{"windows-1255", charmap.Windows1255}, // Hebrew
+	{"windows-1256", charmap.Windows1256}, // Arabic, Persian
+	{"koi8-r", charmap.KOI8R},
+}
+
+// charsetsByLanguage lists the code pages a language's subtitles are usually
+// written in, most common first. Languages not listed use windows-1252.
+var charsetsByLanguage = map[string][]string{
+	"ar": {"windows-1256"}, "fa": {"windows-1256"}, "ur": {"windows-1256"},
+	"ru": {"windows-1251", "koi8-r"}, "uk": {"windows-1251"}, "be": {"windows-1251"},
+	"bg": {"windows-1251"}, "mk": {"windows-1251"}, "sr": {"windows-1251", "windows-1250"},
+	"tr": {"windows-1254"},
+	"el": {"windows-1253"},
+	"he": {"windows-1255"},
+	"pl": {"windows-1250"}, "cs": {"windows-1250"}, "sk": {"windows-1250"},
+	"hu": {"windows-1250"}, "ro": {"windows-1250"}, "hr": {"windows-1250"},
+	"sl": {"windows-1250"}, "bs": {"windows-1250"},
+	"sq": {"windows-1250", "windows-1252"},
+}
+
+// scriptsByLanguage is the script of languages not written in Latin
+var scriptsByLanguage = map[string]*unicode.RangeTable{
+	"ar": unicode.Arabic, "fa": unicode.Arabic, "ur": unicode.Arabic,
+	"ru": unicode.Cyrillic, "uk": unicode.Cyrillic, "be": unicode.Cyrillic,
+	"bg": unicode.Cyrillic, "mk": unicode.Cyrillic,
+	"el": unicode.Greek,
+	"he": unicode.Hebrew,
+}
+
+// commonSymbols are non-letters above ASCII that show up in ordinary subtitle text
+const commonSymbols = " «»‘’“”„–—…·¿¡°€"
+
+// decodeSubtitleText decodes subtitle bytes to UTF-8 and returns the charset
+// they were in. A BOM is trusted; otherwise valid UTF-8 is kept and legacy
+// code pages are scored, with the ones usual for lang (ISO 639-1) preferred.
+func decodeSubtitleText(data []byte, lang string) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeWith(unicodeenc.UTF16(unicodeenc.LittleEndian, unicodeenc.ExpectBOM), data), charsetUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeWith(unicodeenc.UTF16(unicodeenc.BigEndian, unicodeenc.ExpectBOM), data), charsetUTF16BE
+	}
+	if order, ok := guessUTF16(data); ok {
+		name := charsetUTF16LE
+		if order == unicodeenc.BigEndian {
+			name = charsetUTF16BE
+		}
+		return decodeWith(unicodeenc.UTF16(order, unicodeenc.IgnoreBOM), data), name
+	}
+	if utf8.Valid(data) {
+		return string(data), charsetUTF8
+	}
+
+	name := detectLegacyCharset(data, lang)
+	return decodeWith(legacyCharset(name), data), name
+}
+
+// guessUTF16 detects UTF-16 without a BOM: mostly-ASCII text leaves every
+// other byte zero
+func guessUTF16(data []byte) (unicodeenc.Endianness, bool) {
+	n := min(len(data), 4096) &^ 1
+	if n < 64 {
+		return unicodeenc.LittleEndian, false
+	}
+	var evenZeros, oddZeros int
+	for i := 0; i < n; i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	half := n / 2
+	switch {
+	case oddZeros > half*3/5 && evenZeros < half/10:
+		return unicodeenc.LittleEndian, true
+	case evenZeros > half*3/5 && oddZeros < half/10:
+		return unicodeenc.BigEndian, true
+	}
+	return unicodeenc.LittleEndian, false
+}
+
+func decodeWith(enc encoding.Encoding, data []byte) string {
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(out)
+}
+
+func legacyCharset(name string) encoding.Encoding {
+	for _, c := range legacyCharsets {
+		if c.name == name {
+			return c.charmap
+		}
+	}
+	return charmap.Windows1252
+}
+
+// detectLegacyCharset scores every legacy code page on how plausible the
+// decoded text looks and returns the best one. Letters of the language's
+// script and code pages usual for the language get a bonus, so the declared
+// language decides between code pages that decode to equally plausible text.
+func detectLegacyCharset(data []byte, lang string) string {
+	lang = strings.ToLower(lang)
+	preferred := charsetsByLanguage[lang]
+	if preferred == nil {
+		preferred = []string{"windows-1252"}
+	}
+	script := scriptsByLanguage[lang]
+
+	best, bestScore := preferred[0], 0
+	for i, c := range legacyCharsets {
+		score := scoreDecoding(c.charmap, data, script)
+		for _, p := range preferred {
+			if p == c.name {
+				// Enough to win ties between code pages of the same script
+				score += max(score/4, 0) + 1
+			}
+		}
+		if i == 0 || score > bestScore {
+			best, bestScore = c.name, score
+		}
+	}
+	return best
+}
+
+// scoreDecoding rates the text a code page decodes data to. Letters score,
+// letters continuing a word in the same script score more, and undefined
+// bytes, control characters, rare symbols and words mixing scripts lose
+// points, as do capitals inside words. Runs of accented Latin letters are
+// suspicious too: real Latin text has accents scattered between ASCII
+// letters, while a Cyrillic or Greek text read as Latin is almost all
+// accented letters.
+func scoreDecoding(cm *charmap.Charmap, data []byte, script *unicode.RangeTable) int {
+	score := 0
+	var prev rune
+	for _, b := range data {
+		r := cm.DecodeByte(b)
+		if b < 0x80 {
+			prev = r
+			continue
+		}
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r):
+			score -= 5
+		case unicode.IsLetter(r):
+			prevLetter := unicode.IsLetter(prev)
+			if script != nil && unicode.Is(script, r) {
+				score += 2
+			}
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				// Capitals inside words come from a code page with the
+				// cases swapped, like KOI8-R read as Windows-1251
+				score -= 2
+			}
+			switch {
+			case unicode.Is(unicode.Latin, r):
+				if prevLetter && prev >= 0x80 {
+					score--
+				} else {
+					score++
+				}
+			case prevLetter && prev >= 0x80 && !sameScript(prev, r):
+				score -= 2
+			case prevLetter && prev < 0x80:
+				// A non-Latin letter glued to an ASCII letter
+				score -= 2
+			default:
+				score++
+				if prevLetter {
+					score++
+				}
+			}
+		case strings.ContainsRune(commonSymbols, r):
+		default:
+			score--
+		}
+		prev = r
+	}
+	return score
+}
+
+func sameScript(a, b rune) bool {
+	for _, t := range []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew} {
+		if unicode.Is(t, a) {
+			return unicode.Is(t, b)
+		}
+	}
+	return false
+}
+
+// windows1252Bytes maps the runes windows1252ToRune produces back to bytes
+var windows1252Bytes = func() map[rune]byte {
+	m := make(map[rune]byte, 128)
+	for b := 0x80; b <= 0xFF; b++ {
+		m[windows1252ToRune(byte(b))] = byte(b)
+	}
+	return m
+}()
+
+// undoWindows1252 recovers the original bytes of text that was wrongly
+// decoded as Windows-1252. ok is false if the text contains characters
+// Windows-1252 can't produce, meaning it was decoded correctly.
+func undoWindows1252(text string) (data []byte, ok bool) {
+	data = make([]byte, 0, len(text))
+	for _, r := range text {
+		if r < 0x80 {
+			data = append(data, byte(r))
+			continue
+		}
+		b, found := windows1252Bytes[r]
+		if !found {
+			return nil, false
+		}
+		data = append(data, b)
+	}
+	return data, true
+}
+
+// redecodeText re-decodes text that older versions decoded as Windows-1252.
+// It returns the fixed text and its real charset, or changed=false when the
+// text was decoded correctly.
+func redecodeText(text, lang string) (fixed, charset string, changed bool) {
+	data, ok := undoWindows1252(text)
+	// Older versions only decoded files that weren't valid UTF-8
+	if !ok || utf8.Valid(data) {
+		return text, "", false
+	}
+	charset = detectLegacyCharset(data, lang)
+	if charset == "windows-1252" {
+		return text, charset, false
+	}
+	return decodeWith(legacyCharset(charset), data), charset, true
+}
+
+// RedecodeStoredSubtitles repairs subtitles stored before charset detection,
+// which decoded every non-UTF-8 file as Windows-1252. Each file is turned
+// back into its original bytes and decoded with the charset detected for the
+// subtitle's language. Checked subtitles get their charset recorded, so each
+// is only looked at once.
+func (s *SubtitleService) RedecodeStoredSubtitles(ctx context.Context, p *JobProgress) error {
+	if s.db == nil {
+		return fmt.Errorf("no database configured")
+	}
+	subs, err := s.db.ListSubtitlesWithoutCharset()
+	if err != nil {
+		return err
+	}
+	p.SetTotal(len(subs))
+
+	for i := range subs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		sub := &subs[i]
+		p.SetCurrent(fmt.Sprintf("%s %s #%d", sub.ImdbCode, sub.Language, sub.ID))
+
+		fixed, charset, err := s.redecodeStored(sub)
+		if err != nil {
+			log.Printf("[SubtitleCharset] Failed to re-decode subtitle %d: %v", sub.ID, err)
+			p.Add(1, 0, 1)
+			continue
+		}
+		s.db.UpdateSubtitleCharset(sub.ID, charset)
+		if fixed {
+			log.Printf("[SubtitleCharset] Re-decoded subtitle %d (%s) as %s", sub.ID, sub.Language, charset)
+			p.Add(1, 1, 0)
+		} else {
+			p.Add(1, 0, 0)
+		}
+	}
+	return nil
+}
+
+// redecodeStored re-decodes a stored subtitle's VTT and original files in
+// place. It returns whether they changed and the charset to record.
+func (s *SubtitleService) redecodeStored(sub *models.StoredSubtitle) (bool, string, error) {
+	vtt, err := ReadStoredVTT(sub)
+	if err != nil {
+		return false, "", err
+	}
+	fixedVTT, charset, changed := redecodeText(vtt, sub.Language)
+	if charset == "" {
+		charset = charsetUTF8
+	}
+	if !changed {
+		return false, charset, nil
+	}
+
+	if sub.VTTPath == "" {
+		vttPath, err := s.writeSubtitleFile(sub.ImdbCode, sub.ID, fixedVTT)
+		if err != nil {
+			return false, "", err
+		}
+		s.db.UpdateSubtitlePath(sub.ID, vttPath)
+	} else if err := os.WriteFile(sub.VTTPath, []byte(fixedVTT), 0644); err != nil {
+		return false, "", err
+	}
+
+	if sub.OriginalPath != "" {
+		if data, err := os.ReadFile(sub.OriginalPath); err == nil {
+			if fixedOrig, _, ok := redecodeText(string(data), sub.Language); ok {
+				if err := os.WriteFile(sub.OriginalPath, []byte(fixedOrig), 0644); err != nil {
+					return false, "", err
+				}
+			}
+		}
+	}
+	return true, charset, nil
+}
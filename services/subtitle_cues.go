@@ -0,0 +1,243 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"torrent-server/models"
+)
+
+// SubtitleCue is one timed line of a subtitle
+type SubtitleCue struct {
+	Index      int    `json:"index"` // 1-based position in the file
+	Identifier string `json:"identifier,omitempty"`
+	StartMs    int64  `json:"start_ms"`
+	EndMs      int64  `json:"end_ms"`
+	Settings   string `json:"settings,omitempty"` // VTT cue settings, e.g. "line:0"
+	Text       string `json:"text"`
+}
+
+// ParseCues reads the cues of a WebVTT or SRT document. Header blocks,
+// NOTE/STYLE blocks and malformed cues are skipped.
+func ParseCues(content string) []SubtitleCue {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	var cues []SubtitleCue
+	for _, block := range strings.Split(content, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		parts := strings.SplitN(lines[timing], "-->", 2)
+		start, ok1 := parseCueTime(parts[0])
+		endFields := strings.Fields(parts[1])
+		if len(endFields) == 0 {
+			continue
+		}
+		end, ok2 := parseCueTime(endFields[0])
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		cues = append(cues, SubtitleCue{
+			Index:      len(cues) + 1,
+			Identifier: strings.TrimSpace(strings.Join(lines[:timing], " ")),
+			StartMs:    start,
+			EndMs:      end,
+			Settings:   strings.Join(endFields[1:], " "),
+			Text:       strings.Join(lines[timing+1:], "\n"),
+		})
+	}
+	return cues
+}
+
+// parseCueTime parses "hh:mm:ss.mmm", "mm:ss.mmm" or the SRT "hh:mm:ss,mmm"
+// into milliseconds
+func parseCueTime(s string) (int64, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	clock, frac, _ := strings.Cut(s, ".")
+	fields := strings.Split(clock, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, false
+	}
+
+	var ms int64
+	for _, f := range fields {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		ms = ms*60 + n
+	}
+	ms *= 1000
+
+	if frac != "" {
+		// Pad or cut to milliseconds
+		frac = (frac + "00")[:3]
+		n, err := strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		ms += n
+	}
+	return ms, true
+}
+
+// formatCueTime formats milliseconds as hh:mm:ss.mmm, with sep before the
+// milliseconds ("." for VTT, "," for SRT)
+func formatCueTime(ms int64, sep string) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// FormatVTT writes cues as a WebVTT document
+func FormatVTT(cues []SubtitleCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		if c.Identifier != "" {
+			b.WriteString(c.Identifier)
+			b.WriteByte('\n')
+		}
+		b.WriteString(formatCueTime(c.StartMs, "."))
+		b.WriteString(" --> ")
+		b.WriteString(formatCueTime(c.EndMs, "."))
+		if c.Settings != "" {
+			b.WriteByte(' ')
+			b.WriteString(c.Settings)
+		}
+		b.WriteByte('\n')
+		b.WriteString(c.Text)
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// ReadStoredVTT returns a stored subtitle's VTT from disk, falling back to
+// the content kept in the database by older versions
+func ReadStoredVTT(sub *models.StoredSubtitle) (string, error) {
+	if sub.VTTPath == "" {
+		return sub.VTTContent, nil
+	}
+	data, err := os.ReadFile(sub.VTTPath)
+	if err != nil {
+		if sub.VTTContent != "" {
+			return sub.VTTContent, nil
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SubtitleCueEdit changes one cue, picked by its 1-based index. Nil fields
+// are kept; ShiftMs moves the cue by that many milliseconds.
+type SubtitleCueEdit struct {
+	Index   int     `json:"index"`
+	Text    *string `json:"text"`
+	StartMs *int64  `json:"start_ms"`
+	EndMs   *int64  `json:"end_ms"`
+	ShiftMs int64   `json:"shift_ms"`
+	Delete  bool    `json:"delete"`
+}
+
+// ApplyCueEdits applies edits to cues, which keep their original indexes
+// until all edits are done. Cues must be numbered 1..n as ParseCues returns
+// them. Cues are then put back in time order and renumbered.
+func ApplyCueEdits(cues []SubtitleCue, edits []SubtitleCueEdit) ([]SubtitleCue, error) {
+	edited := make([]SubtitleCue, len(cues))
+	copy(edited, cues)
+	deleted := make(map[int]bool)
+
+	for _, e := range edits {
+		if e.Index < 1 || e.Index > len(edited) {
+			return nil, fmt.Errorf("cue %d doesn't exist (subtitle has %d cues)", e.Index, len(edited))
+		}
+		if e.Delete {
+			deleted[e.Index] = true
+			continue
+		}
+		c := &edited[e.Index-1]
+		if e.Text != nil {
+			text := strings.TrimSpace(strings.ReplaceAll(*e.Text, "\r\n", "\n"))
+			if text == "" || strings.Contains(text, "\n\n") || strings.Contains(text, "-->") {
+				return nil, fmt.Errorf("cue %d: text must be non-empty, without blank lines or \"-->\"", e.Index)
+			}
+			c.Text = text
+		}
+		if e.StartMs != nil {
+			c.StartMs = *e.StartMs
+		}
+		if e.EndMs != nil {
+			c.EndMs = *e.EndMs
+		}
+		c.StartMs += e.ShiftMs
+		c.EndMs += e.ShiftMs
+		if c.StartMs < 0 || c.EndMs <= c.StartMs {
+			return nil, fmt.Errorf("cue %d: start must be at least 0 and before the end (got %dms --> %dms)", e.Index, c.StartMs, c.EndMs)
+		}
+	}
+
+	result := make([]SubtitleCue, 0, len(edited))
+	for _, c := range edited {
+		if !deleted[c.Index] {
+			result = append(result, c)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].StartMs < result[j].StartMs })
+	for i := range result {
+		// Numeric identifiers are SRT cue numbers; keep them in sequence
+		if result[i].Identifier == strconv.Itoa(result[i].Index) {
+			result[i].Identifier = strconv.Itoa(i + 1)
+		}
+		result[i].Index = i + 1
+	}
+	return result, nil
+}
+
+// EditStoredCues applies cue edits to a stored subtitle and rewrites its VTT.
+// The original file no longer matches, so it is dropped and other formats
+// are converted from the edited VTT.
+func (s *SubtitleService) EditStoredCues(sub *models.StoredSubtitle, edits []SubtitleCueEdit) ([]SubtitleCue, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("no database configured")
+	}
+	vtt, err := ReadStoredVTT(sub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitle: %w", err)
+	}
+	cues, err := ApplyCueEdits(ParseCues(vtt), edits)
+	if err != nil {
+		return nil, err
+	}
+
+	edited := FormatVTT(cues)
+	vttPath, err := s.writeSubtitleFile(sub.ImdbCode, sub.ID, edited)
+	if err != nil {
+		return nil, err
+	}
+	if vttPath != sub.VTTPath {
+		s.db.UpdateSubtitlePath(sub.ID, vttPath)
+		sub.VTTPath = vttPath
+	}
+	if sub.OriginalPath != "" {
+		os.Remove(sub.OriginalPath)
+		s.db.UpdateSubtitleOriginal(sub.ID, "", "")
+		sub.OriginalPath, sub.OriginalFormat = "", ""
+	}
+	s.rateStored(sub, edited)
+	return cues, nil
+}
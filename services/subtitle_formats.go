@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+
+	"torrent-server/models"
+)
+
+// Subtitle formats served by the stored subtitle endpoint
+const (
+	SubtitleFormatVTT  = "vtt"
+	SubtitleFormatSRT  = "srt"
+	SubtitleFormatASS  = "ass"
+	SubtitleFormatTTML = "ttml"
+)
+
+// subtitleContentTypes maps each served format to its Content-Type
+var subtitleContentTypes = map[string]string{
+	SubtitleFormatVTT:  "text/vtt; charset=utf-8",
+	SubtitleFormatSRT:  "application/x-subrip; charset=utf-8",
+	SubtitleFormatASS:  "text/x-ssa; charset=utf-8",
+	SubtitleFormatTTML: "application/ttml+xml; charset=utf-8",
+}
+
+// SubtitleContentType returns the Content-Type of a format, or "" if the
+// format isn't supported
+func SubtitleContentType(format string) string {
+	return subtitleContentTypes[format]
+}
+
+// DetectSubtitleFormat guesses the format of decoded subtitle text
+func DetectSubtitleFormat(content string) string {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	if strings.HasPrefix(trimmed, "WEBVTT") {
+		return SubtitleFormatVTT
+	}
+	if strings.Contains(content, "[Script Info]") || strings.Contains(content, "[V4+ Styles]") || strings.Contains(content, "[V4 Styles]") {
+		return SubtitleFormatASS
+	}
+	return SubtitleFormatSRT
+}
+
+// RenderStored returns a stored subtitle in the given format. The original
+// file is returned as-is when it is already in that format; otherwise the
+// stored VTT is converted.
+func RenderStored(sub *models.StoredSubtitle, format string) (string, error) {
+	if format == "" {
+		format = SubtitleFormatVTT
+	}
+	if SubtitleContentType(format) == "" {
+		return "", fmt.Errorf("unsupported subtitle format %q", format)
+	}
+
+	if sub.OriginalPath != "" && sub.OriginalFormat == format {
+		if data, err := os.ReadFile(sub.OriginalPath); err == nil {
+			return string(data), nil
+		}
+	}
+
+	vtt, err := ReadStoredVTT(sub)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case SubtitleFormatSRT:
+		return FormatSRT(ParseCues(vtt)), nil
+	case SubtitleFormatASS:
+		return FormatASS(ParseCues(vtt)), nil
+	case SubtitleFormatTTML:
+		return FormatTTML(ParseCues(vtt), sub.Language), nil
+	}
+	return vtt, nil
+}
+
+// cueTagRe matches the markup tags of a VTT cue: <i>, </b>, <c.yellow>,
+// <v Speaker>, <00:00:01.000>...
+var cueTagRe = regexp.MustCompile(`<[^<>]*>`)
+
+// convertCueText rewrites the markup of VTT cue text. tag receives the
+// lowercased tag name ("i", "c", ...) and whether it closes, and returns its
+// replacement; text receives the plain text between tags, with entities decoded.
+func convertCueText(s string, tag func(name string, closing bool) string, text func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range cueTagRe.FindAllStringIndex(s, -1) {
+		b.WriteString(text(html.UnescapeString(s[last:loc[0]])))
+		inner := strings.TrimSpace(s[loc[0]+1 : loc[1]-1])
+		closing := strings.HasPrefix(inner, "/")
+		name := strings.ToLower(strings.TrimPrefix(inner, "/"))
+		if i := strings.IndexAny(name, ". \t"); i >= 0 {
+			name = name[:i]
+		}
+		b.WriteString(tag(name, closing))
+		last = loc[1]
+	}
+	b.WriteString(text(html.UnescapeString(s[last:])))
+	return b.String()
+}
+
+// keepBasicTags keeps <i>, <b> and <u> and drops the other tags
+func keepBasicTags(name string, closing bool) string {
+	switch name {
+	case "i", "b", "u":
+		if closing {
+			return "</" + name + ">"
+		}
+		return "<" + name + ">"
+	}
+	return ""
+}
+
+// FormatSRT writes cues as a SubRip document, keeping italic, bold and
+// underline
+func FormatSRT(cues []SubtitleCue) string {
+	var b strings.Builder
+	for i, c := range cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n", i+1, formatCueTime(c.StartMs, ","), formatCueTime(c.EndMs, ","))
+		b.WriteString(convertCueText(c.Text, keepBasicTags, func(s string) string { return s }))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// assHeader is the script header of generated ASS files. The style matches a
+// plain white subtitle with a black outline.
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 384
+PlayResY: 288
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,16,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,1,0,2,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// FormatASS writes cues as an Advanced SubStation Alpha script. Italic, bold
+// and underline become override tags, and cues placed at the top of the
+// screen are aligned there.
+func FormatASS(cues []SubtitleCue) string {
+	var b strings.Builder
+	b.WriteString(assHeader)
+	for _, c := range cues {
+		text := convertCueText(c.Text, func(name string, closing bool) string {
+			switch name {
+			case "i", "b", "u":
+				if closing {
+					return `{\` + name + `0}`
+				}
+				return `{\` + name + `1}`
+			}
+			return ""
+		}, func(s string) string {
+			// Braces would start an override block
+			s = strings.NewReplacer("{", "(", "}", ")").Replace(s)
+			return strings.ReplaceAll(s, "\n", `\N`)
+		})
+		if cueAtTop(c.Settings) {
+			text = `{\an8}` + text
+		}
+		fmt.Fprintf(&b, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", formatASSTime(c.StartMs), formatASSTime(c.EndMs), text)
+	}
+	return b.String()
+}
+
+// formatASSTime formats milliseconds as h:mm:ss.cc
+func formatASSTime(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%d:%02d:%02d.%02d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000/10)
+}
+
+// cueAtTop reports whether VTT cue settings place the cue near the top of the
+// screen ("line:0", "line:10%"...)
+func cueAtTop(settings string) bool {
+	for _, s := range strings.Fields(settings) {
+		line, ok := strings.CutPrefix(s, "line:")
+		if !ok {
+			continue
+		}
+		line, _, _ = strings.Cut(line, ",")
+		if pct, ok := strings.CutSuffix(line, "%"); ok {
+			var n float64
+			fmt.Sscanf(pct, "%g", &n)
+			return n < 50
+		}
+		var n int
+		fmt.Sscanf(line, "%d", &n)
+		return n >= 0 && n < 3
+	}
+	return false
+}
+
+// ttmlSpans maps cue tags to TTML styling attributes
+var ttmlSpans = map[string]string{
+	"i": `tts:fontStyle="italic"`,
+	"b": `tts:fontWeight="bold"`,
+	"u": `tts:textDecoration="underline"`,
+}
+
+// FormatTTML writes cues as a TTML document. lang is the subtitle's language
+// code.
+func FormatTTML(cues []SubtitleCue, lang string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:tts="http://www.w3.org/ns/ttml#styling" xml:lang="%s">`+"\n", html.EscapeString(lang))
+	b.WriteString("  <body>\n    <div>\n")
+	for _, c := range cues {
+		// Spans are counted so unbalanced cue tags still give valid XML
+		open := 0
+		text := convertCueText(c.Text, func(name string, closing bool) string {
+			attr, ok := ttmlSpans[name]
+			switch {
+			case !ok:
+				return ""
+			case closing && open == 0:
+				return ""
+			case closing:
+				open--
+				return "</span>"
+			}
+			open++
+			return "<span " + attr + ">"
+		}, func(s string) string {
+			return strings.ReplaceAll(html.EscapeString(s), "\n", "<br/>")
+		})
+		text += strings.Repeat("</span>", open)
+		fmt.Fprintf(&b, `      <p begin="%s" end="%s">%s</p>`+"\n", formatCueTime(c.StartMs, "."), formatCueTime(c.EndMs, "."), text)
+	}
+	b.WriteString("    </div>\n  </body>\n</tt>\n")
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"torrent-server/models"
+)
+
+// defaultSyncLanguages are synced until an admin changes the language settings
+var defaultSyncLanguages = []string{"en", "sq", "es", "fr", "de", "it", "pt", "tr", "ar"}
+
+// defaultMaxSubtitles is how many subtitles per language the sync stores for
+// a movie or episode by default
+const defaultMaxSubtitles = 3
+
+// Hearing-impaired preferences of a sync language
+const (
+	HearingImpairedAny     = "any"     // take results in provider order
+	HearingImpairedPrefer  = "prefer"  // hearing-impaired subtitles first
+	HearingImpairedAvoid   = "avoid"   // hearing-impaired subtitles last
+	HearingImpairedExclude = "exclude" // never store hearing-impaired subtitles
+)
+
+var languageCodeRe = regexp.MustCompile(`^[a-z]{2}$`)
+
+// SubtitleLanguageUpdate changes a sync language's settings; nil fields are kept
+type SubtitleLanguageUpdate struct {
+	Enabled         *bool   `json:"enabled"`
+	MaxSubtitles    *int    `json:"max_subtitles"`
+	HearingImpaired *string `json:"hearing_impaired"`
+}
+
+// defaultLanguageSetting returns the built-in settings of a language
+func defaultLanguageSetting(code string) models.SubtitleLanguageSetting {
+	enabled := false
+	for _, c := range defaultSyncLanguages {
+		if c == code {
+			enabled = true
+		}
+	}
+	return models.SubtitleLanguageSetting{
+		Code:            code,
+		Enabled:         enabled,
+		MaxSubtitles:    defaultMaxSubtitles,
+		HearingImpaired: HearingImpairedAny,
+	}
+}
+
+// LanguageSettings returns the sync settings of every supported language and
+// of any other language an admin configured, sorted by code
+func (s *SubtitleService) LanguageSettings() []models.SubtitleLanguageSetting {
+	byCode := make(map[string]models.SubtitleLanguageSetting)
+	for _, l := range GetSubtitleLanguages() {
+		byCode[l.Code] = defaultLanguageSetting(l.Code)
+	}
+	if s.db != nil {
+		saved, err := s.db.ListSubtitleLanguageSettings()
+		if err != nil {
+			log.Printf("[SubtitleService] Failed to load language settings: %v", err)
+		}
+		for _, setting := range saved {
+			byCode[setting.Code] = setting
+		}
+	}
+
+	settings := make([]models.SubtitleLanguageSetting, 0, len(byCode))
+	for _, setting := range byCode {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Code < settings[j].Code })
+	return settings
+}
+
+// syncLanguageSettings returns the settings of the given comma-separated
+// languages, or of every enabled language when none are given
+func (s *SubtitleService) syncLanguageSettings(languages string) []models.SubtitleLanguageSetting {
+	all := s.LanguageSettings()
+	codes := SplitLanguages(languages)
+	if len(codes) == 0 {
+		var enabled []models.SubtitleLanguageSetting
+		for _, setting := range all {
+			if setting.Enabled {
+				enabled = append(enabled, setting)
+			}
+		}
+		return enabled
+	}
+
+	settings := make([]models.SubtitleLanguageSetting, 0, len(codes))
+	for _, code := range codes {
+		setting := defaultLanguageSetting(code)
+		for _, saved := range all {
+			if saved.Code == code {
+				setting = saved
+			}
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// UpdateLanguage changes a sync language's settings and persists them
+func (s *SubtitleService) UpdateLanguage(code string, u SubtitleLanguageUpdate) (*models.SubtitleLanguageSetting, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("no database configured")
+	}
+	code = strings.ToLower(strings.TrimSpace(code))
+	if !languageCodeRe.MatchString(code) {
+		return nil, fmt.Errorf("invalid language code %q: expected ISO 639-1", code)
+	}
+
+	setting := defaultLanguageSetting(code)
+	for _, saved := range s.LanguageSettings() {
+		if saved.Code == code {
+			setting = saved
+		}
+	}
+	if u.Enabled != nil {
+		setting.Enabled = *u.Enabled
+	}
+	if u.MaxSubtitles != nil {
+		setting.MaxSubtitles = max(*u.MaxSubtitles, 0)
+	}
+	if u.HearingImpaired != nil {
+		switch *u.HearingImpaired {
+		case HearingImpairedAny, HearingImpairedPrefer, HearingImpairedAvoid, HearingImpairedExclude:
+			setting.HearingImpaired = *u.HearingImpaired
+		default:
+			return nil, fmt.Errorf("hearing_impaired must be any, prefer, avoid or exclude")
+		}
+	}
+	setting.UpdatedAt = time.Now()
+
+	if err := s.db.SaveSubtitleLanguageSetting(&setting); err != nil {
+		return nil, err
+	}
+	log.Printf("[SubtitleService] Updated sync language %s: enabled=%v max=%d hi=%s", code, setting.Enabled, setting.MaxSubtitles, setting.HearingImpaired)
+	return &setting, nil
+}
+
+// applyHearingImpaired orders or filters results by a language's
+// hearing-impaired preference, keeping provider order otherwise
+func applyHearingImpaired(subs []Subtitle, pref string) []Subtitle {
+	switch pref {
+	case HearingImpairedPrefer:
+		sort.SliceStable(subs, func(i, j int) bool { return subs[i].HearingImpaired && !subs[j].HearingImpaired })
+	case HearingImpairedAvoid:
+		sort.SliceStable(subs, func(i, j int) bool { return !subs[i].HearingImpaired && subs[j].HearingImpaired })
+	case HearingImpairedExclude:
+		kept := subs[:0]
+		for _, sub := range subs {
+			if !sub.HearingImpaired {
+				kept = append(kept, sub)
+			}
+		}
+		subs = kept
+	}
+	return subs
+}
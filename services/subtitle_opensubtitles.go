@@ -0,0 +1,197 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const opensubtitlesRestURL = "https://rest.opensubtitles.org/search"
+
+// OpenSubtitlesProvider searches the legacy OpenSubtitles REST API, one
+// request per language. It supports exact matching by moviehash.
+type OpenSubtitlesProvider struct {
+	client *http.Client
+}
+
+func NewOpenSubtitlesProvider() *OpenSubtitlesProvider {
+	return &OpenSubtitlesProvider{client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (p *OpenSubtitlesProvider) Name() string { return "opensubtitles" }
+
+func (p *OpenSubtitlesProvider) Search(q SubtitleQuery) ([]Subtitle, error) {
+	if q.ImdbID == "" && q.MovieHash == nil {
+		return nil, errSubtitleQueryUnsupported
+	}
+
+	var all []Subtitle
+	var lastErr error
+	seen := make(map[string]bool)
+
+	// Exact matches by file hash first, then everything for the title
+	if q.MovieHash != nil {
+		base := fmt.Sprintf("%s/moviebytesize-%d/moviehash-%s", opensubtitlesRestURL, q.MovieHash.Size, q.MovieHash.Hash)
+		subs, err := p.searchLanguages(base, q.Languages)
+		if err != nil {
+			lastErr = err
+		}
+		for _, sub := range subs {
+			sub.HashMatch = true
+			seen[sub.ID] = true
+			all = append(all, sub)
+		}
+	}
+
+	if q.ImdbID != "" {
+		imdb := strings.TrimPrefix(q.ImdbID, "tt")
+		base := fmt.Sprintf("%s/imdbid-%s", opensubtitlesRestURL, imdb)
+		if q.isEpisode() {
+			base = fmt.Sprintf("%s/imdbid-%s/season-%d/episode-%d", opensubtitlesRestURL, imdb, q.Season, q.Episode)
+		}
+		subs, err := p.searchLanguages(base, q.Languages)
+		if err != nil {
+			lastErr = err
+		}
+		for _, sub := range subs {
+			if !seen[sub.ID] {
+				all = append(all, sub)
+			}
+		}
+	}
+
+	if len(all) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return all, nil
+}
+
+// searchLanguages runs a search once per language, or once for all
+// languages when none are given
+func (p *OpenSubtitlesProvider) searchLanguages(base string, languages []string) ([]Subtitle, error) {
+	if len(languages) == 0 {
+		return p.search(base)
+	}
+
+	var all []Subtitle
+	var lastErr error
+	for i, lang := range languages {
+		osLang := iso2ToOSLang(lang)
+		if osLang == "" {
+			continue
+		}
+		if i > 0 {
+			time.Sleep(200 * time.Millisecond) // rate limit
+		}
+		subs, err := p.search(base + "/sublanguageid-" + osLang)
+		if err != nil {
+			log.Printf("[SubtitleService] OpenSubtitles error for %s: %v", lang, err)
+			lastErr = err
+			continue
+		}
+		all = append(all, subs...)
+	}
+	if len(all) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return all, nil
+}
+
+func (p *OpenSubtitlesProvider) search(apiURL string) ([]Subtitle, error) {
+	log.Printf("[SubtitleService] Fetching: %s", apiURL)
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "OmniusServer v1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenSubtitles API error: %d", resp.StatusCode)
+	}
+
+	var data []openSubtitlesResult
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to parse subtitle response: %w", err)
+	}
+
+	subtitles := make([]Subtitle, 0, len(data))
+	for _, sub := range data {
+		var fps float64
+		fmt.Sscanf(sub.MovieFPS, "%f", &fps)
+		var dlCount int64
+		fmt.Sscanf(sub.SubDownloadsCnt, "%d", &dlCount)
+
+		subtitles = append(subtitles, Subtitle{
+			ID:              sub.IDSubtitleFile,
+			Language:        osLangToISO2(sub.SubLanguageID),
+			LanguageName:    sub.LanguageName,
+			DownloadURL:     sub.SubDownloadLink,
+			ReleaseName:     sub.MovieReleaseName,
+			Uploader:        sub.UserNickName,
+			DownloadCount:   dlCount,
+			HearingImpaired: sub.SubHearingImpaired == "1",
+			FPS:             fps,
+		})
+	}
+	return subtitles, nil
+}
+
+type openSubtitlesResult struct {
+	IDSubtitleFile     string `json:"IDSubtitleFile"`
+	SubLanguageID      string `json:"SubLanguageID"`
+	LanguageName       string `json:"LanguageName"`
+	SubDownloadLink    string `json:"SubDownloadLink"`
+	MovieReleaseName   string `json:"MovieReleaseName"`
+	UserNickName       string `json:"UserNickName"`
+	SubDownloadsCnt    string `json:"SubDownloadsCnt"`
+	SubHearingImpaired string `json:"SubHearingImpaired"`
+	MovieFPS           string `json:"MovieFPS"`
+}
+
+// osLangToISO2 converts OpenSubtitles 3-letter language codes to ISO 639-1 2-letter codes
+func osLangToISO2(code string) string {
+	m := map[string]string{
+		"eng": "en", "spa": "es", "fre": "fr", "ger": "de", "ita": "it",
+		"por": "pt", "rus": "ru", "chi": "zh", "jpn": "ja", "kor": "ko",
+		"ara": "ar", "dut": "nl", "pol": "pl", "tur": "tr", "swe": "sv",
+		"nor": "no", "dan": "da", "fin": "fi", "gre": "el", "heb": "he",
+		"hin": "hi", "tha": "th", "vie": "vi", "ind": "id", "cze": "cs",
+		"hun": "hu", "rum": "ro", "bul": "bg", "ukr": "uk", "hrv": "hr",
+		"srp": "sr", "slo": "sk", "slv": "sl", "alb": "sq", "per": "fa",
+		"may": "ms", "est": "et", "lav": "lv", "lit": "lt", "cat": "ca",
+		"bos": "bs", "mac": "mk", "ice": "is", "geo": "ka", "arm": "hy",
+	}
+	if iso2, ok := m[code]; ok {
+		return iso2
+	}
+	return code
+}
+
+// iso2ToOSLang converts ISO 639-1 2-letter codes to OpenSubtitles 3-letter codes
+func iso2ToOSLang(code string) string {
+	m := map[string]string{
+		"en": "eng", "es": "spa", "fr": "fre", "de": "ger", "it": "ita",
+		"pt": "por", "ru": "rus", "zh": "chi", "ja": "jpn", "ko": "kor",
+		"ar": "ara", "nl": "dut", "pl": "pol", "tr": "tur", "sv": "swe",
+		"no": "nor", "da": "dan", "fi": "fin", "el": "gre", "he": "heb",
+		"hi": "hin", "th": "tha", "vi": "vie", "id": "ind", "cs": "cze",
+		"hu": "hun", "ro": "rum", "bg": "bul", "uk": "ukr", "hr": "hrv",
+		"sr": "srp", "sk": "slo", "sl": "slv", "sq": "alb", "fa": "per",
+		"ms": "may", "et": "est", "lv": "lav", "lt": "lit", "ca": "cat",
+		"bs": "bos", "mk": "mac", "is": "ice", "ka": "geo", "hy": "arm",
+	}
+	if os3, ok := m[code]; ok {
+		return os3
+	}
+	return ""
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"torrent-server/models"
+)
+
+// SubtitleProvider is the interface for subtitle sources (SubDL,
+// OpenSubtitles, ...). Results carry ISO 639-1 language codes.
+type SubtitleProvider interface {
+	Name() string
+	Search(q SubtitleQuery) ([]Subtitle, error)
+}
+
+// SubtitleDownloader is implemented by providers whose results need more
+// than a plain GET of DownloadURL. It returns the raw (possibly zipped) file.
+type SubtitleDownloader interface {
+	Download(sub Subtitle) ([]byte, error)
+}
+
+// SubtitleKeyedProvider is implemented by providers that need an API key.
+// An empty key restores the one the provider was configured with at startup.
+type SubtitleKeyedProvider interface {
+	SetAPIKey(key string)
+	HasAPIKey() bool
+}
+
+// SubtitleQuery describes a search. Either ImdbID or FileName is set;
+// Season and Episode narrow an IMDb search to one episode. Providers that
+// support it also look up exact matches for MovieHash.
+type SubtitleQuery struct {
+	ImdbID    string   // with the "tt" prefix
+	FileName  string   // release file name
+	Languages []string // ISO 639-1 codes; empty matches any language
+	Season    int
+	Episode   int
+	MovieHash *MovieHash
+}
+
+func (q SubtitleQuery) isEpisode() bool {
+	return q.Season > 0 && q.Episode > 0
+}
+
+// errSubtitleQueryUnsupported is returned by providers that can't run a kind
+// of query (e.g. filename search). It isn't counted as a failure.
+var errSubtitleQueryUnsupported = errors.New("query not supported by provider")
+
+// errSubtitleProviderNotConfigured is returned by providers missing an API key
+var errSubtitleProviderNotConfigured = errors.New("provider has no API key")
+
+// defaultSubtitleProviders are registered on every SubtitleService with the
+// settings they start with until an admin changes them
+func defaultSubtitleProviders() []registeredSubtitleProvider {
+	return []registeredSubtitleProvider{
+		{
+			provider: NewOpenSubtitlesProvider(),
+			// OpenSubtitles blocks server IPs for downloads, so it is only
+			// used for live search; apps download directly
+			settings: models.SubtitleProviderSetting{Enabled: true, Priority: 10, MovieLimit: 3, EpisodeLimit: 2, RequestsPerMinute: 40},
+		},
+		{
+			provider: NewSubDLProvider(),
+			settings: models.SubtitleProviderSetting{Enabled: true, Priority: 20, UseForSync: true, MovieLimit: 3, EpisodeLimit: 2, RequestsPerMinute: 60},
+		},
+	}
+}
+
+type registeredSubtitleProvider struct {
+	provider SubtitleProvider
+	settings models.SubtitleProviderSetting
+}
+
+// SubtitleProviderMetrics counts a provider's requests since startup
+type SubtitleProviderMetrics struct {
+	Searches       int64      `json:"searches"`
+	SearchErrors   int64      `json:"search_errors"`
+	Results        int64      `json:"results"`
+	Downloads      int64      `json:"downloads"`
+	DownloadErrors int64      `json:"download_errors"`
+	SuccessRate    float64    `json:"success_rate"` // successful searches and downloads / all
+	AvgLatencyMs   int64      `json:"avg_latency_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt  *time.Time `json:"last_success_at,omitempty"`
+
+	totalLatency time.Duration
+}
+
+// SubtitleProviderStatus is the admin view of a provider
+type SubtitleProviderStatus struct {
+	models.SubtitleProviderSetting
+	NeedsAPIKey bool                    `json:"needs_api_key"`
+	HasAPIKey   bool                    `json:"has_api_key"`
+	Metrics     SubtitleProviderMetrics `json:"metrics"`
+}
+
+// SubtitleProviderUpdate changes a provider's settings; nil fields are kept.
+// An empty APIKey restores the key the provider started with.
+type SubtitleProviderUpdate struct {
+	Enabled           *bool   `json:"enabled"`
+	Priority          *int    `json:"priority"`
+	APIKey            *string `json:"api_key"`
+	UseForSync        *bool   `json:"use_for_sync"`
+	MovieLimit        *int    `json:"movie_limit"`
+	EpisodeLimit      *int    `json:"episode_limit"`
+	RequestsPerMinute *int    `json:"requests_per_minute"`
+}
+
+// subtitleProviders holds the registered providers with their settings and metrics
+type subtitleProviders struct {
+	mu      sync.RWMutex
+	entries map[string]*subtitleProviderEntry
+}
+
+type subtitleProviderEntry struct {
+	provider SubtitleProvider
+	settings models.SubtitleProviderSetting
+	metrics  SubtitleProviderMetrics
+
+	nextRequest time.Time // earliest next sync request under the rate limit
+}
+
+func newSubtitleProviders() *subtitleProviders {
+	return &subtitleProviders{entries: make(map[string]*subtitleProviderEntry)}
+}
+
+// RegisterProvider adds a subtitle source. Settings saved by an admin take
+// precedence over the given defaults.
+func (s *SubtitleService) RegisterProvider(p SubtitleProvider, defaults models.SubtitleProviderSetting) {
+	defaults.Name = p.Name()
+	s.providers.mu.Lock()
+	defer s.providers.mu.Unlock()
+	s.providers.entries[p.Name()] = &subtitleProviderEntry{provider: p, settings: defaults}
+}
+
+// loadProviderSettings applies settings saved by admins to the registered providers
+func (s *SubtitleService) loadProviderSettings() {
+	if s.db == nil {
+		return
+	}
+	saved, err := s.db.ListSubtitleProviderSettings()
+	if err != nil {
+		log.Printf("[SubtitleService] Failed to load provider settings: %v", err)
+		return
+	}
+	s.providers.mu.Lock()
+	defer s.providers.mu.Unlock()
+	for _, setting := range saved {
+		entry, ok := s.providers.entries[setting.Name]
+		if !ok {
+			continue
+		}
+		entry.settings = setting
+		if keyed, ok := entry.provider.(SubtitleKeyedProvider); ok && setting.APIKey != "" {
+			keyed.SetAPIKey(setting.APIKey)
+		}
+	}
+}
+
+// WarnMissingAPIKeys logs the sync providers that have no API key. Searches
+// skip them without recording an error, so without the warning the subtitle
+// sync would quietly store nothing.
+func (s *SubtitleService) WarnMissingAPIKeys() {
+	for _, entry := range s.activeProviders(true) {
+		keyed, ok := entry.provider.(SubtitleKeyedProvider)
+		if !ok || keyed.HasAPIKey() {
+			continue
+		}
+		hint := "add one through /admin/api/subtitles/providers"
+		if env, ok := entry.provider.(interface{ APIKeyEnv() string }); ok {
+			hint = "set " + env.APIKeyEnv() + " or " + hint
+		}
+		log.Printf("[SubtitleService] Warning: %s is used for subtitle sync but has no API key; %s", entry.provider.Name(), hint)
+	}
+}
+
+// activeProviders returns the enabled providers in priority order. With
+// forSync, only providers used by the subtitle sync are returned.
+func (s *SubtitleService) activeProviders(forSync bool) []*subtitleProviderEntry {
+	s.providers.mu.RLock()
+	defer s.providers.mu.RUnlock()
+	var active []*subtitleProviderEntry
+	for _, entry := range s.providers.entries {
+		if !entry.settings.Enabled || (forSync && !entry.settings.UseForSync) {
+			continue
+		}
+		active = append(active, entry)
+	}
+	sortProviderEntries(active)
+	return active
+}
+
+func sortProviderEntries(entries []*subtitleProviderEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].settings.Priority != entries[j].settings.Priority {
+			return entries[i].settings.Priority < entries[j].settings.Priority
+		}
+		return entries[i].settings.Name < entries[j].settings.Name
+	})
+}
+
+// ProviderStatuses returns every registered provider with its settings and metrics
+func (s *SubtitleService) ProviderStatuses() []SubtitleProviderStatus {
+	s.providers.mu.RLock()
+	entries := make([]*subtitleProviderEntry, 0, len(s.providers.entries))
+	for _, entry := range s.providers.entries {
+		entries = append(entries, entry)
+	}
+	sortProviderEntries(entries)
+
+	statuses := make([]SubtitleProviderStatus, 0, len(entries))
+	for _, entry := range entries {
+		status := SubtitleProviderStatus{SubtitleProviderSetting: entry.settings, Metrics: entry.metrics}
+		if keyed, ok := entry.provider.(SubtitleKeyedProvider); ok {
+			status.NeedsAPIKey = true
+			status.HasAPIKey = keyed.HasAPIKey()
+		}
+		statuses = append(statuses, status)
+	}
+	s.providers.mu.RUnlock()
+	return statuses
+}
+
+// UpdateProvider changes a provider's settings and persists them
+func (s *SubtitleService) UpdateProvider(name string, u SubtitleProviderUpdate) (*SubtitleProviderStatus, error) {
+	s.providers.mu.Lock()
+	entry, ok := s.providers.entries[name]
+	if !ok {
+		s.providers.mu.Unlock()
+		return nil, fmt.Errorf("unknown subtitle provider %q", name)
+	}
+	settings := entry.settings
+	if u.Enabled != nil {
+		settings.Enabled = *u.Enabled
+	}
+	if u.Priority != nil {
+		settings.Priority = *u.Priority
+	}
+	if u.UseForSync != nil {
+		settings.UseForSync = *u.UseForSync
+	}
+	if u.MovieLimit != nil {
+		settings.MovieLimit = max(*u.MovieLimit, 0)
+	}
+	if u.EpisodeLimit != nil {
+		settings.EpisodeLimit = max(*u.EpisodeLimit, 0)
+	}
+	if u.RequestsPerMinute != nil {
+		settings.RequestsPerMinute = max(*u.RequestsPerMinute, 0)
+	}
+	if u.APIKey != nil {
+		keyed, ok := entry.provider.(SubtitleKeyedProvider)
+		if !ok {
+			s.providers.mu.Unlock()
+			return nil, fmt.Errorf("subtitle provider %q doesn't use an API key", name)
+		}
+		settings.APIKey = *u.APIKey
+		keyed.SetAPIKey(settings.APIKey)
+	}
+	settings.UpdatedAt = time.Now()
+	entry.settings = settings
+	s.providers.mu.Unlock()
+
+	if s.db != nil {
+		if err := s.db.SaveSubtitleProviderSetting(&settings); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("[SubtitleService] Updated provider %s: enabled=%v priority=%d sync=%v", name, settings.Enabled, settings.Priority, settings.UseForSync)
+
+	for _, status := range s.ProviderStatuses() {
+		if status.Name == name {
+			return &status, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown subtitle provider %q", name)
+}
+
+// waitForProvider blocks until the provider's rate limit allows another sync
+// request. Live searches from apps aren't paced.
+func (s *SubtitleService) waitForProvider(ctx context.Context, entry *subtitleProviderEntry) error {
+	s.providers.mu.Lock()
+	rpm := entry.settings.RequestsPerMinute
+	if rpm <= 0 {
+		s.providers.mu.Unlock()
+		return ctx.Err()
+	}
+	at := entry.nextRequest
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	entry.nextRequest = at.Add(time.Minute / time.Duration(rpm))
+	s.providers.mu.Unlock()
+	return sleepCtx(ctx, time.Until(at))
+}
+
+// searchProvider runs a query against one provider, recording its metrics
+func (s *SubtitleService) searchProvider(entry *subtitleProviderEntry, q SubtitleQuery) ([]Subtitle, error) {
+	start := time.Now()
+	subs, err := entry.provider.Search(q)
+	if errors.Is(err, errSubtitleQueryUnsupported) || errors.Is(err, errSubtitleProviderNotConfigured) {
+		return nil, err
+	}
+
+	s.providers.mu.Lock()
+	defer s.providers.mu.Unlock()
+	m := &entry.metrics
+	m.Searches++
+	m.totalLatency += time.Since(start)
+	m.AvgLatencyMs = (m.totalLatency / time.Duration(m.Searches)).Milliseconds()
+	if err != nil {
+		m.SearchErrors++
+		m.recordError(err)
+	} else {
+		m.Results += int64(len(subs))
+		m.recordSuccess()
+	}
+	return subs, err
+}
+
+// downloadFromProvider downloads a result through its provider, recording
+// its metrics, and returns it decoded in its original format with the
+// charset it was in
+func (s *SubtitleService) downloadFromProvider(entry *subtitleProviderEntry, sub Subtitle) (source, charset string, err error) {
+	if dl, ok := entry.provider.(SubtitleDownloader); ok {
+		var data []byte
+		if data, err = dl.Download(sub); err == nil {
+			source, charset, err = decodeSubtitle(data, sub.Language)
+		}
+	} else {
+		source, charset, err = s.downloadSubtitleSource(sub.DownloadURL, sub.Language)
+	}
+
+	s.providers.mu.Lock()
+	defer s.providers.mu.Unlock()
+	m := &entry.metrics
+	m.Downloads++
+	if err != nil {
+		m.DownloadErrors++
+		m.recordError(err)
+	} else {
+		m.recordSuccess()
+	}
+	return source, charset, err
+}
+
+func (m *SubtitleProviderMetrics) recordError(err error) {
+	now := time.Now()
+	m.LastError = err.Error()
+	m.LastErrorAt = &now
+	m.updateSuccessRate()
+}
+
+func (m *SubtitleProviderMetrics) recordSuccess() {
+	now := time.Now()
+	m.LastSuccessAt = &now
+	m.updateSuccessRate()
+}
+
+func (m *SubtitleProviderMetrics) updateSuccessRate() {
+	total := m.Searches + m.Downloads
+	if total == 0 {
+		return
+	}
+	m.SuccessRate = float64(total-m.SearchErrors-m.DownloadErrors) / float64(total)
+}
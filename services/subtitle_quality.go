@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"torrent-server/models"
+)
+
+// errDuplicateSubtitle is returned when a new subtitle has the same content
+// as a stored one that scores at least as well; the new one is removed
+var errDuplicateSubtitle = errors.New("duplicate of a stored subtitle")
+
+// Points of each part of the quality score, adding up to 100
+const (
+	scoreCuePoints      = 25 // enough cues for the runtime
+	scoreCoveragePoints = 30 // last cue close to the end of the runtime
+	scoreEncodingPoints = 20 // no replacement characters or mojibake
+	scoreReleasePoints  = 25 // release name matches a torrent or local file
+)
+
+// cuesPerMinute is the cue density below which subtitles lose points;
+// dialogue-heavy films have twice as many
+const cuesPerMinute = 5
+
+// subtitleQuality is the quality score of a subtitle and how it was made up
+type subtitleQuality struct {
+	score        int
+	cues         int
+	coverage     float64 // last cue end / runtime; 0 if the runtime is unknown
+	badChars     int     // replacement, control and mojibake characters
+	releaseMatch float64 // 0-1, best token overlap with our releases
+}
+
+// scoreSubtitle rates VTT content on cue count and coverage relative to the
+// runtime in minutes, encoding sanity, and how well releaseName matches the
+// releases we have (hints). Broken files without cues score 0.
+func scoreSubtitle(vtt string, runtime int, releaseName string, hints []string) subtitleQuality {
+	cues := ParseCues(vtt)
+	q := subtitleQuality{cues: len(cues)}
+	if len(cues) == 0 {
+		return q
+	}
+
+	expected := 400
+	if runtime > 0 {
+		expected = runtime * cuesPerMinute
+	}
+	cuePoints := float64(scoreCuePoints) * min(1, float64(len(cues))/float64(expected))
+
+	// Without a runtime, coverage can't be judged either way
+	coveragePoints := float64(scoreCoveragePoints) / 2
+	if runtime > 0 {
+		var end int64
+		for _, c := range cues {
+			end = max(end, c.EndMs)
+		}
+		q.coverage = float64(end) / float64(runtime*60000)
+		switch {
+		case q.coverage < 0.8:
+			// Cut short, or for a shorter cut of the film
+			coveragePoints = scoreCoveragePoints * q.coverage / 0.8
+		case q.coverage > 1.05:
+			// Running past the end: another cut or framerate
+			coveragePoints = scoreCoveragePoints * max(0, 1-(q.coverage-1.05)*2)
+		default:
+			coveragePoints = scoreCoveragePoints
+		}
+	}
+
+	letters := 0
+	for _, c := range cues {
+		n, bad := countBadChars(c.Text)
+		letters += n
+		q.badChars += bad
+	}
+	encodingPoints := float64(scoreEncodingPoints)
+	if letters > 0 {
+		// 2% bad characters is unreadable
+		encodingPoints *= max(0, 1-float64(q.badChars)/float64(letters)*50)
+	}
+
+	q.releaseMatch = releaseMatch(releaseName, hints)
+	releasePoints := scoreReleasePoints * q.releaseMatch
+
+	q.score = int(cuePoints + coveragePoints + encodingPoints + releasePoints + 0.5)
+	return q
+}
+
+// mojibakeLeads are the characters UTF-8 lead bytes turn into when read as
+// Windows-1252; followed by a continuation character they mark mojibake
+const mojibakeLeads = "ÃÂÅÄÐÑØÙ"
+
+// countBadChars counts the letters of cue text and the characters that show
+// it was decoded wrongly
+func countBadChars(text string) (letters, bad int) {
+	var prev rune
+	for _, r := range text {
+		switch {
+		case r == '\uFFFD':
+			bad++
+		case unicode.IsControl(r) && r != '\n' && r != '\t':
+			bad++
+		case strings.ContainsRune(mojibakeLeads, prev) && isContinuationRune(r):
+			bad++
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+		prev = r
+	}
+	return letters, bad
+}
+
+// isContinuationRune reports whether r is what a UTF-8 continuation byte
+// (0x80-0xBF) decodes to in Windows-1252
+func isContinuationRune(r rune) bool {
+	if r >= 0xA0 && r <= 0xBF {
+		return true
+	}
+	for b := 0x80; b < 0xA0; b++ {
+		if windows1252ToRune(byte(b)) == r {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseTokenRe splits release names into tokens
+var releaseTokenRe = regexp.MustCompile(`[a-z0-9]+`)
+
+// releaseSynonyms maps release name tokens to one spelling per source and codec
+var releaseSynonyms = map[string]string{
+	"webrip": "web", "webdl": "web", "amzn": "web", "nf": "web",
+	"bluray": "bluray", "brrip": "bluray", "bdrip": "bluray", "bdremux": "bluray", "remux": "bluray",
+	"h264": "x264", "avc": "x264",
+	"h265": "x265", "hevc": "x265",
+	"hdrip": "hdtv",
+}
+
+func releaseTokens(name string) map[string]bool {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("web-dl", "webdl", "blu-ray", "bluray", "h.264", "h264", "h.265", "h265").Replace(name)
+	tokens := make(map[string]bool)
+	for _, t := range releaseTokenRe.FindAllString(name, -1) {
+		if s, ok := releaseSynonyms[t]; ok {
+			t = s
+		}
+		tokens[t] = true
+	}
+	return tokens
+}
+
+// releaseMatch returns the best share of a release hint's tokens found in the
+// subtitle's release name
+func releaseMatch(releaseName string, hints []string) float64 {
+	sub := releaseTokens(releaseName)
+	best := 0.0
+	for _, h := range hints {
+		tokens := releaseTokens(h)
+		if len(tokens) == 0 {
+			continue
+		}
+		matched := 0
+		for t := range tokens {
+			if sub[t] {
+				matched++
+			}
+		}
+		best = max(best, float64(matched)/float64(len(tokens)))
+	}
+	return best
+}
+
+// subtitleFingerprint hashes the text and timing of the cues, ignoring markup,
+// case, punctuation and timing differences under half a second, so re-encoded
+// or re-uploaded copies of the same file match
+func subtitleFingerprint(vtt string) string {
+	cues := ParseCues(vtt)
+	if len(cues) == 0 {
+		return ""
+	}
+	h := sha1.New()
+	for _, c := range cues {
+		plain := convertCueText(c.Text, func(string, bool) string { return "" }, func(s string) string { return s })
+		var b strings.Builder
+		for _, r := range strings.ToLower(plain) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(r)
+			}
+		}
+		fmt.Fprintf(h, "%d|%s\n", (c.StartMs+500)/1000, b.String())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (s *SubtitleService) scoreVTT(sub *models.StoredSubtitle, vtt string) subtitleQuality {
+	runtime := s.db.GetSubtitleRuntime(sub.ImdbCode, sub.SeasonNumber, sub.EpisodeNumber)
+	hints, err := s.db.ListSubtitleReleaseHints(sub.ImdbCode, sub.SeasonNumber, sub.EpisodeNumber)
+	if err != nil {
+		log.Printf("[subtitleQuality] Failed to list releases for %s: %v", sub.ImdbCode, err)
+	}
+	return scoreSubtitle(vtt, runtime, sub.ReleaseName, hints)
+}
+
+// rateStored records the score and fingerprint of a stored subtitle's VTT
+func (s *SubtitleService) rateStored(sub *models.StoredSubtitle, vtt string) {
+	sub.Score = s.scoreVTT(sub, vtt).score
+	sub.Fingerprint = subtitleFingerprint(vtt)
+	if err := s.db.UpdateSubtitleScore(sub.ID, sub.Score, sub.Fingerprint); err != nil {
+		log.Printf("[subtitleQuality] Failed to save score of subtitle %d: %v", sub.ID, err)
+	}
+}
+
+// collapseDuplicate keeps the better of a subtitle and a stored one with the
+// same fingerprint, removing the other. It returns errDuplicateSubtitle when
+// sub was the one removed.
+func (s *SubtitleService) collapseDuplicate(sub *models.StoredSubtitle) error {
+	dup, err := s.db.FindSubtitleDuplicate(sub)
+	if err != nil || dup == nil {
+		return err
+	}
+	if dup.Score >= sub.Score {
+		s.removeStored(sub)
+		log.Printf("[subtitleQuality] Dropped subtitle %d (%s), duplicate of %d", sub.ID, sub.ReleaseName, dup.ID)
+		return fmt.Errorf("%w #%d", errDuplicateSubtitle, dup.ID)
+	}
+	s.removeStored(dup)
+	log.Printf("[subtitleQuality] Dropped subtitle %d (%s), duplicate of better-scored %d", dup.ID, dup.ReleaseName, sub.ID)
+	return nil
+}
+
+// removeStored deletes a stored subtitle and its files
+func (s *SubtitleService) removeStored(sub *models.StoredSubtitle) {
+	if sub.VTTPath != "" {
+		os.Remove(sub.VTTPath)
+	}
+	if sub.OriginalPath != "" {
+		os.Remove(sub.OriginalPath)
+	}
+	s.db.DeleteSubtitle(sub.ID)
+}
+
+// ScoreStoredSubtitles rescores every stored subtitle, since scores depend on
+// the torrents and runtime known at the time, and collapses duplicates.
+// Subtitles stored before scoring get their score and fingerprint here.
+func (s *SubtitleService) ScoreStoredSubtitles(ctx context.Context, p *JobProgress) error {
+	if s.db == nil {
+		return fmt.Errorf("no database configured")
+	}
+	ids, err := s.db.ListSubtitleIDs()
+	if err != nil {
+		return err
+	}
+	p.SetTotal(len(ids))
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Re-read: the subtitle may have been dropped as an earlier one's duplicate
+		sub, err := s.db.GetSubtitleByID(id)
+		if err != nil {
+			p.Add(1, 0, 0)
+			continue
+		}
+		p.SetCurrent(fmt.Sprintf("%s %s #%d", sub.ImdbCode, sub.Language, sub.ID))
+
+		vtt, err := ReadStoredVTT(sub)
+		if err != nil {
+			log.Printf("[subtitleQuality] Failed to read subtitle %d: %v", sub.ID, err)
+			p.Add(1, 0, 1)
+			continue
+		}
+		s.rateStored(sub, vtt)
+		if err := s.collapseDuplicate(sub); err != nil && !errors.Is(err, errDuplicateSubtitle) {
+			log.Printf("[subtitleQuality] Failed to check duplicates of subtitle %d: %v", sub.ID, err)
+		}
+		p.Add(1, 0, 0)
+	}
+	return nil
+}
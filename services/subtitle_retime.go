@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"torrent-server/models"
+)
+
+// SubtitleSyncPoint pairs a cue time in the subtitle with the time it should
+// appear at in the video
+type SubtitleSyncPoint struct {
+	FromMs int64 `json:"from_ms"`
+	ToMs   int64 `json:"to_ms"`
+}
+
+// RetimeOptions describes a timing correction. Framerate conversion is
+// applied first, then the constant offset. Two sync points replace both with
+// the linear fit through them.
+type RetimeOptions struct {
+	OffsetMs int64               `json:"offset_ms"`
+	FromFPS  float64             `json:"from_fps"` // framerate the subtitle was timed for
+	ToFPS    float64             `json:"to_fps"`   // framerate of the video
+	Points   []SubtitleSyncPoint `json:"points"`
+}
+
+// linear returns the scale and offset that map old cue times to new ones
+func (o RetimeOptions) linear() (scale, offset float64, err error) {
+	if len(o.Points) > 0 {
+		if len(o.Points) != 2 {
+			return 0, 0, fmt.Errorf("a linear fit needs exactly two sync points")
+		}
+		p1, p2 := o.Points[0], o.Points[1]
+		if p1.FromMs == p2.FromMs {
+			return 0, 0, fmt.Errorf("sync points must be at different times")
+		}
+		scale = float64(p2.ToMs-p1.ToMs) / float64(p2.FromMs-p1.FromMs)
+		if scale <= 0 {
+			return 0, 0, fmt.Errorf("sync points must keep the cues in order")
+		}
+		return scale, float64(p1.ToMs) - scale*float64(p1.FromMs), nil
+	}
+
+	scale = 1
+	if o.FromFPS != 0 || o.ToFPS != 0 {
+		if o.FromFPS <= 0 || o.ToFPS <= 0 {
+			return 0, 0, fmt.Errorf("from_fps and to_fps must both be positive")
+		}
+		scale = o.FromFPS / o.ToFPS
+	}
+	if scale == 1 && o.OffsetMs == 0 {
+		return 0, 0, fmt.Errorf("no correction given: set offset_ms, from_fps/to_fps or two points")
+	}
+	return scale, float64(o.OffsetMs), nil
+}
+
+// RetimeCues applies a timing correction to cues. Cues pushed entirely before
+// zero are dropped.
+func RetimeCues(cues []SubtitleCue, opts RetimeOptions) ([]SubtitleCue, error) {
+	scale, offset, err := opts.linear()
+	if err != nil {
+		return nil, err
+	}
+
+	retimed := make([]SubtitleCue, 0, len(cues))
+	for _, c := range cues {
+		c.StartMs = int64(math.Round(float64(c.StartMs)*scale + offset))
+		c.EndMs = int64(math.Round(float64(c.EndMs)*scale + offset))
+		if c.EndMs <= 0 {
+			continue
+		}
+		if c.StartMs < 0 {
+			c.StartMs = 0
+		}
+		c.Index = len(retimed) + 1
+		retimed = append(retimed, c)
+	}
+	return retimed, nil
+}
+
+// RetimeStored returns a stored subtitle's VTT with a timing correction applied
+func (s *SubtitleService) RetimeStored(sub *models.StoredSubtitle, opts RetimeOptions) (string, error) {
+	vtt, err := ReadStoredVTT(sub)
+	if err != nil {
+		return "", fmt.Errorf("failed to read subtitle: %w", err)
+	}
+	cues, err := RetimeCues(ParseCues(vtt), opts)
+	if err != nil {
+		return "", err
+	}
+	return FormatVTT(cues), nil
+}
+
+// SaveRetimed stores a corrected copy of sub under releaseName, so it is
+// listed for everyone watching that release
+func (s *SubtitleService) SaveRetimed(sub *models.StoredSubtitle, vtt, releaseName string) (*models.StoredSubtitle, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("no database configured")
+	}
+	releaseName = strings.TrimSpace(releaseName)
+	if releaseName == "" {
+		return nil, fmt.Errorf("release_name is required to save a retimed subtitle")
+	}
+	// Only names of releases we have, so a retimed copy can't claim a
+	// release ahead of its real subtitles
+	hints, err := s.db.ListSubtitleReleaseHints(sub.ImdbCode, sub.SeasonNumber, sub.EpisodeNumber)
+	if err != nil {
+		return nil, err
+	}
+	if releaseMatch(releaseName, hints) < 1 {
+		return nil, fmt.Errorf("release_name %q does not match a torrent or local file of this title", releaseName)
+	}
+
+	retimed := &models.StoredSubtitle{
+		ImdbCode:        sub.ImdbCode,
+		Language:        sub.Language,
+		LanguageName:    sub.LanguageName,
+		ReleaseName:     releaseName,
+		HearingImpaired: sub.HearingImpaired,
+		Source:          "retimed",
+		Charset:         charsetUTF8,
+		SeasonNumber:    sub.SeasonNumber,
+		EpisodeNumber:   sub.EpisodeNumber,
+	}
+	if err := s.db.CreateSubtitle(retimed); err != nil {
+		return nil, err
+	}
+	if retimed.ID == 0 {
+		return nil, fmt.Errorf("%s subtitle for %q already exists", sub.Language, releaseName)
+	}
+
+	vttPath, err := s.writeSubtitleFile(sub.ImdbCode, retimed.ID, vtt)
+	if err != nil {
+		s.db.DeleteSubtitle(retimed.ID)
+		return nil, err
+	}
+	s.db.UpdateSubtitlePath(retimed.ID, vttPath)
+	retimed.VTTPath = vttPath
+	s.rateStored(retimed, vtt)
+	return retimed, nil
+}
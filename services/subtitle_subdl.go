@@ -0,0 +1,171 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const subdlAPIURL = "https://api.subdl.com/api/v1/subtitles"
+
+// SubDLProvider searches api.subdl.com. It needs an API key, read from
+// SUBDL_API_KEY or set by an admin.
+type SubDLProvider struct {
+	client *http.Client
+	envKey string
+	mu     sync.RWMutex
+	apiKey string
+}
+
+func NewSubDLProvider() *SubDLProvider {
+	key := os.Getenv("SUBDL_API_KEY")
+	return &SubDLProvider{
+		client: &http.Client{Timeout: 15 * time.Second},
+		envKey: key,
+		apiKey: key,
+	}
+}
+
+func (p *SubDLProvider) Name() string { return "subdl" }
+
+// APIKeyEnv names the environment variable the key is read from
+func (p *SubDLProvider) APIKeyEnv() string { return "SUBDL_API_KEY" }
+
+func (p *SubDLProvider) SetAPIKey(key string) {
+	if key == "" {
+		key = p.envKey
+	}
+	p.mu.Lock()
+	p.apiKey = key
+	p.mu.Unlock()
+}
+
+func (p *SubDLProvider) HasAPIKey() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.apiKey != ""
+}
+
+func (p *SubDLProvider) Search(q SubtitleQuery) ([]Subtitle, error) {
+	p.mu.RLock()
+	key := p.apiKey
+	p.mu.RUnlock()
+	if key == "" {
+		return nil, errSubtitleProviderNotConfigured
+	}
+
+	params := url.Values{}
+	params.Set("api_key", key)
+	switch {
+	case q.FileName != "":
+		params.Set("file_name", q.FileName)
+	case q.ImdbID != "":
+		params.Set("imdb_id", q.ImdbID)
+		if q.isEpisode() {
+			params.Set("season_number", fmt.Sprint(q.Season))
+			params.Set("episode_number", fmt.Sprint(q.Episode))
+		}
+	default:
+		return nil, errSubtitleQueryUnsupported
+	}
+	if len(q.Languages) > 0 {
+		params.Set("languages", strings.ToUpper(strings.Join(q.Languages, ",")))
+	}
+
+	req, err := http.NewRequest("GET", subdlAPIURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "OmniusServer v1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SubDL API error: %d", resp.StatusCode)
+	}
+
+	return parseSubDLResponse(resp.Body)
+}
+
+func parseSubDLResponse(body io.Reader) ([]Subtitle, error) {
+	var data subDLResponse
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to parse SubDL response: %w", err)
+	}
+
+	if !data.Status {
+		return []Subtitle{}, nil
+	}
+
+	subtitles := make([]Subtitle, 0, len(data.Subtitles))
+	for _, sub := range data.Subtitles {
+		langCode := subdlLangToISO2(strings.ToLower(sub.Language))
+		langName := sub.Lang
+		if langName == "" {
+			langName = sub.Language
+		}
+		subtitles = append(subtitles, Subtitle{
+			ID:              sub.URL,
+			Language:        langCode,
+			LanguageName:    langName,
+			DownloadURL:     "https://dl.subdl.com" + sub.URL,
+			ReleaseName:     sub.ReleaseName,
+			Uploader:        sub.Author,
+			DownloadCount:   0,
+			HearingImpaired: sub.HI,
+		})
+	}
+	return subtitles, nil
+}
+
+type subDLResponse struct {
+	Status    bool            `json:"status"`
+	Subtitles []subDLSubtitle `json:"subtitles"`
+}
+
+type subDLSubtitle struct {
+	ReleaseName string `json:"release_name"`
+	Lang        string `json:"lang"`
+	Language    string `json:"language"`
+	URL         string `json:"url"`
+	HI          bool   `json:"hi"`
+	Author      string `json:"author"`
+}
+
+// subdlLangToISO2 converts SubDL full language names to ISO 639-1 2-letter codes
+func subdlLangToISO2(lang string) string {
+	m := map[string]string{
+		"english": "en", "spanish": "es", "french": "fr", "german": "de",
+		"italian": "it", "portuguese": "pt", "russian": "ru", "chinese": "zh",
+		"japanese": "ja", "korean": "ko", "arabic": "ar", "dutch": "nl",
+		"polish": "pl", "turkish": "tr", "swedish": "sv", "norwegian": "no",
+		"danish": "da", "finnish": "fi", "greek": "el", "hebrew": "he",
+		"hindi": "hi", "thai": "th", "vietnamese": "vi", "indonesian": "id",
+		"czech": "cs", "hungarian": "hu", "romanian": "ro", "bulgarian": "bg",
+		"ukrainian": "uk", "croatian": "hr", "serbian": "sr", "slovak": "sk",
+		"slovenian": "sl", "albanian": "sq", "persian": "fa", "farsi": "fa",
+		"malay": "ms", "estonian": "et", "latvian": "lv", "lithuanian": "lt",
+		"catalan": "ca", "bosnian": "bs", "macedonian": "mk", "icelandic": "is",
+		"georgian": "ka", "armenian": "hy", "bengali": "bn", "urdu": "ur",
+		"tagalog": "tl", "filipino": "tl", "swahili": "sw",
+		"big 5 code": "zh", "brazillian portuguese": "pt",
+	}
+	if code, ok := m[lang]; ok {
+		return code
+	}
+	// If already a 2-letter code, return as-is
+	if len(lang) == 2 {
+		return lang
+	}
+	return lang
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"torrent-server/models"
+)
+
+// maxSubtitleFileSize is the largest subtitle file accepted; anything bigger
+// isn't a real subtitle
+const maxSubtitleFileSize = 5 * 1024 * 1024
+
+// MaxSubtitleUploadSize caps an upload request, which may be a ZIP of subtitles
+const MaxSubtitleUploadSize = 32 << 20
+
+// imdbCodeRe matches IMDb title IDs; they name directories under subtitlesDir
+var imdbCodeRe = regexp.MustCompile(`^tt\d+$`)
+
+// uploadSubtitleExts are the formats convertToVTT understands
+var uploadSubtitleExts = map[string]bool{".srt": true, ".vtt": true, ".ass": true, ".ssa": true}
+
+// SubtitleUpload is a subtitle file (or ZIP of them) added by an admin.
+// Language, ReleaseName and HearingImpaired are optional; the language is
+// detected from the file name or text when not given.
+type SubtitleUpload struct {
+	ImdbCode        string
+	FileName        string
+	Data            []byte
+	Language        string
+	ReleaseName     string
+	HearingImpaired bool
+	Season          int
+	Episode         int
+}
+
+// SubtitleUploadResult is the outcome for one file of an upload
+type SubtitleUploadResult struct {
+	File     string                 `json:"file"`
+	Subtitle *models.StoredSubtitle `json:"subtitle,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+type namedSubtitleFile struct {
+	name string
+	data []byte
+}
+
+// StoreUpload stores an uploaded SRT, ASS, VTT or ZIP file. Each subtitle in
+// a ZIP is stored separately. Files are decoded with charset detection,
+// converted to VTT and written next to their original like synced subtitles.
+func (s *SubtitleService) StoreUpload(u SubtitleUpload) ([]SubtitleUploadResult, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("no database configured")
+	}
+	if !imdbCodeRe.MatchString(u.ImdbCode) {
+		return nil, fmt.Errorf("invalid imdb_code %q", u.ImdbCode)
+	}
+	if (u.Season > 0) != (u.Episode > 0) {
+		return nil, fmt.Errorf("season and episode must be given together")
+	}
+	if u.Season > 0 {
+		if _, err := s.db.GetSeriesByIMDB(u.ImdbCode); err != nil {
+			return nil, fmt.Errorf("series %s not found", u.ImdbCode)
+		}
+	} else if _, err := s.db.GetMovieByIMDB(u.ImdbCode); err != nil {
+		return nil, fmt.Errorf("movie %s not found", u.ImdbCode)
+	}
+
+	files, err := uploadedSubtitleFiles(u.FileName, u.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SubtitleUploadResult, 0, len(files))
+	for _, f := range files {
+		sub, err := s.storeUploadedFile(u, f)
+		result := SubtitleUploadResult{File: f.name, Subtitle: sub}
+		if err != nil {
+			result.Error = err.Error()
+			log.Printf("[SubtitleUpload] Failed to store %s for %s: %v", f.name, u.ImdbCode, err)
+		} else {
+			log.Printf("[SubtitleUpload] Stored %s for %s (lang=%s, charset=%s)", f.name, u.ImdbCode, sub.Language, sub.Charset)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// uploadedSubtitleFiles returns the subtitle files of an upload: the file
+// itself, or every subtitle file in a ZIP
+func uploadedSubtitleFiles(fileName string, data []byte) ([]namedSubtitleFile, error) {
+	if len(data) >= 2 && data[0] == 0x50 && data[1] == 0x4B {
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ZIP archive: %w", err)
+		}
+		var files []namedSubtitleFile
+		for _, f := range reader.File {
+			name := filepath.Base(f.Name)
+			if strings.HasPrefix(f.Name, "__MACOSX/") || !uploadSubtitleExts[strings.ToLower(filepath.Ext(name))] {
+				continue
+			}
+			if f.UncompressedSize64 > maxSubtitleFileSize {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ZIP entry %s: %w", f.Name, err)
+			}
+			content, err := io.ReadAll(io.LimitReader(rc, maxSubtitleFileSize))
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ZIP entry %s: %w", f.Name, err)
+			}
+			files = append(files, namedSubtitleFile{name: name, data: content})
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no subtitle file found in ZIP archive")
+		}
+		return files, nil
+	}
+
+	if !uploadSubtitleExts[strings.ToLower(filepath.Ext(fileName))] {
+		return nil, fmt.Errorf("unsupported subtitle file %q: expected .srt, .ass, .ssa, .vtt or .zip", fileName)
+	}
+	if len(data) > maxSubtitleFileSize {
+		return nil, fmt.Errorf("subtitle file too large (%d bytes)", len(data))
+	}
+	return []namedSubtitleFile{{name: filepath.Base(fileName), data: data}}, nil
+}
+
+// storeUploadedFile decodes, converts and stores one uploaded subtitle file
+func (s *SubtitleService) storeUploadedFile(u SubtitleUpload, f namedSubtitleFile) (*models.StoredSubtitle, error) {
+	lang := strings.ToLower(strings.TrimSpace(u.Language))
+	if lang == "" {
+		lang, _, _ = languageFromFilename(f.name)
+	}
+
+	source, charset := decodeSubtitleText(f.data, lang)
+	if lang == "" {
+		detected, ok := detectTextLanguage(source)
+		if !ok {
+			return nil, fmt.Errorf("couldn't detect the language; set language")
+		}
+		lang = detected
+		if charsetsByLanguage[lang] != nil && !strings.HasPrefix(charset, "utf-") {
+			// Decode again now that the language is known
+			source, charset = decodeSubtitleText(f.data, lang)
+		}
+	}
+
+	if len(ParseCues(convertToVTT(source))) == 0 {
+		return nil, fmt.Errorf("no subtitle cues found")
+	}
+
+	releaseName := strings.TrimSpace(u.ReleaseName)
+	if releaseName == "" {
+		releaseName = strings.TrimSuffix(f.name, filepath.Ext(f.name))
+	}
+	sub := &models.StoredSubtitle{
+		ImdbCode:        u.ImdbCode,
+		Language:        lang,
+		LanguageName:    subtitleLanguageName(lang),
+		ReleaseName:     releaseName,
+		HearingImpaired: u.HearingImpaired,
+		Source:          "upload",
+		SeasonNumber:    u.Season,
+		EpisodeNumber:   u.Episode,
+	}
+	if err := s.db.CreateSubtitle(sub); err != nil {
+		return nil, err
+	}
+	if sub.ID == 0 {
+		return nil, fmt.Errorf("%s subtitle for %q already exists", lang, releaseName)
+	}
+	if err := s.storeSubtitleFiles(sub, source, charset); err != nil {
+		s.db.DeleteSubtitle(sub.ID)
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subtitleLanguageName returns the English name of a language code
+func subtitleLanguageName(code string) string {
+	for _, l := range GetSubtitleLanguages() {
+		if l.Code == code {
+			return l.Name
+		}
+	}
+	return strings.ToUpper(code)
+}
+
+// textScripts maps scripts to the language subtitles in them are most likely in
+var textScripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Arabic, "ar"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+}
+
+// stopWords are frequent short words that tell Latin-script languages apart
+var stopWords = map[string][]string{
+	"en": {"the", "you", "and", "that", "what", "this", "is", "it", "have", "don't", "i'm", "not", "your"},
+	"es": {"que", "el", "los", "qué", "está", "por", "lo", "para", "es", "una", "pero", "estoy", "usted"},
+	"fr": {"je", "pas", "vous", "le", "les", "est", "c'est", "une", "tu", "et", "ce", "qui", "suis"},
+	"de": {"ich", "nicht", "und", "du", "das", "ist", "die", "der", "sie", "wir", "was", "ein", "mit"},
+	"it": {"che", "non", "il", "è", "sono", "per", "mi", "ti", "ho", "cosa", "questo", "della", "gli"},
+	"pt": {"não", "você", "é", "eu", "um", "uma", "isso", "para", "com", "está", "meu", "são", "tem"},
+	"sq": {"të", "që", "në", "dhe", "është", "nuk", "për", "unë", "një", "çfarë", "ti", "jam", "ke"},
+	"tr": {"bir", "ve", "bu", "ne", "değil", "için", "ben", "sen", "çok", "var", "ama", "mı", "şey"},
+	"nl": {"het", "een", "niet", "ik", "je", "dat", "wat", "van", "zijn", "we", "hij", "maar", "heb"},
+	"pl": {"nie", "się", "jest", "że", "na", "co", "jak", "tak", "mnie", "ja", "ty", "mam", "już"},
+}
+
+// detectTextLanguage guesses a subtitle's language from its text: by script
+// for non-Latin text, by counting frequent words otherwise
+func detectTextLanguage(text string) (string, bool) {
+	var words []string
+	for _, c := range ParseCues(convertToVTT(text)) {
+		plain := convertCueText(c.Text, func(string, bool) string { return " " }, func(s string) string { return s })
+		words = append(words, strings.FieldsFunc(strings.ToLower(plain), func(r rune) bool {
+			return !unicode.IsLetter(r) && r != '\''
+		})...)
+		if len(words) > 2000 {
+			break
+		}
+	}
+
+	scripts := make(map[string]int)
+	letters := 0
+	for _, w := range words {
+		for _, r := range w {
+			letters++
+			if r < 0x80 {
+				continue
+			}
+			for _, sc := range textScripts {
+				if unicode.Is(sc.table, r) {
+					scripts[sc.lang]++
+					break
+				}
+			}
+		}
+	}
+	for _, sc := range textScripts {
+		if n := scripts[sc.lang]; n > 0 && n*2 > letters {
+			if sc.lang == "ru" {
+				return cyrillicLanguage(words), true
+			}
+			if sc.lang == "zh" && scripts["ja"] > 0 {
+				return "ja", true
+			}
+			return sc.lang, true
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, w := range words {
+		for lang, list := range stopWords {
+			for _, sw := range list {
+				if w == sw {
+					counts[lang]++
+					break
+				}
+			}
+		}
+	}
+	best, bestN, second := "", 0, 0
+	for lang, n := range counts {
+		if n > bestN || n == bestN && lang < best {
+			best, bestN, second = lang, n, bestN
+		} else if n > second {
+			second = n
+		}
+	}
+	// Require a clear winner
+	if bestN < 5 || bestN*2 < second*3 {
+		return "", false
+	}
+	return best, true
+}
+
+// cyrillicLanguage tells Ukrainian and Serbian apart from Russian by their
+// own letters
+func cyrillicLanguage(words []string) string {
+	text := strings.Join(words, " ")
+	switch {
+	case strings.ContainsAny(text, "іїєґ"):
+		return "uk"
+	case strings.ContainsAny(text, "ђјљњћџ"):
+		return "sr"
+	}
+	return "ru"
+}
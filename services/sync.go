@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -16,30 +17,84 @@ type SyncService struct {
 	db              *database.DB
 	providers       []providers.TorrentProvider
 	imdb            *IMDBService
+	metadata        *MetadataService
+	omdb            *OMDBService
 	subtitleService *SubtitleService
 	mu              sync.Mutex
-	running         bool
 
 	searchCache   map[string]time.Time
 	searchCacheMu sync.Mutex
+
+	ratingsPrecedence RatingsPrecedence
+	ratingsMaxAge     time.Duration
+
+	availability availabilityNotifier
+
+	// Non-English locales to fetch translated metadata for
+	locales []string
+
+	listImports listImports
 }
 
 const searchCacheTTL = 5 * time.Minute
 
 func NewSyncService(db *database.DB, subtitlesDir string) *SyncService {
+	imdb := NewIMDBService()
 	return &SyncService{
 		db:              db,
-		imdb:            NewIMDBService(),
+		imdb:            imdb,
+		metadata:        NewMetadataService(imdb),
 		subtitleService: NewSubtitleServiceWithDB(db, subtitlesDir),
 		providers: []providers.TorrentProvider{
 			providers.NewYTSProvider(),
 			providers.NewEZTVProvider(),
 			providers.NewL337xProvider(),
 		},
-		searchCache: make(map[string]time.Time),
+		searchCache:       make(map[string]time.Time),
+		ratingsPrecedence: DefaultRatingsPrecedence(),
+		ratingsMaxAge:     7 * 24 * time.Hour,
 	}
 }
 
+// SetSubtitleService shares the server's subtitle service, so provider
+// settings changed by admins apply to background syncs too.
+func (s *SyncService) SetSubtitleService(ss *SubtitleService) {
+	s.subtitleService = ss
+}
+
+// SetMetadataService replaces the default IMDB-only metadata source with a
+// configured provider chain.
+func (s *SyncService) SetMetadataService(m *MetadataService) {
+	s.metadata = m
+}
+
+// SetOMDBService adds OMDB as a ratings source (IMDb, Rotten Tomatoes, Metacritic)
+func (s *SyncService) SetOMDBService(omdb *OMDBService) {
+	s.omdb = omdb
+}
+
+// SetRatingsPrecedence sets which source wins for each rating field
+func (s *SyncService) SetRatingsPrecedence(p RatingsPrecedence) {
+	s.ratingsPrecedence = p
+}
+
+// SetLocales sets which non-English locales get translated titles and summaries
+func (s *SyncService) SetLocales(locales []string) {
+	var out []string
+	for _, l := range locales {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l != "" && l != "en" {
+			out = append(out, l)
+		}
+	}
+	s.locales = out
+}
+
+// SetRatingsMaxAge sets how long merged ratings are kept before a refresh re-fetches them
+func (s *SyncService) SetRatingsMaxAge(d time.Duration) {
+	s.ratingsMaxAge = d
+}
+
 // SearchAndSyncFromYTS queries YTS for a free-text term and syncs up to max hits.
 // Results are cached per normalized query for searchCacheTTL to avoid spam.
 // Returns the list of synced/updated movies.
@@ -160,10 +215,10 @@ func (s *SyncService) SyncMovie(imdbCode string) (*models.Movie, error) {
 		return existing, nil
 	}
 
-	// Fetch rich data from IMDB API
-	richData, err := s.imdb.FetchRichData(imdbCode)
+	// Fetch rich data from the metadata providers
+	richData, err := s.metadata.FetchRichData(imdbCode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from IMDB: %w", err)
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
 	}
 
 	// For borderline types (tvSpecial, etc.), check provider availability
@@ -198,20 +253,24 @@ func (s *SyncService) SyncMovie(imdbCode string) (*models.Movie, error) {
 	movie.Budget = richData.Budget
 	movie.BoxOfficeGross = richData.BoxOfficeGross
 	movie.AllImages = richData.AllImages
-	movie.Provider = "imdb"
+	movie.Provider = richData.Sources["title"]
+	s.applyMovieRatings(movie, richData)
+	movie.MetadataSources = richData.Sources
 	movie.ContentType = "movie"
-	log.Printf("Fetched rich data from IMDB for %s", imdbCode)
+	log.Printf("Fetched rich data from %s for %s", movie.Provider, imdbCode)
 
 	// Save movie
 	if err := s.db.CreateMovie(movie); err != nil {
 		return nil, err
 	}
+	s.syncCredits("movie", movie.ID, richData.Cast, richData.Directors, richData.Writers)
 
 	// Fetch torrents
 	s.syncMovieTorrents(movie)
 
-	// Sync subtitles in background
+	// Sync subtitles and translations in background
 	go s.syncSubtitles(imdbCode)
+	go s.syncMovieLocalizations(*movie)
 
 	return movie, nil
 }
@@ -233,24 +292,9 @@ func (s *SyncService) ScanAllMovieTorrents() (scanned, added, skipped int) {
 
 		log.Printf("[ScanTorrents] [%d/%d] Checking %s (%s)...", i+1, total, movie.Title, movie.ImdbCode)
 
-		beforeCount := len(movie.Torrents)
-		s.syncMovieTorrents(&movie)
-
-		// Re-fetch to get updated torrent count
-		updated, err := s.db.GetMovieByIMDB(movie.ImdbCode)
-		if err == nil && updated != nil {
-			newTorrents := len(updated.Torrents) - beforeCount
-			if newTorrents > 0 {
-				added += newTorrents
-				log.Printf("[ScanTorrents]   Found %d new torrent(s)", newTorrents)
-
-				// If movie was coming_soon and now has torrents, mark as available
-				if updated.Status == "coming_soon" {
-					updated.Status = "available"
-					s.db.UpdateMovie(updated)
-					log.Printf("[ScanTorrents]   Marked %s as available", movie.Title)
-				}
-			}
+		if newTorrents := s.syncMovieTorrents(&movie); newTorrents > 0 {
+			added += newTorrents
+			log.Printf("[ScanTorrents]   Found %d new torrent(s)", newTorrents)
 		}
 		scanned++
 
@@ -263,77 +307,97 @@ func (s *SyncService) ScanAllMovieTorrents() (scanned, added, skipped int) {
 }
 
 // RefreshAllMovies refreshes all movies in the database
-func (s *SyncService) RefreshAllMovies() {
+func (s *SyncService) RefreshAllMovies(ctx context.Context, p *JobProgress) error {
 	log.Println("Starting refresh all movies...")
 
 	movies, _, err := s.db.ListMovies(database.MovieFilter{Limit: 10000})
 	if err != nil {
-		log.Printf("Failed to list movies: %v", err)
-		return
+		return fmt.Errorf("failed to list movies: %w", err)
 	}
 
 	total := len(movies)
 	refreshed := 0
 	failed := 0
+	p.SetTotal(total)
 
 	for i, movie := range movies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if movie.ImdbCode == "" {
 			log.Printf("[%d/%d] Skipping %s - no IMDB code", i+1, total, movie.Title)
+			p.Add(1, 0, 0)
 			continue
 		}
 
 		log.Printf("[%d/%d] Refreshing %s (%s)...", i+1, total, movie.Title, movie.ImdbCode)
+		p.SetCurrent(movie.Title)
 		_, err := s.RefreshMovie(&movie)
 		if err != nil {
 			log.Printf("  Failed: %v", err)
 			failed++
+			p.Add(1, 0, 1)
 		} else {
 			log.Printf("  Done")
 			refreshed++
+			p.Add(1, 0, 0)
 		}
 
 		// Rate limiting - don't hammer the APIs
-		time.Sleep(2 * time.Second)
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			return err
+		}
 	}
 
 	log.Printf("Refresh all movies completed: %d refreshed, %d failed, %d total", refreshed, failed, total)
+	return nil
 }
 
 // RefreshAllSeries refreshes all series in the database
-func (s *SyncService) RefreshAllSeries() {
+func (s *SyncService) RefreshAllSeries(ctx context.Context, p *JobProgress) error {
 	log.Println("Starting refresh all TV series...")
 
 	seriesList, _, err := s.db.ListSeries(database.SeriesFilter{Limit: 1000, Page: 1})
 	if err != nil {
-		log.Printf("Failed to list series: %v", err)
-		return
+		return fmt.Errorf("failed to list series: %w", err)
 	}
 
 	total := len(seriesList)
 	refreshed := 0
 	failed := 0
+	p.SetTotal(total)
 
 	for i, series := range seriesList {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if series.ImdbCode == "" {
 			log.Printf("[%d/%d] Skipping %s - no IMDB code", i+1, total, series.Title)
+			p.Add(1, 0, 0)
 			continue
 		}
 
 		log.Printf("[%d/%d] Refreshing %s (%s)...", i+1, total, series.Title, series.ImdbCode)
+		p.SetCurrent(series.Title)
 		_, err := s.RefreshSeries(&series)
 		if err != nil {
 			log.Printf("  Failed: %v", err)
 			failed++
+			p.Add(1, 0, 1)
 		} else {
 			log.Printf("  Done")
 			refreshed++
+			p.Add(1, 0, 0)
 		}
 
 		// Rate limiting - don't hammer the APIs
-		time.Sleep(3 * time.Second)
+		if err := sleepCtx(ctx, 3*time.Second); err != nil {
+			return err
+		}
 	}
 
 	log.Printf("Refresh all TV series completed: %d refreshed, %d failed, %d total", refreshed, failed, total)
+	return nil
 }
 
 // RefreshMovie re-fetches data for an existing movie
@@ -345,10 +409,10 @@ func (s *SyncService) RefreshMovie(movie *models.Movie) (*models.Movie, error) {
 		return nil, fmt.Errorf("movie has no IMDB code")
 	}
 
-	// Fetch rich data from IMDB
-	richData, err := s.imdb.FetchRichData(movie.ImdbCode)
+	// Fetch rich data from the metadata providers
+	richData, err := s.metadata.FetchRichData(movie.ImdbCode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from IMDB: %w", err)
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
 	}
 
 	// Update movie with rich data
@@ -361,19 +425,9 @@ func (s *SyncService) RefreshMovie(movie *models.Movie) (*models.Movie, error) {
 	movie.Genres = richData.Genres
 	movie.Summary = richData.Plot
 	movie.DescriptionFull = richData.Plot
+	movie.Tagline = richData.Tagline
 	movie.MpaRating = richData.ContentRating
 
-	if richData.Rating > 0 {
-		r := float32(richData.Rating)
-		movie.Rating = r
-		movie.ImdbRating = &r
-	}
-	if richData.VoteCount > 0 {
-		movie.ImdbVotes = formatVotes(richData.VoteCount)
-	}
-	if richData.Metacritic > 0 {
-		movie.Metacritic = &richData.Metacritic
-	}
 	if len(richData.Directors) > 0 {
 		movie.Director = richData.Directors[0]
 	}
@@ -392,24 +446,147 @@ func (s *SyncService) RefreshMovie(movie *models.Movie) (*models.Movie, error) {
 		movie.BackgroundImage = richData.BackgroundURL
 	}
 
-	movie.Provider = "imdb"
-	log.Printf("Refreshed movie %s from IMDB", movie.ImdbCode)
+	if richData.YouTubeTrailerID != "" {
+		movie.YtTrailerCode = richData.YouTubeTrailerID
+	}
+
+	movie.Provider = richData.Sources["title"]
+	s.applyMovieRatings(movie, richData)
+	movie.MetadataSources = richData.Sources
+	log.Printf("Refreshed movie %s from %s", movie.ImdbCode, movie.Provider)
 
 	// Save updated movie
 	if err := s.db.UpdateMovie(movie); err != nil {
 		return nil, fmt.Errorf("failed to save refreshed movie: %w", err)
 	}
+	s.syncCredits("movie", movie.ID, richData.Cast, richData.Directors, richData.Writers)
 
 	// Also sync torrents
 	s.syncMovieTorrents(movie)
 
-	// Sync subtitles in background
+	// Sync subtitles and translations in background
 	go s.syncSubtitles(movie.ImdbCode)
+	go s.syncMovieLocalizations(*movie)
 
 	return movie, nil
 }
 
-func (s *SyncService) syncMovieTorrents(movie *models.Movie) {
+// syncCredits links the people from the metadata credits to a movie or series.
+// Titles whose provider returned no credits keep their existing ones.
+func (s *SyncService) syncCredits(contentType string, contentID uint, cast []models.Cast, directors, writers []string) {
+	if len(cast) == 0 && len(directors) == 0 && len(writers) == 0 {
+		return
+	}
+	if err := s.db.SyncCredits(contentType, contentID, cast, directors, writers); err != nil {
+		log.Printf("[People] Failed to sync credits for %s %d: %v", contentType, contentID, err)
+	}
+}
+
+// collectRatings gathers rating candidates keyed by source: whatever the
+// metadata providers returned, plus OMDB when it is configured.
+func (s *SyncService) collectRatings(imdbCode string, sources map[string]string, rating float64, votes, metacritic int) map[string]*RatingValues {
+	candidates := make(map[string]*RatingValues)
+	candidate := func(name string) *RatingValues {
+		c, ok := candidates[name]
+		if !ok {
+			c = &RatingValues{}
+			candidates[name] = c
+		}
+		return c
+	}
+
+	if rating > 0 {
+		c := candidate(sources["rating"])
+		r := float32(rating)
+		c.ImdbRating = &r
+		if votes > 0 {
+			c.ImdbVotes = formatVotes(votes)
+		}
+	}
+	if metacritic > 0 {
+		c := candidate(sources["metacritic"])
+		mc := metacritic
+		c.Metacritic = &mc
+	}
+
+	if s.omdb != nil && s.omdb.IsConfigured() {
+		values, err := s.omdb.FetchRatings(imdbCode)
+		if err != nil {
+			log.Printf("[Ratings] OMDB lookup failed for %s: %v", imdbCode, err)
+		} else {
+			candidates[s.omdb.Name()] = values
+		}
+	}
+	return candidates
+}
+
+// applyMovieRatings merges ratings into movie when its ratings are stale.
+// Per-field sources are recorded in richData.Sources; when the ratings are
+// still fresh the previous sources are carried over instead.
+func (s *SyncService) applyMovieRatings(movie *models.Movie, richData *RichMovieData) {
+	if !ratingsStale(movie.RatingsUpdatedAt, s.ratingsMaxAge) {
+		carryRatingSources(richData.Sources, movie.MetadataSources)
+		return
+	}
+
+	candidates := s.collectRatings(movie.ImdbCode, richData.Sources, richData.Rating, richData.VoteCount, richData.Metacritic)
+	merged, sources := s.ratingsPrecedence.Merge(candidates)
+	if merged.ImdbRating != nil {
+		movie.Rating = *merged.ImdbRating
+		movie.ImdbRating = merged.ImdbRating
+		if merged.ImdbVotes != "" {
+			movie.ImdbVotes = merged.ImdbVotes
+		}
+	}
+	if merged.RottenTomatoes != nil {
+		movie.RottenTomatoes = merged.RottenTomatoes
+	}
+	if merged.Metacritic != nil {
+		movie.Metacritic = merged.Metacritic
+	}
+	for field, src := range sources {
+		richData.Sources[field] = src
+	}
+	movie.RatingsUpdatedAt = time.Now().UTC().Format(time.RFC3339)
+}
+
+// applySeriesRatings is applyMovieRatings for series
+func (s *SyncService) applySeriesRatings(series *models.Series, richData *RichSeriesData) {
+	if !ratingsStale(series.RatingsUpdatedAt, s.ratingsMaxAge) {
+		carryRatingSources(richData.Sources, series.MetadataSources)
+		return
+	}
+
+	candidates := s.collectRatings(series.ImdbCode, richData.Sources, richData.Rating, richData.VoteCount, richData.Metacritic)
+	merged, sources := s.ratingsPrecedence.Merge(candidates)
+	if merged.ImdbRating != nil {
+		series.Rating = *merged.ImdbRating
+		series.ImdbRating = merged.ImdbRating
+	}
+	if merged.RottenTomatoes != nil {
+		series.RottenTomatoes = merged.RottenTomatoes
+	}
+	if merged.Metacritic != nil {
+		series.Metacritic = merged.Metacritic
+	}
+	for field, src := range sources {
+		richData.Sources[field] = src
+	}
+	series.RatingsUpdatedAt = time.Now().UTC().Format(time.RFC3339)
+}
+
+func carryRatingSources(dst, prev map[string]string) {
+	for _, field := range []string{RatingFieldIMDb, RatingFieldRottenTomatoes, RatingFieldMetacritic} {
+		if src, ok := prev[field]; ok {
+			dst[field] = src
+		}
+	}
+}
+
+// syncMovieTorrents searches every provider for movie's torrents and saves the
+// new ones. A coming-soon movie that gains torrents is marked available.
+func (s *SyncService) syncMovieTorrents(movie *models.Movie) int {
+	added := 0
 	for _, provider := range s.providers {
 		results, err := provider.SearchMovie(movie.Title, int(movie.Year))
 		if err != nil {
@@ -430,9 +607,16 @@ func (s *SyncService) syncMovieTorrents(movie *models.Movie) {
 
 			if err := s.db.CreateTorrent(torrent); err != nil {
 				log.Printf("Failed to save torrent: %v", err)
+				continue
 			}
+			added++
 		}
 	}
+
+	if added > 0 && movie.Status == "coming_soon" {
+		s.markAvailable(movie, added)
+	}
+	return added
 }
 
 // SyncSeriesWithData creates a series with provided metadata
@@ -486,10 +670,10 @@ func (s *SyncService) RefreshSeries(series *models.Series) (*models.Series, erro
 		return nil, fmt.Errorf("series has no IMDB code")
 	}
 
-	// Fetch rich data from IMDB
-	richData, err := s.imdb.FetchRichSeriesData(series.ImdbCode)
+	// Fetch rich data from the metadata providers
+	richData, err := s.metadata.FetchRichSeriesData(series.ImdbCode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from IMDB: %w", err)
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
 	}
 
 	// Update series with rich data
@@ -503,68 +687,57 @@ func (s *SyncService) RefreshSeries(series *models.Series) (*models.Series, erro
 	series.Runtime = uint(richData.Runtime)
 	series.Genres = richData.Genres
 	series.Summary = richData.Plot
+	series.Tagline = richData.Tagline
 	series.Status = richData.Status
 	series.TotalSeasons = uint(richData.TotalSeasons)
 
-	if richData.Rating > 0 {
-		series.Rating = float32(richData.Rating)
-		r := float32(richData.Rating)
-		series.ImdbRating = &r
-	}
-
 	if richData.PosterURL != "" {
 		series.PosterImage = richData.PosterURL
 	}
 	if richData.BackgroundURL != "" {
 		series.BackgroundImage = richData.BackgroundURL
 	}
+	s.applySeriesRatings(series, richData)
+	series.MetadataSources = richData.Sources
 
-	log.Printf("Refreshed series %s from IMDB: %d seasons found", series.ImdbCode, len(richData.Seasons))
+	log.Printf("Refreshed series %s from %s: %d seasons found", series.ImdbCode, richData.Sources["title"], len(richData.Seasons))
 
 	// Save updated series
 	if err := s.db.UpdateSeries(series); err != nil {
 		return nil, fmt.Errorf("failed to save refreshed series: %w", err)
 	}
+	s.syncCredits("series", series.ID, richData.Cast, richData.Directors, richData.Writers)
 
-	// Sync episodes from IMDB data
+	// Sync episodes from metadata
 	totalEpisodes := 0
 	for _, season := range richData.Seasons {
 		for _, ep := range season.Episodes {
-			// Parse season number from string
 			seasonNum := season.Season
 
-			// Format air date from ReleaseDate
-			airDate := ""
-			if ep.ReleaseDate != nil {
-				airDate = fmt.Sprintf("%04d-%02d-%02d", ep.ReleaseDate.Year, ep.ReleaseDate.Month, ep.ReleaseDate.Day)
-			}
-
 			episode := &models.Episode{
 				SeriesID:      series.ID,
 				SeasonNumber:  uint(seasonNum),
-				EpisodeNumber: uint(ep.EpisodeNumber),
+				EpisodeNumber: uint(ep.Episode),
 				Title:         ep.Title,
 				Summary:       ep.Plot,
-				AirDate:       airDate,
+				AirDate:       ep.AirDate,
+				StillImage:    ep.StillURL,
 			}
-			if ep.RuntimeSeconds > 0 {
-				runtime := uint(ep.RuntimeSeconds / 60)
+			if ep.Runtime > 0 {
+				runtime := uint(ep.Runtime)
 				episode.Runtime = &runtime
 			}
-			if ep.PrimaryImage != nil {
-				episode.StillImage = ep.PrimaryImage.URL
-			}
 
 			// CreateEpisode uses ON CONFLICT DO UPDATE, so it will update existing
 			if err := s.db.CreateEpisode(episode); err != nil {
-				log.Printf("Failed to save episode S%02dE%02d: %v", seasonNum, ep.EpisodeNumber, err)
+				log.Printf("Failed to save episode S%02dE%02d: %v", seasonNum, ep.Episode, err)
 			} else {
 				totalEpisodes++
 			}
 		}
 	}
 
-	log.Printf("Synced %d episodes from IMDB for %s", totalEpisodes, series.Title)
+	log.Printf("Synced %d episodes from %s for %s", totalEpisodes, richData.Sources["episodes"], series.Title)
 
 	// Update total episodes count
 	series.TotalEpisodes = uint(totalEpisodes)
@@ -587,8 +760,9 @@ func (s *SyncService) RefreshSeries(series *models.Series) (*models.Series, erro
 		}
 	}
 
-	// Sync subtitles per-episode in background
+	// Sync subtitles per-episode and translations in background
 	go s.syncSeriesSubtitles(series)
+	go s.syncSeriesLocalizations(*series)
 
 	return series, nil
 }
@@ -705,8 +879,9 @@ func (s *SyncService) syncSeriesEpisodeTorrents(series *models.Series) {
 	log.Printf("Saved %d season packs and %d episode torrents for %s (deduped from %d)", seasonPackCount, episodeTorrentCount, series.Title, len(results))
 }
 
-// SyncEpisode fetches torrents for a specific episode
-func (s *SyncService) SyncEpisode(series *models.Series, season, episode int) error {
+// SyncEpisode fetches torrents for a specific episode and returns how many
+// new torrents were saved
+func (s *SyncService) SyncEpisode(series *models.Series, season, episode int) (int, error) {
 	// Create episode if not exists
 	episodes, _ := s.db.GetEpisodes(series.ID, season)
 	var ep *models.Episode
@@ -724,50 +899,53 @@ func (s *SyncService) SyncEpisode(series *models.Series, season, episode int) er
 			EpisodeNumber: uint(episode),
 		}
 		if err := s.db.CreateEpisode(ep); err != nil {
-			return err
+			return 0, err
 		}
 	}
 
+	// Skip torrents the episode already has
+	known := make(map[string]bool, len(ep.Torrents))
+	for _, t := range ep.Torrents {
+		known[strings.ToLower(t.Hash)] = true
+	}
+
 	// Search for torrents
+	added := 0
+	var lastErr error
 	for _, provider := range s.providers {
 		results, err := provider.SearchSeries(series.Title, season, episode)
 		if err != nil {
+			lastErr = err
 			continue
 		}
 
 		for _, result := range results {
+			if result.Hash == "" || known[strings.ToLower(result.Hash)] {
+				continue
+			}
 			torrent := result.ToEpisodeTorrent(ep.ID)
+			torrent.SeriesID = series.ID
+			torrent.SeasonNumber = uint(season)
+			torrent.EpisodeNumber = uint(episode)
 			if err := s.db.CreateEpisodeTorrent(torrent); err != nil {
 				log.Printf("Failed to save episode torrent: %v", err)
+				continue
 			}
+			known[strings.ToLower(result.Hash)] = true
+			added++
 		}
 	}
 
-	return nil
-}
-
-// StartBackgroundSync starts periodic sync of all content
-func (s *SyncService) StartBackgroundSync(interval time.Duration) {
-	if s.running {
-		return
+	if added == 0 && lastErr != nil {
+		return 0, lastErr
 	}
-	s.running = true
-
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			s.syncAll()
-		}
-	}()
+	return added, nil
 }
 
 func (s *SyncService) syncSeriesSubtitles(series *models.Series) {
 	if s.subtitleService == nil || series.ImdbCode == "" {
 		return
 	}
-	languages := "en,sq,es,fr,de,it,pt,tr,ar"
 
 	for seasonNum := 1; seasonNum <= int(series.TotalSeasons); seasonNum++ {
 		episodes, err := s.db.GetEpisodes(series.ID, seasonNum)
@@ -780,13 +958,12 @@ func (s *SyncService) syncSeriesSubtitles(series *models.Series) {
 			if count > 0 {
 				continue
 			}
-			n, err := s.subtitleService.SyncEpisodeSubtitles(series.ImdbCode, languages, int(ep.SeasonNumber), int(ep.EpisodeNumber))
+			n, err := s.subtitleService.SyncEpisodeSubtitles(series.ImdbCode, "", int(ep.SeasonNumber), int(ep.EpisodeNumber))
 			if err != nil {
 				log.Printf("[SyncService] Failed to sync subtitles for %s S%02dE%02d: %v", series.ImdbCode, ep.SeasonNumber, ep.EpisodeNumber, err)
 			} else if n > 0 {
 				log.Printf("[SyncService] Synced %d subtitles for %s S%02dE%02d", n, series.ImdbCode, ep.SeasonNumber, ep.EpisodeNumber)
 			}
-			time.Sleep(1 * time.Second) // Rate limit
 		}
 	}
 }
@@ -800,8 +977,7 @@ func (s *SyncService) syncSubtitles(imdbCode string) {
 	if count > 0 {
 		return
 	}
-	languages := "en,sq,es,fr,de,it,pt,tr,ar"
-	count, err := s.subtitleService.SyncSubtitles(imdbCode, languages)
+	count, err := s.subtitleService.SyncSubtitles(imdbCode, "")
 	if err != nil {
 		log.Printf("[SyncService] Failed to sync subtitles for %s: %v", imdbCode, err)
 	} else if count > 0 {
@@ -809,24 +985,180 @@ func (s *SyncService) syncSubtitles(imdbCode string) {
 	}
 }
 
-func (s *SyncService) syncAll() {
+// SyncAll rescans torrents for every movie and fills in missing subtitles
+func (s *SyncService) SyncAll(ctx context.Context, p *JobProgress) error {
 	log.Println("Starting background sync...")
 
-	// Sync all movies
 	movies, _, err := s.db.ListMovies(database.MovieFilter{Limit: 1000})
 	if err != nil {
-		log.Printf("Failed to list movies for sync: %v", err)
-		return
+		return fmt.Errorf("failed to list movies for sync: %w", err)
 	}
+	p.SetTotal(len(movies))
 
 	for _, movie := range movies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.SetCurrent(movie.Title)
 		s.syncMovieTorrents(&movie)
 		// Auto-sync subtitles for movies that don't have any
 		if movie.ImdbCode != "" {
 			s.syncSubtitles(movie.ImdbCode)
 		}
-		time.Sleep(1 * time.Second) // Rate limiting
+		p.Add(1, 0, 0)
+		if err := sleepCtx(ctx, 1*time.Second); err != nil { // Rate limiting
+			return err
+		}
 	}
 
 	log.Println("Background sync completed")
+	return nil
+}
+
+// ResyncMissingSubtitles tops up the subtitles of every playable movie and
+// episode to the configured maximum of each enabled language. Providers are
+// paced by their own rate limits.
+func (s *SyncService) ResyncMissingSubtitles(ctx context.Context, p *JobProgress) error {
+	if s.subtitleService == nil {
+		return fmt.Errorf("subtitle service not configured")
+	}
+	targets, err := s.db.ListSubtitleTargets()
+	if err != nil {
+		return fmt.Errorf("failed to list subtitle targets: %w", err)
+	}
+	p.SetTotal(len(targets))
+
+	for _, t := range targets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		label := t.Title
+		if t.Season > 0 {
+			label = fmt.Sprintf("%s S%02dE%02d", t.Title, t.Season, t.Episode)
+		}
+		p.SetCurrent(label)
+
+		n, err := s.subtitleService.FillMissingSubtitles(ctx, t.ImdbCode, t.Season, t.Episode)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[SyncService] Failed to fill subtitles for %s: %v", label, err)
+			p.Add(1, 0, 1)
+			continue
+		}
+		p.Add(1, n, 0)
+	}
+	return nil
+}
+
+// sleepCtx waits for d, returning early with ctx's error if it is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// localizedValue drops translations that are identical to the English value,
+// so the API falls back to English rather than storing a copy.
+func localizedValue(translated, english string) string {
+	if translated == english {
+		return ""
+	}
+	return translated
+}
+
+// syncMovieLocalizations stores translated metadata for every configured locale
+func (s *SyncService) syncMovieLocalizations(movie models.Movie) {
+	for _, locale := range s.locales {
+		loc, provider, err := s.metadata.FetchLocalized(movie.ImdbCode, locale)
+		if err != nil {
+			log.Printf("[Localization] %s (%s): %v", movie.ImdbCode, locale, err)
+			continue
+		}
+		l := &models.Localization{
+			ContentType: "movie",
+			ContentID:   movie.ID,
+			Locale:      locale,
+			Title:       localizedValue(loc.Title, movie.Title),
+			Summary:     localizedValue(loc.Plot, movie.Summary),
+			Tagline:     localizedValue(loc.Tagline, movie.Tagline),
+			PosterImage: loc.PosterURL,
+			Source:      provider,
+		}
+		if err := s.db.SaveLocalization(l); err != nil {
+			log.Printf("[Localization] Failed to save %s (%s): %v", movie.ImdbCode, locale, err)
+		}
+	}
+}
+
+// syncSeriesLocalizations stores translated metadata for a series and its episodes
+func (s *SyncService) syncSeriesLocalizations(series models.Series) {
+	if len(s.locales) == 0 {
+		return
+	}
+
+	episodes, _ := s.db.GetEpisodes(series.ID, 0)
+	byNumber := make(map[[2]int]models.Episode, len(episodes))
+	for _, ep := range episodes {
+		byNumber[[2]int{int(ep.SeasonNumber), int(ep.EpisodeNumber)}] = ep
+	}
+
+	for _, locale := range s.locales {
+		loc, provider, err := s.metadata.FetchLocalized(series.ImdbCode, locale)
+		if err != nil {
+			log.Printf("[Localization] %s (%s): %v", series.ImdbCode, locale, err)
+			continue
+		}
+		l := &models.Localization{
+			ContentType: "series",
+			ContentID:   series.ID,
+			Locale:      locale,
+			Title:       localizedValue(loc.Title, series.Title),
+			Summary:     localizedValue(loc.Plot, series.Summary),
+			Tagline:     localizedValue(loc.Tagline, series.Tagline),
+			PosterImage: loc.PosterURL,
+			Source:      provider,
+		}
+		if err := s.db.SaveLocalization(l); err != nil {
+			log.Printf("[Localization] Failed to save %s (%s): %v", series.ImdbCode, locale, err)
+		}
+
+		if len(byNumber) == 0 {
+			continue
+		}
+		translated, provider, err := s.metadata.FetchLocalizedEpisodes(series.ImdbCode, locale)
+		if err != nil {
+			log.Printf("[Localization] %s episodes (%s): %v", series.ImdbCode, locale, err)
+			continue
+		}
+		saved := 0
+		for _, t := range translated {
+			ep, ok := byNumber[[2]int{t.Season, t.Episode}]
+			if !ok {
+				continue
+			}
+			l := &models.Localization{
+				ContentType: "episode",
+				ContentID:   ep.ID,
+				Locale:      locale,
+				Title:       localizedValue(t.Title, ep.Title),
+				Summary:     localizedValue(t.Plot, ep.Summary),
+				PosterImage: localizedValue(t.StillURL, ep.StillImage),
+				Source:      provider,
+			}
+			if l.Title == "" && l.Summary == "" && l.PosterImage == "" {
+				continue
+			}
+			if err := s.db.SaveLocalization(l); err == nil {
+				saved++
+			}
+		}
+		log.Printf("[Localization] Saved %d %s episode translations for %s", saved, locale, series.Title)
+	}
 }
@@ -0,0 +1,555 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"torrent-server/models"
+)
+
+const (
+	tmdbAPIBaseURL   = "https://api.themoviedb.org/3"
+	tmdbImageBaseURL = "https://image.tmdb.org/t/p/"
+)
+
+// TMDBService is a MetadataProvider backed by The Movie Database.
+// TMDB is keyed by its own numeric IDs, so every lookup first resolves the
+// IMDB ID through /find and caches the result.
+type TMDBService struct {
+	apiKey string
+	client *http.Client
+	cache  *ResponseCache
+
+	refsMu sync.Mutex
+	refs   map[string]tmdbRef
+}
+
+type tmdbRef struct {
+	Kind string // "movie" or "tv"
+	ID   int
+}
+
+func NewTMDBService(apiKey string) *TMDBService {
+	return &TMDBService{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 30 * time.Second},
+		refs:   make(map[string]tmdbRef),
+	}
+}
+
+func (s *TMDBService) Name() string {
+	return "tmdb"
+}
+
+func (s *TMDBService) IsConfigured() bool {
+	return s.apiKey != ""
+}
+
+// SetCache routes lookups through the persistent response cache
+func (s *TMDBService) SetCache(cache *ResponseCache) {
+	s.cache = cache
+}
+
+// TMDB response shapes
+
+type tmdbFindResponse struct {
+	MovieResults []struct {
+		ID int `json:"id"`
+	} `json:"movie_results"`
+	TVResults []struct {
+		ID int `json:"id"`
+	} `json:"tv_results"`
+}
+
+type tmdbGenre struct {
+	Name string `json:"name"`
+}
+
+type tmdbDetails struct {
+	// Movies
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	ReleaseDate   string `json:"release_date"`
+	Runtime       int    `json:"runtime"`
+	Budget        int64  `json:"budget"`
+	Revenue       int64  `json:"revenue"`
+	ReleaseDates  struct {
+		Results []struct {
+			Country      string `json:"iso_3166_1"`
+			ReleaseDates []struct {
+				Certification string `json:"certification"`
+			} `json:"release_dates"`
+		} `json:"results"`
+	} `json:"release_dates"`
+
+	// TV
+	Name           string `json:"name"`
+	OriginalName   string `json:"original_name"`
+	FirstAirDate   string `json:"first_air_date"`
+	LastAirDate    string `json:"last_air_date"`
+	EpisodeRunTime []int  `json:"episode_run_time"`
+	Status         string `json:"status"`
+	Type           string `json:"type"`
+	Seasons        []struct {
+		SeasonNumber int `json:"season_number"`
+	} `json:"seasons"`
+	ContentRatings struct {
+		Results []struct {
+			Country string `json:"iso_3166_1"`
+			Rating  string `json:"rating"`
+		} `json:"results"`
+	} `json:"content_ratings"`
+
+	// Shared
+	Tagline      string      `json:"tagline"`
+	Overview     string      `json:"overview"`
+	Genres       []tmdbGenre `json:"genres"`
+	VoteAverage  float64     `json:"vote_average"`
+	VoteCount    int         `json:"vote_count"`
+	PosterPath   string      `json:"poster_path"`
+	BackdropPath string      `json:"backdrop_path"`
+}
+
+type tmdbCreditsResponse struct {
+	Cast []struct {
+		ID          int    `json:"id"`
+		Name        string `json:"name"`
+		Character   string `json:"character"`
+		ProfilePath string `json:"profile_path"`
+	} `json:"cast"`
+	Crew []struct {
+		Name       string `json:"name"`
+		Job        string `json:"job"`
+		Department string `json:"department"`
+	} `json:"crew"`
+}
+
+type tmdbImagesResponse struct {
+	Posters   []tmdbImage `json:"posters"`
+	Backdrops []tmdbImage `json:"backdrops"`
+}
+
+type tmdbImage struct {
+	FilePath string `json:"file_path"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type tmdbVideosResponse struct {
+	Results []struct {
+		Key      string `json:"key"`
+		Name     string `json:"name"`
+		Site     string `json:"site"`
+		Type     string `json:"type"`
+		Official bool   `json:"official"`
+	} `json:"results"`
+}
+
+type tmdbSeasonResponse struct {
+	Episodes []struct {
+		SeasonNumber  int    `json:"season_number"`
+		EpisodeNumber int    `json:"episode_number"`
+		Name          string `json:"name"`
+		Overview      string `json:"overview"`
+		AirDate       string `json:"air_date"`
+		Runtime       int    `json:"runtime"`
+		StillPath     string `json:"still_path"`
+	} `json:"episodes"`
+}
+
+// get fetches a TMDB path into out, cached under endpoint/imdbID/variant
+func (s *TMDBService) get(endpoint, imdbID, variant, path string, params url.Values, out interface{}) error {
+	if !s.IsConfigured() {
+		return fmt.Errorf("TMDB API key not configured")
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("api_key", s.apiKey)
+
+	key := CacheKey{Provider: "tmdb", Endpoint: endpoint, ImdbCode: imdbID, Variant: variant}
+	body, err := s.cache.Fetch(key, func() ([]byte, error) {
+		resp, err := s.client.Get(tmdbAPIBaseURL + path + "?" + params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("TMDB API returned %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// resolve maps an IMDB ID to a TMDB movie or TV ID
+func (s *TMDBService) resolve(imdbID string) (tmdbRef, error) {
+	s.refsMu.Lock()
+	ref, ok := s.refs[imdbID]
+	s.refsMu.Unlock()
+	if ok {
+		return ref, nil
+	}
+
+	var found tmdbFindResponse
+	params := url.Values{}
+	params.Set("external_source", "imdb_id")
+	if err := s.get("find", imdbID, "", "/find/"+imdbID, params, &found); err != nil {
+		return tmdbRef{}, err
+	}
+
+	switch {
+	case len(found.MovieResults) > 0:
+		ref = tmdbRef{Kind: "movie", ID: found.MovieResults[0].ID}
+	case len(found.TVResults) > 0:
+		ref = tmdbRef{Kind: "tv", ID: found.TVResults[0].ID}
+	default:
+		return tmdbRef{}, fmt.Errorf("TMDB has no title for %s", imdbID)
+	}
+
+	s.refsMu.Lock()
+	s.refs[imdbID] = ref
+	s.refsMu.Unlock()
+	return ref, nil
+}
+
+func tmdbImageURL(size, path string) string {
+	if path == "" {
+		return ""
+	}
+	return tmdbImageBaseURL + size + path
+}
+
+func tmdbYear(date string) int {
+	var year int
+	if len(date) >= 4 {
+		fmt.Sscanf(date[:4], "%d", &year)
+	}
+	return year
+}
+
+// details fetches /movie/{id} or /tv/{id}. Title, Episodes and BoxOffice all
+// read from the same response so it is requested (and cached) once.
+func (s *TMDBService) details(imdbID string, ref tmdbRef) (*tmdbDetails, error) {
+	var d tmdbDetails
+	params := url.Values{}
+	if ref.Kind == "movie" {
+		params.Set("append_to_response", "release_dates")
+	} else {
+		params.Set("append_to_response", "content_ratings")
+	}
+	if err := s.get("title", imdbID, "", fmt.Sprintf("/%s/%d", ref.Kind, ref.ID), params, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Title implements MetadataProvider
+func (s *TMDBService) Title(imdbID string) (*MetadataTitle, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := s.details(imdbID, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &MetadataTitle{
+		Plot:          d.Overview,
+		Tagline:       d.Tagline,
+		Rating:        d.VoteAverage,
+		VoteCount:     d.VoteCount,
+		PosterURL:     tmdbImageURL("w500", d.PosterPath),
+		BackgroundURL: tmdbImageURL("original", d.BackdropPath),
+	}
+	for _, g := range d.Genres {
+		out.Genres = append(out.Genres, g.Name)
+	}
+
+	if ref.Kind == "movie" {
+		out.Type = "movie"
+		out.Title = d.Title
+		out.OriginalTitle = d.OriginalTitle
+		out.Year = tmdbYear(d.ReleaseDate)
+		out.Runtime = d.Runtime
+		for _, r := range d.ReleaseDates.Results {
+			if r.Country != "US" {
+				continue
+			}
+			for _, rd := range r.ReleaseDates {
+				if rd.Certification != "" {
+					out.ContentRating = rd.Certification
+					break
+				}
+			}
+		}
+		return out, nil
+	}
+
+	out.Type = "tvSeries"
+	if d.Type == "Miniseries" {
+		out.Type = "tvMiniSeries"
+	}
+	out.Title = d.Name
+	out.OriginalTitle = d.OriginalName
+	out.Year = tmdbYear(d.FirstAirDate)
+	if len(d.EpisodeRunTime) > 0 {
+		out.Runtime = d.EpisodeRunTime[0]
+	}
+	if d.Status == "Ended" || d.Status == "Canceled" {
+		if endYear := tmdbYear(d.LastAirDate); endYear > 0 {
+			out.EndYear = &endYear
+		}
+	}
+	for _, r := range d.ContentRatings.Results {
+		if r.Country == "US" && r.Rating != "" {
+			out.ContentRating = r.Rating
+			break
+		}
+	}
+	return out, nil
+}
+
+// Credits implements MetadataProvider
+func (s *TMDBService) Credits(imdbID string) (*MetadataCredits, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	var credits tmdbCreditsResponse
+	if err := s.get("credits", imdbID, "", fmt.Sprintf("/%s/%d/credits", ref.Kind, ref.ID), nil, &credits); err != nil {
+		return nil, err
+	}
+
+	out := &MetadataCredits{
+		Directors: []string{},
+		Writers:   []string{},
+		Cast:      []models.Cast{},
+	}
+	for _, c := range credits.Crew {
+		switch {
+		case c.Job == "Director":
+			out.Directors = append(out.Directors, c.Name)
+		case c.Department == "Writing":
+			out.Writers = append(out.Writers, c.Name)
+		}
+	}
+	for _, c := range credits.Cast {
+		if len(out.Cast) >= 10 { // Limit to top 10 actors
+			break
+		}
+		out.Cast = append(out.Cast, models.Cast{
+			Name:          c.Name,
+			CharacterName: c.Character,
+			URLSmallImage: tmdbImageURL("w185", c.ProfilePath),
+		})
+	}
+	return out, nil
+}
+
+// Images implements MetadataProvider
+func (s *TMDBService) Images(imdbID string) ([]MetadataImage, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	var images tmdbImagesResponse
+	if err := s.get("images", imdbID, "", fmt.Sprintf("/%s/%d/images", ref.Kind, ref.ID), nil, &images); err != nil {
+		return nil, err
+	}
+
+	var out []MetadataImage
+	for _, img := range append(images.Posters, images.Backdrops...) {
+		out = append(out, MetadataImage{
+			URL:    tmdbImageURL("original", img.FilePath),
+			Width:  img.Width,
+			Height: img.Height,
+		})
+	}
+	return out, nil
+}
+
+// Videos implements MetadataProvider
+func (s *TMDBService) Videos(imdbID string) ([]MetadataVideo, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	var videos tmdbVideosResponse
+	if err := s.get("videos", imdbID, "", fmt.Sprintf("/%s/%d/videos", ref.Kind, ref.ID), nil, &videos); err != nil {
+		return nil, err
+	}
+
+	// Official trailers first
+	var official, rest []MetadataVideo
+	for _, v := range videos.Results {
+		mv := MetadataVideo{ID: v.Key, Name: v.Name, Site: v.Site, Type: v.Type}
+		if v.Official {
+			official = append(official, mv)
+		} else {
+			rest = append(rest, mv)
+		}
+	}
+	return append(official, rest...), nil
+}
+
+// Episodes implements MetadataProvider
+func (s *TMDBService) Episodes(imdbID string) ([]MetadataEpisode, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Kind != "tv" {
+		return nil, fmt.Errorf("%s is not a TV series on TMDB", imdbID)
+	}
+
+	d, err := s.details(imdbID, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []MetadataEpisode
+	for _, season := range d.Seasons {
+		// Season 0 holds specials, which torrent providers don't index
+		if season.SeasonNumber == 0 {
+			continue
+		}
+		var sr tmdbSeasonResponse
+		if err := s.get("season", imdbID, fmt.Sprint(season.SeasonNumber), fmt.Sprintf("/tv/%d/season/%d", ref.ID, season.SeasonNumber), nil, &sr); err != nil {
+			return nil, err
+		}
+		for _, ep := range sr.Episodes {
+			out = append(out, MetadataEpisode{
+				Season:   ep.SeasonNumber,
+				Episode:  ep.EpisodeNumber,
+				Title:    ep.Name,
+				Plot:     ep.Overview,
+				Runtime:  ep.Runtime,
+				AirDate:  ep.AirDate,
+				StillURL: tmdbImageURL("w300", ep.StillPath),
+			})
+		}
+
+		// Limit to prevent runaway requests
+		if len(out) >= 500 {
+			break
+		}
+	}
+	return out, nil
+}
+
+// BoxOffice implements BoxOfficeProvider
+func (s *TMDBService) BoxOffice(imdbID string) (string, string, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return "", "", err
+	}
+	if ref.Kind != "movie" {
+		return "", "", nil
+	}
+
+	d, err := s.details(imdbID, ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	var budget, gross string
+	if d.Budget > 0 {
+		budget = formatMoney(&IMDBMoney{Amount: d.Budget, Currency: "USD"})
+	}
+	if d.Revenue > 0 {
+		gross = formatMoney(&IMDBMoney{Amount: d.Revenue, Currency: "USD"})
+	}
+	return budget, gross, nil
+}
+
+// Localized implements LocalizedProvider. TMDB falls back to the original
+// language for untranslated titles; callers drop values equal to English.
+func (s *TMDBService) Localized(imdbID, locale string) (*MetadataLocalized, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	var d tmdbDetails
+	params := url.Values{}
+	params.Set("language", locale)
+	if err := s.get("title", imdbID, locale, fmt.Sprintf("/%s/%d", ref.Kind, ref.ID), params, &d); err != nil {
+		return nil, err
+	}
+
+	title := d.Title
+	if ref.Kind == "tv" {
+		title = d.Name
+	}
+	// TMDB answers with the English poster when it has none for the
+	// language, so only keep posters that differ from it
+	poster := tmdbImageURL("w500", d.PosterPath)
+	if en, err := s.details(imdbID, ref); err != nil || en.PosterPath == d.PosterPath {
+		poster = ""
+	}
+	return &MetadataLocalized{
+		Title:     title,
+		Plot:      d.Overview,
+		Tagline:   d.Tagline,
+		PosterURL: poster,
+	}, nil
+}
+
+// LocalizedEpisodes implements LocalizedProvider
+func (s *TMDBService) LocalizedEpisodes(imdbID, locale string) ([]MetadataEpisode, error) {
+	ref, err := s.resolve(imdbID)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Kind != "tv" {
+		return nil, fmt.Errorf("%s is not a TV series on TMDB", imdbID)
+	}
+
+	d, err := s.details(imdbID, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("language", locale)
+
+	var out []MetadataEpisode
+	for _, season := range d.Seasons {
+		if season.SeasonNumber == 0 {
+			continue
+		}
+		var sr tmdbSeasonResponse
+		variant := fmt.Sprintf("%d|%s", season.SeasonNumber, locale)
+		if err := s.get("season", imdbID, variant, fmt.Sprintf("/tv/%d/season/%d", ref.ID, season.SeasonNumber), params, &sr); err != nil {
+			return nil, err
+		}
+		for _, ep := range sr.Episodes {
+			out = append(out, MetadataEpisode{
+				Season:   ep.SeasonNumber,
+				Episode:  ep.EpisodeNumber,
+				Title:    ep.Name,
+				Plot:     ep.Overview,
+				StillURL: tmdbImageURL("w300", ep.StillPath),
+			})
+		}
+		if len(out) >= 500 {
+			break
+		}
+	}
+	return out, nil
+}
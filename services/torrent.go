@@ -209,6 +209,23 @@ func (s *TorrentService) GetFileReader(t *torrent.Torrent, fileIndex int) (io.Re
 	return reader, f.Length(), nil
 }
 
+// GetRangeReader returns a reader for a file that leaves the file's priority
+// alone, so only the pieces around each read (plus readahead bytes) are
+// downloaded
+func (s *TorrentService) GetRangeReader(t *torrent.Torrent, fileIndex int, readahead int64) (torrent.Reader, int64, error) {
+	files := t.Files()
+	if fileIndex < 0 || fileIndex >= len(files) {
+		return nil, 0, fmt.Errorf("file index out of range")
+	}
+
+	f := files[fileIndex]
+	reader := f.NewReader()
+	reader.SetReadahead(readahead)
+	reader.SetResponsive()
+
+	return reader, f.Length(), nil
+}
+
 // GetContentType returns the content type based on file extension
 func GetContentType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))